@@ -12,13 +12,14 @@ import (
 	"github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
 	"github.com/giantswarm/apiextensions/v3/pkg/label"
 	"github.com/giantswarm/backoff"
-	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	capiv1alpha3 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/apputil"
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
 )
 
 // Test_Apps test the default release apps are installed and deployed successfully.
@@ -29,11 +30,16 @@ func Test_Apps(t *testing.T) {
 
 	ctx := context.Background()
 
-	cpCtrlClient, err := ctrlclient.CreateCPCtrlClient()
+	cpCtrlClient, err := ctrlclient.ForTest(t).CPCtrlClient()
 	if err != nil {
 		t.Fatalf("error creating CP k8s client: %v", err)
 	}
 
+	tcCtrlClient, err := ctrlclient.ForTest(t).TCCtrlClient()
+	if err != nil {
+		t.Fatalf("error creating TC k8s client: %v", err)
+	}
+
 	regularLogger, err := micrologger.New(micrologger.Config{})
 	if err != nil {
 		t.Fatal(err)
@@ -73,13 +79,18 @@ func Test_Apps(t *testing.T) {
 		}
 	}
 
-	o := func() error {
-		appList := &appv1alpha1.AppList{}
-		err = cpCtrlClient.List(ctx, appList, client.InNamespace(clusterID))
-		if err != nil {
-			t.Fatal(err)
-		}
+	waiter, err := wait.New(wait.Config{
+		Logger:   logger,
+		Client:   cpCtrlClient,
+		Interval: 1 * time.Minute,
+		Timeout:  backoff.MediumMaxWait,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
+	appList := &appv1alpha1.AppList{}
+	predicate := func(client.ObjectList) (bool, string) {
 		existingApps := map[string]appv1alpha1.App{}
 		for _, app := range appList.Items {
 			existingApps[app.Name] = app
@@ -92,33 +103,36 @@ func Test_Apps(t *testing.T) {
 		for _, app := range release.Spec.Apps {
 			deployedApp, ok := existingApps[app.Name]
 			if !ok {
-				return microerror.Maskf(appNotReadyError, "App %#q was not found on the namespace %#q.", app.Name, clusterID)
+				return false, fmt.Sprintf("App %#q was not found on the namespace %#q.", app.Name, clusterID)
 			}
 
 			if deployedApp.Status.Version != app.Version {
-				return microerror.Maskf(appNotReadyError, "App %s not updated yet (version is %s, expected %s)", app.Name, deployedApp.Status.Version, app.Version)
+				return false, fmt.Sprintf("App %s not updated yet (version is %s, expected %s)", app.Name, deployedApp.Status.Version, app.Version)
 			}
 
 			switch deployedApp.Status.Release.Status {
 			case "failed":
 				t.Fatalf("App %s is in failed state", app.Name)
 			case "deployed":
-				logger.Debugf(ctx, "App %s with version %s deployed correctly.", app.Name, app.Version)
+				// Apps installed in the control plane have no workloads in the workload cluster.
+				if !deployedApp.Spec.KubeConfig.InCluster {
+					err := apputil.CheckWorkloads(ctx, tcCtrlClient, &deployedApp)
+					if err != nil {
+						return false, err.Error()
+					}
+				}
 				continue
 			default:
-				return microerror.Maskf(appNotReadyError, "App %s with version %s is still in state %s.", app.Name, app.Version, deployedApp.Status.Release.Status)
 				// Have to wait.
+				return false, fmt.Sprintf("App %s with version %s is still in state %s.", app.Name, app.Version, deployedApp.Status.Release.Status)
 			}
 		}
 
-		return nil
+		return true, ""
 	}
 
-	b := backoff.NewConstant(backoff.MediumMaxWait, 1*time.Minute)
-	n := backoff.NewNotifier(logger, ctx)
-	err = backoff.RetryNotify(o, b, n)
+	err = waiter.UntilList(ctx, appList, "be deployed", predicate, client.InNamespace(clusterID))
 	if err != nil {
-		t.Fatalf("Error waiting for apps to be deployed.")
+		t.Fatalf("Error waiting for apps to be deployed: %s", err)
 	}
-
 }
@@ -2,12 +2,12 @@ package sonobuoy_plugin
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/Azure/go-autorest/autorest/to"
-	"github.com/giantswarm/backoff"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
@@ -15,13 +15,14 @@ import (
 	"github.com/kyverno/kyverno/api/kyverno/v2beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/provider"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
 )
 
 const (
@@ -33,16 +34,18 @@ const (
 func Test_Autoscaler(t *testing.T) {
 	t.Parallel()
 
+	provider.SkipUnlessSupported(t, provider.NodePools, provider.Autoscaler)
+
 	var err error
 
 	ctx := context.Background()
 
-	tcCtrlClient, err := ctrlclient.CreateTCCtrlClient()
+	tcCtrlClient, err := ctrlclient.ForTest(t).TCCtrlClient()
 	if err != nil {
 		t.Fatalf("error creating TC k8s client: %v", err)
 	}
 
-	cpCtrlClient, err := ctrlclient.CreateCPCtrlClient()
+	cpCtrlClient, err := ctrlclient.ForTest(t).CPCtrlClient()
 	if err != nil {
 		t.Fatalf("error creating CP k8s client: %v", err)
 	}
@@ -110,24 +113,18 @@ func Test_Autoscaler(t *testing.T) {
 		t.Fatalf("%v", err)
 	}
 
-	logger.Debugf(ctx, "Waiting for %d worker nodes to exist", expectedWorkersCount)
-
-	// Wait for nodes to increase by one.
-	o := func() error {
-		workersCount, err := getWorkersCount(ctx, tcCtrlClient, machinePoolName, nodeSelectorLabel)
-		if err != nil {
-			return microerror.Mask(err)
-		}
-
-		if int32(workersCount) != expectedWorkersCount {
-			return microerror.Maskf(executionFailedError, "Expecting %d workers, %d found", expectedWorkersCount, workersCount)
-		}
-
-		return nil
+	waiter, err := wait.New(wait.Config{
+		Logger:   logger,
+		Client:   tcCtrlClient,
+		Interval: 1 * time.Minute,
+		Timeout:  120 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
 	}
-	b := backoff.NewConstant(120*time.Minute, 1*time.Minute)
-	n := backoff.NewNotifier(logger, ctx)
-	err = backoff.RetryNotify(o, b, n)
+
+	// Wait for nodes to increase by two.
+	err = waitForWorkersCount(ctx, waiter, machinePoolName, nodeSelectorLabel, expectedWorkersCount)
 	if err != nil {
 		t.Fatalf("timeout waiting for cluster to scale up: %v", err)
 	}
@@ -140,8 +137,7 @@ func Test_Autoscaler(t *testing.T) {
 		t.Fatalf("timeout waiting for cluster to scale down: %v", err)
 	}
 
-	b = backoff.NewConstant(120*time.Minute, 1*time.Minute)
-	err = backoff.RetryNotify(o, b, n)
+	err = waitForWorkersCount(ctx, waiter, machinePoolName, nodeSelectorLabel, expectedWorkersCount)
 	if err != nil {
 		t.Fatalf("timeout waiting for cluster to scale down: %v", err)
 	}
@@ -157,30 +153,32 @@ func getWorkersCount(ctx context.Context, ctrlClient client.Client, machinePoolN
 	return len(workers.Items), nil
 }
 
+func waitForWorkersCount(ctx context.Context, waiter *wait.Waiter, machinePoolName string, labelSelector string, expectedWorkersCount int32) error {
+	workers := &corev1.NodeList{}
+	predicate := func(client.ObjectList) (bool, string) {
+		return int32(len(workers.Items)) == expectedWorkersCount, fmt.Sprintf("%d workers found", len(workers.Items))
+	}
+
+	err := waiter.UntilList(ctx, workers, fmt.Sprintf("have %d workers", expectedWorkersCount), predicate, client.MatchingLabels{"kubernetes.io/role": "worker", labelSelector: machinePoolName})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
 func scaleDeployment(ctx context.Context, ctrlClient client.Client, expectedWorkersCount int32) error {
-	o := func() error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		deployment := &appsv1.Deployment{}
 		err := ctrlClient.Get(ctx, client.ObjectKey{Namespace: helloWorldNamespace, Name: helloWorldDeploymentName}, deployment)
 		if err != nil {
-			return microerror.Mask(err)
+			return err
 		}
 
 		deployment.Spec.Replicas = &expectedWorkersCount
 
-		err = ctrlClient.Update(ctx, deployment)
-		if apierrors.IsConflict(err) {
-			// Retriable error.
-			return microerror.Mask(err)
-		} else if err != nil {
-			// Wrap masked error with backoff.Permanent() to stop retries on unrecoverable error.
-			return backoff.Permanent(microerror.Mask(err))
-		}
-
-		return nil
-	}
-
-	b := backoff.NewConstant(backoff.ShortMaxWait, backoff.ShortMaxInterval)
-	err := backoff.Retry(o, b)
+		return ctrlClient.Update(ctx, deployment)
+	})
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -2,6 +2,7 @@ package sonobuoy_plugin
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -15,12 +16,15 @@ import (
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/provider"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
 )
 
 // Test_CgroupsV1 creates a node pool with cgroups V1 and ensures nodes become ready.
 func Test_CgroupsV1(t *testing.T) {
 	t.Parallel()
 
+	provider.SkipUnlessSupported(t, provider.NodePools, provider.CgroupsV1)
+
 	var err error
 
 	ctx := context.Background()
@@ -32,12 +36,12 @@ func Test_CgroupsV1(t *testing.T) {
 
 	logger := NewTestLogger(regularLogger, t)
 
-	tcCtrlClient, err := ctrlclient.CreateTCCtrlClient()
+	tcCtrlClient, err := ctrlclient.ForTest(t).TCCtrlClient()
 	if err != nil {
 		t.Fatalf("error creating TC k8s client: %v", err)
 	}
 
-	cpCtrlClient, err := ctrlclient.CreateCPCtrlClient()
+	cpCtrlClient, err := ctrlclient.ForTest(t).CPCtrlClient()
 	if err != nil {
 		t.Fatalf("error creating CP k8s client: %v", err)
 	}
@@ -57,8 +61,20 @@ func Test_CgroupsV1(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	machinePoolObjectKey, err := providerSupport.CreateNodePoolAndWaitReady(ctx, cpCtrlClient, cluster, providerSupport.GetProviderAZs(), true)
-	if err != nil {
+	spec := provider.NodePoolSpec{
+		AvailabilityZones: providerSupport.GetProviderAZs(),
+		CgroupsV1:         true,
+	}
+	// The node pool gets one node per availability zone.
+	desiredNodes := len(spec.AvailabilityZones)
+	if desiredNodes == 0 {
+		t.Fatalf("provider reports no availability zones")
+	}
+
+	machinePoolObjectKey, err := providerSupport.CreateNodePoolAndWaitReady(ctx, cpCtrlClient, cluster, spec)
+	if provider.IsUnsupportedNodePoolSpec(err) {
+		t.Skipf("node pools of the cluster can't use cgroups v1: %s", microerror.JSON(err))
+	} else if err != nil {
 		t.Fatal(err)
 	}
 
@@ -66,17 +82,18 @@ func Test_CgroupsV1(t *testing.T) {
 		_ = providerSupport.DeleteNodePool(ctx, cpCtrlClient, *machinePoolObjectKey)
 	})
 
-	o := func() error {
-		desiredNodes := 3
-
-		nodes := v1.NodeList{}
-		err = tcCtrlClient.List(ctx, &nodes, client.MatchingLabels{
-			providerSupport.GetNodeSelectorLabel(): machinePoolObjectKey.Name,
-		})
-		if err != nil {
-			t.Fatal(err)
-		}
+	waiter, err := wait.New(wait.Config{
+		Logger:   logger,
+		Client:   tcCtrlClient,
+		Interval: 1 * time.Minute,
+		Timeout:  backoff.LongMaxWait,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
+	nodes := &v1.NodeList{}
+	predicate := func(client.ObjectList) (bool, string) {
 		readyNodes := 0
 		for _, node := range nodes.Items {
 			for _, condition := range node.Status.Conditions {
@@ -90,17 +107,13 @@ func Test_CgroupsV1(t *testing.T) {
 			}
 		}
 
-		if readyNodes != desiredNodes {
-			return microerror.Maskf(executionFailedError, "Expected cgroups v1 node pool to have %d ready replicas, but only had %d", desiredNodes, readyNodes)
-		}
-
-		return nil
+		return readyNodes == desiredNodes, fmt.Sprintf("%d of %d nodes are ready", readyNodes, desiredNodes)
 	}
 
-	b := backoff.NewConstant(backoff.LongMaxWait, 1*time.Minute)
-	n := backoff.NewNotifier(logger, ctx)
-	err = backoff.RetryNotify(o, b, n)
+	err = waiter.UntilList(ctx, nodes, fmt.Sprintf("have %d ready nodes", desiredNodes), predicate, client.MatchingLabels{
+		providerSupport.GetNodeSelectorLabel(): machinePoolObjectKey.Name,
+	})
 	if err != nil {
-		t.Fatal("Node pool with cgroups v1 did not become ready in time.")
+		t.Fatalf("Node pool with cgroups v1 did not become ready in time: %s", microerror.JSON(err))
 	}
 }
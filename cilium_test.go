@@ -24,6 +24,7 @@ import (
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/podrunner"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
 )
 
 const (
@@ -40,12 +41,12 @@ func Test_Cilium(t *testing.T) {
 
 	ctx := context.Background()
 
-	tcCtrlClient, err := ctrlclient.CreateTCCtrlClient()
+	tcCtrlClient, err := ctrlclient.ForTest(t).TCCtrlClient()
 	if err != nil {
 		t.Fatalf("error creating TC k8s client: %v", err)
 	}
 
-	cpCtrlClient, err := ctrlclient.CreateCPCtrlClient()
+	cpCtrlClient, err := ctrlclient.ForTest(t).CPCtrlClient()
 	if err != nil {
 		t.Fatalf("error creating CP k8s client: %v", err)
 	}
@@ -103,65 +104,62 @@ func Test_Cilium(t *testing.T) {
 		return
 	}
 
-	// Wait for cilium app to be deployed.
-	o := func() error {
-		deployedApp := &appv1alpha1.App{}
-		err = cpCtrlClient.Get(ctx, client.ObjectKey{Namespace: clusterID, Name: ciliumAppName}, deployedApp)
-		if err != nil {
-			return microerror.Maskf(appNotReadyError, "App %s does not exist", ciliumAppName)
-		}
+	waiter, err := wait.New(wait.Config{
+		Logger:   logger,
+		Client:   cpCtrlClient,
+		Interval: 1 * time.Minute,
+		Timeout:  backoff.MediumMaxWait,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
+	// Wait for cilium app to be deployed.
+	deployedApp := &appv1alpha1.App{ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: ciliumAppName}}
+	deployed := func(client.Object) (bool, string) {
 		if deployedApp.Status.Version != desiredVersion {
-			return microerror.Maskf(appNotReadyError, "App %s not updated yet (version is %s, expected %s)", ciliumAppName, deployedApp.Status.Version, desiredVersion)
+			return false, fmt.Sprintf("App %s not updated yet (version is %s, expected %s)", ciliumAppName, deployedApp.Status.Version, desiredVersion)
 		}
 
 		switch deployedApp.Status.Release.Status {
 		case "failed":
 			t.Fatalf("App %s is in failed state", ciliumAppName)
 		case "deployed":
-			logger.Debugf(ctx, "App %s with version %s deployed correctly.", ciliumAppName, desiredVersion)
-		default:
-			return microerror.Maskf(appNotReadyError, "App %s with version %s is still in state %s.", ciliumAppName, desiredVersion, deployedApp.Status.Release.Status)
-			// Have to wait.
+			return true, ""
 		}
 
-		return nil
+		// Have to wait.
+		return false, fmt.Sprintf("App %s with version %s is still in state %s.", ciliumAppName, desiredVersion, deployedApp.Status.Release.Status)
 	}
 
-	b := backoff.NewConstant(backoff.MediumMaxWait, 1*time.Minute)
-	n := backoff.NewNotifier(logger, ctx)
-	err = backoff.RetryNotify(o, b, n)
+	err = waiter.UntilPredicate(ctx, deployedApp, "be deployed", deployed)
 	if err != nil {
-		t.Fatalf("Error waiting for apps to be deployed.")
+		t.Fatalf("Error waiting for apps to be deployed: %s", microerror.JSON(err))
 	}
 
-	labelSelector := metav1.LabelSelector{}
+	tcWaiter, err := wait.New(wait.Config{
+		Logger:   logger,
+		Client:   tcCtrlClient,
+		Interval: 1 * time.Minute,
+		Timeout:  backoff.MediumMaxWait,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// Wait for cilium daemonset to be satisfied
-	o = func() error {
-		ds := &v1.DaemonSet{}
-
-		err = tcCtrlClient.Get(ctx, client.ObjectKey{Namespace: ciliumDsNamespace, Name: ciliumDsName}, ds)
-		if err != nil {
-			t.Fatalf("Error waiting for ds to be satisfied.")
-		}
-
-		if ds.Status.DesiredNumberScheduled != ds.Status.NumberAvailable {
-			return microerror.Maskf(dsNotReadyError, "DS %s has %d replicas ready but wants %d.", ciliumDsName, ds.Status.NumberAvailable, ds.Status.DesiredNumberScheduled)
-		}
-
-		logger.Debugf(ctx, "%d out of %d pods ready and available for DS %s.", ds.Status.NumberAvailable, ds.Status.DesiredNumberScheduled, ciliumDsName)
-
-		labelSelector = *ds.Spec.Selector
-
-		return nil
+	ds := &v1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: ciliumDsNamespace, Name: ciliumDsName}}
+	available := func(client.Object) (bool, string) {
+		return ds.Status.DesiredNumberScheduled == ds.Status.NumberAvailable, fmt.Sprintf("%d out of %d pods are available", ds.Status.NumberAvailable, ds.Status.DesiredNumberScheduled)
 	}
 
-	err = backoff.RetryNotify(o, b, n)
+	err = tcWaiter.UntilPredicate(ctx, ds, "be satisfied", available)
 	if err != nil {
-		t.Fatalf("Error waiting for ds to be satisfied.")
+		t.Fatalf("Error waiting for ds to be satisfied: %s", microerror.JSON(err))
 	}
 
+	labelSelector := *ds.Spec.Selector
+
 	// Check cilium status from inside one of the cilium pods.
 	pods := &corev1.PodList{}
 
@@ -172,14 +170,22 @@ func Test_Cilium(t *testing.T) {
 
 	pod := pods.Items[0]
 
-	kc, err := ctrlclient.GetTCKubeConfig()
+	tcClients, err := ctrlclient.ForTest(t).TC()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	stdout, _, err := podrunner.ExecInPod(ctx, logger, pod.Name, ciliumDsNamespace, "cilium-agent", []string{"cilium", "status", "-o", "json"}, kc)
+	result, err := podrunner.Exec(ctx, logger, tcClients, podrunner.ExecOptions{
+		PodName:       pod.Name,
+		Namespace:     ciliumDsNamespace,
+		ContainerName: "cilium-agent",
+		Command:       []string{"cilium", "status", "-o", "json"},
+	})
 	if err != nil {
-		t.Fatalf("Can't exec command in cilium pod %s.", pod.Name)
+		t.Fatalf("Can't exec command in cilium pod %s: %v", pod.Name, err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("Command in cilium pod %s exited with code %d: %s", pod.Name, result.ExitCode, result.Stderr)
 	}
 
 	response := struct {
@@ -193,7 +199,7 @@ func Test_Cilium(t *testing.T) {
 		}
 	}{}
 
-	err = json.Unmarshal([]byte(stdout), &response)
+	err = json.Unmarshal([]byte(result.Stdout), &response)
 	if err != nil {
 		t.Fatalf("Can't exec command in cilium pod %s.", pod.Name)
 	}
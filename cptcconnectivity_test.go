@@ -7,14 +7,12 @@ import (
 	"testing"
 
 	"github.com/giantswarm/backoff"
-	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	capiv1alpha3 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/podrunner"
 )
 
 const (
@@ -31,10 +29,11 @@ func Test_CPTCConnectivity(t *testing.T) {
 
 	ctx := context.Background()
 
-	cpCtrlClient, err := ctrlclient.CreateCPCtrlClient()
+	cpClients, err := ctrlclient.ForTest(t).CP()
 	if err != nil {
 		t.Fatalf("error creating CP k8s client: %v", err)
 	}
+	cpCtrlClient := cpClients.CtrlClient
 
 	regularLogger, err := micrologger.New(micrologger.Config{})
 	if err != nil {
@@ -58,46 +57,17 @@ func Test_CPTCConnectivity(t *testing.T) {
 	k8sAPIEndpointPort := fmt.Sprintf("%d", clusterList.Items[0].Spec.ControlPlaneEndpoint.Port)
 
 	logger.Debugf(ctx, "testing connectivity between control plane cluster and tenant cluster")
-	pod := &corev1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      podName,
-			Namespace: clusterID,
-		},
-		Spec: corev1.PodSpec{
-			RestartPolicy: corev1.RestartPolicyNever,
-			Containers:    []corev1.Container{{Name: "test", Image: "quay.io/giantswarm/busybox:1.34.1", Command: []string{"nc"}, Args: []string{"-z", k8sAPIEndpointHost, k8sAPIEndpointPort}}},
-		},
-	}
-	err = cpCtrlClient.Create(ctx, pod)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	t.Cleanup(func() {
-		_ = cpCtrlClient.Delete(ctx, pod)
+	_, err = podrunner.RunProbe(ctx, podrunner.ProbeConfig{
+		Clients:   cpClients,
+		Logger:    logger,
+		Name:      podName,
+		Namespace: clusterID,
+		Image:     "quay.io/giantswarm/busybox:1.34.1",
+		Command:   []string{"nc"},
+		Args:      []string{"-z", k8sAPIEndpointHost, k8sAPIEndpointPort},
+		Expected:  podrunner.Success,
+		Timeout:   backoff.MediumMaxWait,
 	})
-
-	o := func() error {
-		objectKey := client.ObjectKeyFromObject(pod)
-		scheduledPod := &corev1.Pod{}
-		err = cpCtrlClient.Get(ctx, objectKey, scheduledPod)
-		if err != nil {
-			return microerror.Mask(err)
-		}
-
-		if scheduledPod.Status.Phase != corev1.PodSucceeded {
-			return microerror.Maskf(executionFailedError, "container didn't finish yet, pod state is %#q", scheduledPod.Status.Phase)
-		}
-
-		if scheduledPod.Status.ContainerStatuses[0].State.Terminated.ExitCode != 0 {
-			return microerror.Maskf(executionFailedError, "expected container exit code is 0, got %d", scheduledPod.Status.ContainerStatuses[0].LastTerminationState.Terminated.ExitCode)
-		}
-
-		return nil
-	}
-	b := backoff.NewExponential(backoff.MediumMaxWait, backoff.ShortMaxInterval)
-	n := backoff.NewNotifier(logger, ctx)
-	err = backoff.RetryNotify(o, b, n)
 	if err != nil {
 		t.Fatalf("couldn't connect from control plane cluster to tenant cluster: %v", err)
 	}
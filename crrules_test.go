@@ -0,0 +1,65 @@
+package sonobuoy_plugin
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/provider"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/rules"
+)
+
+const (
+	crRulesDirEnvVarName = "CR_RULES_DIR"
+	defaultCRRulesDir    = "crrules"
+)
+
+// Test_CRRules evaluates the declarative CR invariants defined in the YAML
+// rule files found in $CR_RULES_DIR (defaults to the crrules directory). Every
+// rule file becomes a subtest and every rule a subtest of its file.
+func Test_CRRules(t *testing.T) {
+	t.Parallel()
+
+	var err error
+
+	ctx := context.Background()
+
+	cpCtrlClient, err := ctrlclient.ForTest(t).CPCtrlClient()
+	if err != nil {
+		t.Fatalf("error creating CP k8s client: %v", err)
+	}
+
+	clusterID, exists := os.LookupEnv("CLUSTER_ID")
+	if !exists {
+		t.Fatal("missing CLUSTER_ID environment variable")
+	}
+
+	dir := os.Getenv(crRulesDirEnvVarName)
+	if dir == "" {
+		dir = defaultCRRulesDir
+	}
+
+	ruleSets, err := rules.LoadDir(dir)
+	if err != nil {
+		t.Fatalf("error loading CR rules from %q: %s", dir, microerror.JSON(err))
+	}
+
+	engine, err := rules.New(rules.Config{
+		Client:    cpCtrlClient,
+		ClusterID: clusterID,
+		Provider:  provider.GetProvider(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ruleSet := range ruleSets {
+		ruleSet := ruleSet
+		t.Run(ruleSet.Name, func(t *testing.T) {
+			engine.Run(t, ctx, ruleSet)
+		})
+	}
+}
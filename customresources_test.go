@@ -0,0 +1,775 @@
+package sonobuoy_plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions/v3/pkg/annotation"
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	kcp "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	capiconditions "sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/assert"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/provider"
+)
+
+// Test_CustomResources checks the metadata, spec and status of the Cluster
+// API and provider specific CRs of the tested cluster. See the "Custom
+// Resources" section of the README for the complete list of checks. Failed
+// checks are collected and reported together at the end of each subtest.
+func Test_CustomResources(t *testing.T) {
+	t.Parallel()
+
+	var err error
+
+	ctx := context.Background()
+
+	cpCtrlClient, err := ctrlclient.ForTest(t).CPCtrlClient()
+	if err != nil {
+		t.Fatalf("error creating CP k8s client: %v", err)
+	}
+
+	tcCtrlClient, err := ctrlclient.ForTest(t).TCCtrlClient()
+	if err != nil {
+		t.Fatalf("error creating TC k8s client: %v", err)
+	}
+
+	regularLogger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := NewTestLogger(regularLogger, t)
+
+	clusterID, exists := os.LookupEnv("CLUSTER_ID")
+	if !exists {
+		t.Fatal("missing CLUSTER_ID environment variable")
+	}
+
+	cluster, err := capiutil.FindCluster(ctx, cpCtrlClient, clusterID)
+	if err != nil {
+		t.Fatalf("error finding cluster: %s", microerror.JSON(err))
+	}
+	setGVK(t, cluster)
+
+	operatorVersionLabel := getOperatorVersionLabel()
+
+	t.Run("Cluster", func(t *testing.T) {
+		testCluster(t, ctx, logger, cpCtrlClient, cluster, operatorVersionLabel)
+	})
+
+	t.Run("Topology", func(t *testing.T) {
+		if !capiutil.IsTopologyManaged(cluster) {
+			t.Skipf("cluster %q is not created from a ClusterClass", clusterID)
+		}
+
+		testTopology(t, ctx, logger, cpCtrlClient, cluster)
+	})
+
+	t.Run("MachinePool", func(t *testing.T) {
+		machinePools, err := capiutil.FindNonTestingMachinePoolsForCluster(ctx, cpCtrlClient, clusterID)
+		if err != nil {
+			t.Fatalf("error finding MachinePools for cluster %q: %s", clusterID, microerror.JSON(err))
+		}
+
+		if len(machinePools) == 0 {
+			t.Skipf("cluster %q does not have any MachinePools", clusterID)
+		}
+
+		for i := range machinePools {
+			machinePool := &machinePools[i]
+			setGVK(t, machinePool)
+
+			t.Run(machinePool.Name, func(t *testing.T) {
+				testMachinePool(t, ctx, logger, cpCtrlClient, cluster, machinePool, operatorVersionLabel)
+			})
+		}
+	})
+
+	t.Run("MachineDeployment", func(t *testing.T) {
+		machineDeployments, err := capiutil.FindNonTestingMachineDeploymentsForCluster(ctx, cpCtrlClient, clusterID)
+		if err != nil {
+			t.Fatalf("error finding MachineDeployments for cluster %q: %s", clusterID, microerror.JSON(err))
+		}
+
+		if len(machineDeployments) == 0 {
+			t.Skipf("cluster %q does not have any MachineDeployments", clusterID)
+		}
+
+		for i := range machineDeployments {
+			machineDeployment := &machineDeployments[i]
+			setGVK(t, machineDeployment)
+
+			t.Run(machineDeployment.Name, func(t *testing.T) {
+				testMachineDeployment(t, ctx, logger, cpCtrlClient, cluster, machineDeployment)
+			})
+		}
+	})
+
+	t.Run("KubeadmControlPlane", func(t *testing.T) {
+		kubeadmControlPlane, err := capiutil.FindKubeadmControlPlane(ctx, cpCtrlClient, cluster)
+		if capiutil.IsNotFound(err) {
+			t.Skipf("control plane of cluster %q is not a KubeadmControlPlane", clusterID)
+		} else if err != nil {
+			t.Fatalf("error finding KubeadmControlPlane for cluster %q: %s", clusterID, microerror.JSON(err))
+		}
+		setGVK(t, kubeadmControlPlane)
+
+		testKubeadmControlPlane(t, ctx, logger, cpCtrlClient, cluster, kubeadmControlPlane)
+	})
+
+	t.Run("Machine", func(t *testing.T) {
+		machines, err := capiutil.FindNonTestingMachinesForCluster(ctx, cpCtrlClient, clusterID)
+		if err != nil {
+			t.Fatalf("error finding Machines for cluster %q: %s", clusterID, microerror.JSON(err))
+		}
+
+		if len(machines) == 0 {
+			t.Skipf("cluster %q does not have any Machines", clusterID)
+		}
+
+		for i := range machines {
+			machine := &machines[i]
+			if machine.DeletionTimestamp != nil {
+				continue
+			}
+
+			t.Run(machine.Name, func(t *testing.T) {
+				// Machines are replaced while the previous ones are tested,
+				// e.g. during a rolling update.
+				err := cpCtrlClient.Get(ctx, ctrl.ObjectKeyFromObject(machine), machine)
+				if apierrors.IsNotFound(err) {
+					t.Skipf("Machine %q is gone", machine.Name)
+				} else if err != nil {
+					t.Fatalf("error getting Machine %q: %s", machine.Name, microerror.JSON(err))
+				}
+				if machine.DeletionTimestamp != nil {
+					t.Skipf("Machine %q is being deleted", machine.Name)
+				}
+				setGVK(t, machine)
+
+				testMachine(t, ctx, logger, cpCtrlClient, tcCtrlClient, machine)
+			})
+		}
+	})
+
+	t.Run("MachineHealthCheck", func(t *testing.T) {
+		machineHealthChecks, err := capiutil.FindMachineHealthChecksForCluster(ctx, cpCtrlClient, cluster)
+		if err != nil {
+			t.Fatalf("error finding MachineHealthChecks for cluster %q: %s", clusterID, microerror.JSON(err))
+		}
+
+		if len(machineHealthChecks) == 0 {
+			t.Skipf("cluster %q does not have any MachineHealthChecks", clusterID)
+		}
+
+		for i := range machineHealthChecks {
+			machineHealthCheck := &machineHealthChecks[i]
+			setGVK(t, machineHealthCheck)
+
+			t.Run(machineHealthCheck.Name, func(t *testing.T) {
+				testMachineHealthCheck(t, ctx, logger, cpCtrlClient, machineHealthCheck)
+			})
+		}
+	})
+
+	t.Run("AzureCluster", func(t *testing.T) {
+		if provider.GetProvider() != "azure" {
+			t.Skipf("AzureCluster checks are not supported on provider %q", provider.GetProvider())
+		}
+
+		azureCluster, err := capiutil.FindAzureCluster(ctx, cpCtrlClient, clusterID)
+		if err != nil {
+			t.Fatalf("error finding AzureCluster for cluster %q: %s", clusterID, microerror.JSON(err))
+		}
+		setGVK(t, azureCluster)
+
+		testAzureCluster(t, ctx, logger, cpCtrlClient, cluster, azureCluster, operatorVersionLabel)
+	})
+
+	t.Run("AzureMachinePool", func(t *testing.T) {
+		if provider.GetProvider() != "azure" {
+			t.Skipf("AzureMachinePool checks are not supported on provider %q", provider.GetProvider())
+		}
+
+		azureMachinePools, err := capiutil.FindNonTestingAzureMachinePoolsForCluster(ctx, cpCtrlClient, clusterID)
+		if err != nil {
+			t.Fatalf("error finding AzureMachinePools for cluster %q: %s", clusterID, microerror.JSON(err))
+		}
+
+		if len(azureMachinePools) == 0 {
+			t.Skipf("cluster %q does not have any AzureMachinePools", clusterID)
+		}
+
+		for i := range azureMachinePools {
+			azureMachinePool := &azureMachinePools[i]
+			setGVK(t, azureMachinePool)
+
+			t.Run(azureMachinePool.Name, func(t *testing.T) {
+				testAzureMachinePool(t, ctx, logger, cpCtrlClient, cluster, azureMachinePool, operatorVersionLabel)
+			})
+		}
+	})
+}
+
+func testCluster(t *testing.T, ctx context.Context, logger micrologger.Logger, cpCtrlClient ctrl.Client, cluster *capi.Cluster, operatorVersionLabel string) {
+	c := assert.NewCollector(t, assert.SoftMode)
+
+	// Metadata checks.
+	{
+		assert.LabelIsSet(c, cluster, label.ReleaseVersion)
+		if operatorVersionLabel != "" {
+			assert.LabelIsSet(c, cluster, operatorVersionLabel)
+		}
+		assert.AnnotationIsSet(c, cluster, annotation.LastDeployedReleaseVersion)
+
+		releaseVersion := cluster.GetLabels()[label.ReleaseVersion]
+		lastDeployedReleaseVersion := cluster.GetAnnotations()[annotation.LastDeployedReleaseVersion]
+		if releaseVersion != lastDeployedReleaseVersion {
+			c.Fatalf("Cluster '%s/%s': expected label %q value %q to match annotation %q value %q",
+				cluster.Namespace,
+				cluster.Name,
+				label.ReleaseVersion,
+				releaseVersion,
+				annotation.LastDeployedReleaseVersion,
+				lastDeployedReleaseVersion)
+		}
+	}
+
+	// Status checks.
+	{
+		capiutil.WaitForCondition(t, ctx, logger, cpCtrlClient, cluster, capi.ReadyCondition, capiconditions.IsTrue)
+		capiutil.WaitForCondition(t, ctx, logger, cpCtrlClient, cluster, capiutil.CreatingCondition, capiconditions.IsFalse)
+		capiutil.WaitForCondition(t, ctx, logger, cpCtrlClient, cluster, capiutil.UpgradingCondition, capiconditions.IsFalse)
+		assertConditionNeverReturned(c, "Cluster", cluster, string(capiutil.UpgradingCondition), corev1.ConditionTrue)
+
+		if !capiconditions.IsTrue(cluster, capi.ControlPlaneInitializedCondition) {
+			c.Fatalf("Cluster %q: expected condition %q to be True", cluster.Name, capi.ControlPlaneInitializedCondition)
+		}
+
+		if !cluster.Status.ControlPlaneReady {
+			c.Fatalf("Cluster %q: expected Status.ControlPlaneReady to be true", cluster.Name)
+		}
+
+		if !cluster.Status.InfrastructureReady {
+			c.Fatalf("Cluster %q: expected Status.InfrastructureReady to be true", cluster.Name)
+		}
+
+		if reason := capiconditions.GetReason(cluster, capiutil.CreatingCondition); reason != capiutil.CreationCompletedReason {
+			c.Fatalf("Cluster %q: expected condition %q to have reason %q, got %q", cluster.Name, capiutil.CreatingCondition, capiutil.CreationCompletedReason, reason)
+		}
+
+		for _, conditionType := range []capi.ConditionType{capi.ControlPlaneReadyCondition, capi.InfrastructureReadyCondition, capiutil.NodePoolsReadyCondition} {
+			if status := capiutil.GetCondition(cluster, conditionType); status != corev1.ConditionTrue {
+				c.Fatalf("Cluster %q: expected condition %q to have status %q, got %q", cluster.Name, conditionType, corev1.ConditionTrue, status)
+			}
+		}
+	}
+}
+
+func testTopology(t *testing.T, ctx context.Context, logger micrologger.Logger, cpCtrlClient ctrl.Client, cluster *capi.Cluster) {
+	c := assert.NewCollector(t, assert.SoftMode)
+
+	capiutil.WaitForCondition(t, ctx, logger, cpCtrlClient, cluster, capi.TopologyReconciledCondition, capiconditions.IsTrue)
+
+	topology, err := capiutil.ResolveTopology(ctx, cpCtrlClient, cluster)
+	if err != nil {
+		t.Fatalf("error resolving topology of cluster %q: %s", cluster.Name, microerror.JSON(err))
+	}
+	clusterClass := topology.ClusterClass
+
+	// Variable checks.
+	{
+		for _, variable := range clusterClass.Spec.Variables {
+			if _, ok := topology.Variables[variable.Name]; variable.Required && !ok {
+				c.Fatalf("Cluster %q: expected required variable %q of ClusterClass %q to be set", cluster.Name, variable.Name, clusterClass.Name)
+			}
+		}
+	}
+
+	// Infrastructure checks.
+	{
+		if ref := clusterClass.Spec.Infrastructure.Ref; ref != nil && ref.Kind != cluster.Spec.InfrastructureRef.Kind+"Template" {
+			c.Fatalf("Cluster %q: expected infrastructure %s to be created from ClusterClass template %s", cluster.Name, cluster.Spec.InfrastructureRef.Kind, ref.Kind)
+		}
+	}
+
+	// Control plane checks.
+	{
+		controlPlane, err := capiutil.FindControlPlane(ctx, cpCtrlClient, cluster)
+		if err != nil {
+			t.Fatalf("error finding control plane of cluster %q: %s", cluster.Name, microerror.JSON(err))
+		}
+
+		if ref := clusterClass.Spec.ControlPlane.Ref; ref != nil && ref.Kind != controlPlane.GetKind()+"Template" {
+			c.Fatalf("Cluster %q: expected control plane %s to be created from ClusterClass template %s", cluster.Name, controlPlane.GetKind(), ref.Kind)
+		}
+
+		version, _, _ := unstructured.NestedString(controlPlane.Object, "spec", "version")
+		if version != topology.Version {
+			c.Fatalf("%s %q: expected spec.version to be %q (from topology), got %q", controlPlane.GetKind(), controlPlane.GetName(), topology.Version, version)
+		}
+
+		replicas, _, _ := unstructured.NestedInt64(controlPlane.Object, "spec", "replicas")
+		if topology.ControlPlane.Replicas != nil && replicas != int64(*topology.ControlPlane.Replicas) {
+			c.Fatalf("%s %q: expected spec.replicas to be %d (from topology), got %d", controlPlane.GetKind(), controlPlane.GetName(), *topology.ControlPlane.Replicas, replicas)
+		}
+	}
+
+	// Worker checks.
+	{
+		workers := map[string]bool{}
+		for _, worker := range topology.Workers {
+			workers[worker.Name] = true
+
+			// Worker pools created by other e2e tests may not be generated
+			// yet.
+			if _, isE2E := worker.Metadata.Labels[capiutil.E2ENodepool]; isE2E {
+				continue
+			}
+
+			if worker.Class == nil {
+				c.Fatalf("Cluster %q: worker class %q of topology %q is not defined in ClusterClass %q", cluster.Name, worker.MachineDeploymentTopology.Class, worker.Name, clusterClass.Name)
+				continue
+			}
+
+			machineDeployment, err := capiutil.FindTopologyMachineDeployment(ctx, cpCtrlClient, cluster, worker.Name)
+			if err != nil {
+				c.Fatalf("Cluster %q: cannot find MachineDeployment of topology %q: %s", cluster.Name, worker.Name, microerror.JSON(err))
+				continue
+			}
+
+			if version := machineDeployment.Spec.Template.Spec.Version; version == nil || *version != topology.Version {
+				c.Fatalf("MachineDeployment %q: expected Spec.Template.Spec.Version to be %q (from topology)", machineDeployment.Name, topology.Version)
+			}
+
+			if worker.Replicas != nil && (machineDeployment.Spec.Replicas == nil || *machineDeployment.Spec.Replicas != *worker.Replicas) {
+				c.Fatalf("MachineDeployment %q: expected Spec.Replicas to be %d (from topology)", machineDeployment.Name, *worker.Replicas)
+			}
+
+			if worker.FailureDomain != nil && (machineDeployment.Spec.Template.Spec.FailureDomain == nil || *machineDeployment.Spec.Template.Spec.FailureDomain != *worker.FailureDomain) {
+				c.Fatalf("MachineDeployment %q: expected Spec.Template.Spec.FailureDomain to be %q (from topology)", machineDeployment.Name, *worker.FailureDomain)
+			}
+
+			if ref := worker.Class.Template.Bootstrap.Ref; ref != nil {
+				if configRef := machineDeployment.Spec.Template.Spec.Bootstrap.ConfigRef; configRef == nil || ref.Kind != configRef.Kind+"Template" {
+					c.Fatalf("MachineDeployment %q: expected bootstrap config to be created from worker class template %s", machineDeployment.Name, ref.Kind)
+				}
+			}
+
+			if ref := worker.Class.Template.Infrastructure.Ref; ref != nil && ref.Kind != machineDeployment.Spec.Template.Spec.InfrastructureRef.Kind+"Template" {
+				c.Fatalf("MachineDeployment %q: expected infrastructure %s to be created from worker class template %s", machineDeployment.Name, machineDeployment.Spec.Template.Spec.InfrastructureRef.Kind, ref.Kind)
+			}
+		}
+
+		var machineDeploymentList capi.MachineDeploymentList
+		err := cpCtrlClient.List(ctx, &machineDeploymentList, ctrl.InNamespace(cluster.Namespace), ctrl.MatchingLabels{capi.ClusterNameLabel: cluster.Name}, ctrl.HasLabels{capi.ClusterTopologyOwnedLabel})
+		if err != nil {
+			t.Fatalf("error finding MachineDeployments of cluster %q: %s", cluster.Name, microerror.JSON(err))
+		}
+
+		for _, machineDeployment := range machineDeploymentList.Items {
+			_, isE2E := machineDeployment.Labels[capiutil.E2ENodepool]
+			if isE2E || machineDeployment.DeletionTimestamp != nil {
+				continue
+			}
+
+			if name := machineDeployment.Labels[capi.ClusterTopologyMachineDeploymentNameLabel]; !workers[name] {
+				c.Fatalf("MachineDeployment %q: topology %q it was generated for is not in the Cluster topology", machineDeployment.Name, name)
+			}
+		}
+	}
+}
+
+func testMachinePool(t *testing.T, ctx context.Context, logger micrologger.Logger, cpCtrlClient ctrl.Client, cluster *capi.Cluster, machinePool *capiexp.MachinePool, operatorVersionLabel string) {
+	c := assert.NewCollector(t, assert.SoftMode)
+
+	// Metadata checks.
+	{
+		assert.LabelIsSet(c, machinePool, label.MachinePool)
+		assert.LabelIsSet(c, machinePool, label.ReleaseVersion)
+		assert.LabelIsEqual(c, cluster, machinePool, label.ReleaseVersion)
+		if operatorVersionLabel != "" {
+			assert.LabelIsSet(c, machinePool, operatorVersionLabel)
+			assert.LabelIsEqual(c, cluster, machinePool, operatorVersionLabel)
+		}
+		assert.AnnotationIsSet(c, machinePool, annotation.LastDeployedReleaseVersion)
+		assert.AnnotationIsEqual(c, cluster, machinePool, annotation.LastDeployedReleaseVersion)
+		assert.AnnotationIsSet(c, machinePool, annotation.NodePoolMinSize)
+		assert.AnnotationIsSet(c, machinePool, annotation.NodePoolMaxSize)
+		assert.ExpectedOwnerReferenceIsSet(c, machinePool, cluster)
+	}
+
+	// Status checks.
+	{
+		capiutil.WaitForCondition(t, ctx, logger, cpCtrlClient, machinePool, capi.ReadyCondition, capiconditions.IsTrue)
+		capiutil.WaitForCondition(t, ctx, logger, cpCtrlClient, machinePool, capiutil.CreatingCondition, capiconditions.IsFalse)
+		capiutil.WaitForCondition(t, ctx, logger, cpCtrlClient, machinePool, capiutil.UpgradingCondition, capiconditions.IsFalse)
+		assertConditionNeverReturned(c, "MachinePool", machinePool, string(capiutil.UpgradingCondition), corev1.ConditionTrue)
+
+		minReplicas, minOK := parseReplicasAnnotation(c, machinePool, annotation.NodePoolMinSize)
+		maxReplicas, maxOK := parseReplicasAnnotation(c, machinePool, annotation.NodePoolMaxSize)
+		if minOK && maxOK && (machinePool.Status.Replicas < minReplicas || machinePool.Status.Replicas > maxReplicas) {
+			c.Fatalf("MachinePool %q: expected Status.Replicas to be between %d and %d, got %d", machinePool.Name, minReplicas, maxReplicas, machinePool.Status.Replicas)
+		}
+
+		if machinePool.Status.Replicas != machinePool.Status.ReadyReplicas {
+			c.Fatalf("MachinePool %q: expected Status.ReadyReplicas to be %d, got %d", machinePool.Name, machinePool.Status.Replicas, machinePool.Status.ReadyReplicas)
+		}
+
+		for _, conditionType := range []capi.ConditionType{capi.InfrastructureReadyCondition, capiutil.ReplicasReadyCondition} {
+			if status := capiutil.GetCondition(machinePool, conditionType); status != corev1.ConditionTrue {
+				c.Fatalf("MachinePool %q: expected condition %q to have status %q, got %q", machinePool.Name, conditionType, corev1.ConditionTrue, status)
+			}
+		}
+	}
+}
+
+func testMachineDeployment(t *testing.T, ctx context.Context, logger micrologger.Logger, cpCtrlClient ctrl.Client, cluster *capi.Cluster, machineDeployment *capi.MachineDeployment) {
+	c := assert.NewCollector(t, assert.SoftMode)
+
+	// Metadata checks.
+	{
+		assert.LabelIsSet(c, machineDeployment, capi.ClusterNameLabel)
+		assert.ExpectedOwnerReferenceIsSet(c, machineDeployment, cluster)
+	}
+
+	// Status checks.
+	{
+		capiutil.WaitForCondition(t, ctx, logger, cpCtrlClient, machineDeployment, capi.MachineDeploymentAvailableCondition, capiconditions.IsTrue)
+
+		if machineDeployment.Status.Phase != string(capi.MachineDeploymentPhaseRunning) {
+			c.Fatalf("MachineDeployment %q: expected Status.Phase to be %q, got %q", machineDeployment.Name, capi.MachineDeploymentPhaseRunning, machineDeployment.Status.Phase)
+		}
+
+		var replicas int32
+		if machineDeployment.Spec.Replicas != nil {
+			replicas = *machineDeployment.Spec.Replicas
+		}
+
+		if machineDeployment.Status.ReadyReplicas != replicas {
+			c.Fatalf("MachineDeployment %q: expected Status.ReadyReplicas to be %d, got %d", machineDeployment.Name, replicas, machineDeployment.Status.ReadyReplicas)
+		}
+
+		if machineDeployment.Status.UpdatedReplicas != replicas {
+			c.Fatalf("MachineDeployment %q: expected Status.UpdatedReplicas to be %d, got %d", machineDeployment.Name, replicas, machineDeployment.Status.UpdatedReplicas)
+		}
+
+		if machineDeployment.Status.UnavailableReplicas != 0 {
+			c.Fatalf("MachineDeployment %q: expected Status.UnavailableReplicas to be 0, got %d", machineDeployment.Name, machineDeployment.Status.UnavailableReplicas)
+		}
+
+		machineSets, err := capiutil.FindMachineSetsForMachineDeployment(ctx, cpCtrlClient, machineDeployment)
+		if err != nil {
+			t.Fatalf("error finding MachineSets for MachineDeployment %q: %s", machineDeployment.Name, microerror.JSON(err))
+		}
+
+		var activeMachineSets int
+		for i := range machineSets {
+			machineSet := &machineSets[i]
+			setGVK(t, machineSet)
+
+			assert.ExpectedOwnerReferenceIsSet(c, machineSet, machineDeployment)
+
+			if machineSet.Spec.Replicas != nil && *machineSet.Spec.Replicas > 0 {
+				activeMachineSets++
+
+				if machineSet.Status.ReadyReplicas != *machineSet.Spec.Replicas {
+					c.Fatalf("MachineSet %q: expected Status.ReadyReplicas to be %d, got %d", machineSet.Name, *machineSet.Spec.Replicas, machineSet.Status.ReadyReplicas)
+				}
+			}
+		}
+
+		if replicas > 0 && activeMachineSets != 1 {
+			c.Fatalf("MachineDeployment %q: expected 1 MachineSet with replicas (rollout completed), got %d", machineDeployment.Name, activeMachineSets)
+		}
+
+		machines, err := capiutil.FindMachinesForMachineDeployment(ctx, cpCtrlClient, machineDeployment)
+		if err != nil {
+			t.Fatalf("error finding Machines for MachineDeployment %q: %s", machineDeployment.Name, microerror.JSON(err))
+		}
+
+		if int32(len(machines)) != replicas {
+			c.Fatalf("MachineDeployment %q: expected %d Machines, got %d", machineDeployment.Name, replicas, len(machines))
+		}
+	}
+}
+
+func testKubeadmControlPlane(t *testing.T, ctx context.Context, logger micrologger.Logger, cpCtrlClient ctrl.Client, cluster *capi.Cluster, kubeadmControlPlane *kcp.KubeadmControlPlane) {
+	c := assert.NewCollector(t, assert.SoftMode)
+
+	// Metadata checks.
+	{
+		assert.ExpectedOwnerReferenceIsSet(c, kubeadmControlPlane, cluster)
+	}
+
+	// Status checks.
+	{
+		capiutil.WaitForCondition(t, ctx, logger, cpCtrlClient, kubeadmControlPlane, capi.ReadyCondition, capiconditions.IsTrue)
+
+		if !kubeadmControlPlane.Status.Initialized {
+			c.Fatalf("KubeadmControlPlane %q: expected Status.Initialized to be true", kubeadmControlPlane.Name)
+		}
+
+		if !kubeadmControlPlane.Status.Ready {
+			c.Fatalf("KubeadmControlPlane %q: expected Status.Ready to be true", kubeadmControlPlane.Name)
+		}
+
+		var replicas int32
+		if kubeadmControlPlane.Spec.Replicas != nil {
+			replicas = *kubeadmControlPlane.Spec.Replicas
+		}
+
+		if kubeadmControlPlane.Status.ReadyReplicas != replicas {
+			c.Fatalf("KubeadmControlPlane %q: expected Status.ReadyReplicas to be %d, got %d", kubeadmControlPlane.Name, replicas, kubeadmControlPlane.Status.ReadyReplicas)
+		}
+
+		if kubeadmControlPlane.Status.UpdatedReplicas != replicas {
+			c.Fatalf("KubeadmControlPlane %q: expected Status.UpdatedReplicas to be %d, got %d", kubeadmControlPlane.Name, replicas, kubeadmControlPlane.Status.UpdatedReplicas)
+		}
+
+		if kubeadmControlPlane.Status.Version == nil || *kubeadmControlPlane.Status.Version != kubeadmControlPlane.Spec.Version {
+			c.Fatalf("KubeadmControlPlane %q: expected Status.Version to be %q", kubeadmControlPlane.Name, kubeadmControlPlane.Spec.Version)
+		}
+
+		for _, conditionType := range []capi.ConditionType{kcp.AvailableCondition, kcp.MachinesReadyCondition, kcp.ControlPlaneComponentsHealthyCondition, kcp.EtcdClusterHealthyCondition} {
+			if status := capiutil.GetCondition(kubeadmControlPlane, conditionType); status != corev1.ConditionTrue {
+				c.Fatalf("KubeadmControlPlane %q: expected condition %q to have status %q, got %q", kubeadmControlPlane.Name, conditionType, corev1.ConditionTrue, status)
+			}
+		}
+
+		machines, err := capiutil.FindControlPlaneMachinesForCluster(ctx, cpCtrlClient, cluster.Name)
+		if err != nil {
+			t.Fatalf("error finding control plane Machines for cluster %q: %s", cluster.Name, microerror.JSON(err))
+		}
+
+		if int32(len(machines)) != replicas {
+			c.Fatalf("KubeadmControlPlane %q: expected %d control plane Machines, got %d", kubeadmControlPlane.Name, replicas, len(machines))
+		}
+	}
+}
+
+func testMachine(t *testing.T, ctx context.Context, logger micrologger.Logger, cpCtrlClient ctrl.Client, tcCtrlClient ctrl.Client, machine *capi.Machine) {
+	c := assert.NewCollector(t, assert.SoftMode)
+
+	// Metadata checks.
+	{
+		assert.LabelIsSet(c, machine, capi.ClusterNameLabel)
+	}
+
+	// Spec checks.
+	{
+		if machine.Spec.ProviderID == nil || *machine.Spec.ProviderID == "" {
+			c.Fatalf("Machine %q: expected Spec.ProviderID to be set", machine.Name)
+		}
+	}
+
+	// Status checks.
+	{
+		capiutil.WaitForCondition(t, ctx, logger, cpCtrlClient, machine, capi.ReadyCondition, capiconditions.IsTrue)
+
+		if machine.Status.Phase != string(capi.MachinePhaseRunning) {
+			c.Fatalf("Machine %q: expected Status.Phase to be %q, got %q", machine.Name, capi.MachinePhaseRunning, machine.Status.Phase)
+		}
+
+		if status := capiutil.GetCondition(machine, capi.MachineNodeHealthyCondition); status != corev1.ConditionTrue {
+			c.Fatalf("Machine %q: expected condition %q to have status %q, got %q", machine.Name, capi.MachineNodeHealthyCondition, corev1.ConditionTrue, status)
+		}
+	}
+
+	// Node checks.
+	{
+		node, err := capiutil.FindNodeForMachine(ctx, tcCtrlClient, machine)
+		if err != nil {
+			c.Fatalf("Machine %q: cannot resolve Status.NodeRef: %s", machine.Name, microerror.JSON(err))
+			return
+		}
+
+		if machine.Spec.ProviderID != nil && node.Spec.ProviderID != *machine.Spec.ProviderID {
+			c.Fatalf("Machine %q: expected Node %q to have provider ID %q, got %q", machine.Name, node.Name, *machine.Spec.ProviderID, node.Spec.ProviderID)
+		}
+
+		ready := false
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady {
+				ready = condition.Status == corev1.ConditionTrue
+			}
+		}
+		if !ready {
+			c.Fatalf("Machine %q: expected Node %q to be Ready", machine.Name, node.Name)
+		}
+	}
+}
+
+func testMachineHealthCheck(t *testing.T, ctx context.Context, logger micrologger.Logger, cpCtrlClient ctrl.Client, machineHealthCheck *capi.MachineHealthCheck) {
+	// Status checks.
+	{
+		capiutil.WaitForCondition(t, ctx, logger, cpCtrlClient, machineHealthCheck, capi.RemediationAllowedCondition, capiconditions.IsTrue)
+
+		// Machines are unhealthy until their Node joins, e.g. right after a
+		// rolling update.
+		healthy := func(ctrl.Object) (bool, string) {
+			return machineHealthCheck.Status.CurrentHealthy == machineHealthCheck.Status.ExpectedMachines, fmt.Sprintf("%d of %d machines are healthy", machineHealthCheck.Status.CurrentHealthy, machineHealthCheck.Status.ExpectedMachines)
+		}
+		capiutil.WaitForPredicate(t, ctx, logger, cpCtrlClient, machineHealthCheck, "have all machines healthy", healthy)
+	}
+}
+
+func testAzureCluster(t *testing.T, ctx context.Context, logger micrologger.Logger, cpCtrlClient ctrl.Client, cluster *capi.Cluster, azureCluster *capz.AzureCluster, operatorVersionLabel string) {
+	c := assert.NewCollector(t, assert.SoftMode)
+
+	// Metadata checks.
+	{
+		assert.LabelIsSet(c, azureCluster, label.ReleaseVersion)
+		assert.LabelIsEqual(c, cluster, azureCluster, label.ReleaseVersion)
+		if operatorVersionLabel != "" {
+			assert.LabelIsSet(c, azureCluster, operatorVersionLabel)
+			assert.LabelIsEqual(c, cluster, azureCluster, operatorVersionLabel)
+		}
+		assert.ExpectedOwnerReferenceIsSet(c, azureCluster, cluster)
+	}
+
+	// Spec checks.
+	{
+		if len(azureCluster.Spec.NetworkSpec.Vnet.CIDRBlocks) != 1 {
+			c.Fatalf("AzureCluster %q: expected 1 VNet CIDR block, got %d", azureCluster.Name, len(azureCluster.Spec.NetworkSpec.Vnet.CIDRBlocks))
+		}
+
+		machinePools, err := capiutil.FindNonTestingMachinePoolsForCluster(ctx, cpCtrlClient, cluster.Name)
+		if err != nil {
+			t.Fatalf("error finding MachinePools for cluster %q: %s", cluster.Name, microerror.JSON(err))
+		}
+
+		if len(azureCluster.Spec.NetworkSpec.Subnets) != len(machinePools) {
+			c.Fatalf("AzureCluster %q: expected %d subnets (one for each MachinePool), got %d", azureCluster.Name, len(machinePools), len(azureCluster.Spec.NetworkSpec.Subnets))
+		}
+
+		machinePoolNames := map[string]bool{}
+		for _, machinePool := range machinePools {
+			machinePoolNames[machinePool.Name] = true
+		}
+
+		for _, subnet := range azureCluster.Spec.NetworkSpec.Subnets {
+			if !machinePoolNames[subnet.Name] {
+				c.Fatalf("AzureCluster %q: subnet %q does not match the name of any MachinePool", azureCluster.Name, subnet.Name)
+			}
+
+			if len(subnet.CIDRBlocks) != 1 {
+				c.Fatalf("AzureCluster %q: expected subnet %q to have 1 CIDR block, got %d", azureCluster.Name, subnet.Name, len(subnet.CIDRBlocks))
+			}
+		}
+	}
+
+	// Status checks.
+	{
+		capiutil.WaitForCondition(t, ctx, logger, cpCtrlClient, azureCluster, capi.ReadyCondition, capiconditions.IsTrue)
+
+		if !azureCluster.Status.Ready {
+			c.Fatalf("AzureCluster %q: expected Status.Ready to be true", azureCluster.Name)
+		}
+	}
+}
+
+func testAzureMachinePool(t *testing.T, ctx context.Context, logger micrologger.Logger, cpCtrlClient ctrl.Client, cluster *capi.Cluster, azureMachinePool *capzexp.AzureMachinePool, operatorVersionLabel string) {
+	c := assert.NewCollector(t, assert.SoftMode)
+
+	machinePool, err := capiutil.FindMachinePool(ctx, cpCtrlClient, azureMachinePool.Labels[label.MachinePool])
+	if err != nil {
+		t.Fatalf("error finding MachinePool for AzureMachinePool %q: %s", azureMachinePool.Name, microerror.JSON(err))
+	}
+	setGVK(t, machinePool)
+
+	// Metadata checks.
+	{
+		assert.LabelIsSet(c, azureMachinePool, label.MachinePool)
+		assert.LabelIsSet(c, azureMachinePool, label.ReleaseVersion)
+		assert.LabelIsEqual(c, cluster, azureMachinePool, label.ReleaseVersion)
+		if operatorVersionLabel != "" {
+			assert.LabelIsSet(c, azureMachinePool, operatorVersionLabel)
+			assert.LabelIsEqual(c, cluster, azureMachinePool, operatorVersionLabel)
+		}
+		assert.LabelIsEqual(c, machinePool, azureMachinePool, label.MachinePool)
+		assert.ExpectedOwnerReferenceIsSet(c, azureMachinePool, machinePool)
+	}
+
+	// Spec checks.
+	{
+		if azureMachinePool.Spec.ProviderID == "" {
+			c.Fatalf("AzureMachinePool %q: expected Spec.ProviderID to be set", azureMachinePool.Name)
+		}
+
+		if int32(len(azureMachinePool.Spec.ProviderIDList)) != machinePool.Status.Replicas {
+			c.Fatalf("AzureMachinePool %q: expected Spec.ProviderIDList to have %d IDs, got %d", azureMachinePool.Name, machinePool.Status.Replicas, len(azureMachinePool.Spec.ProviderIDList))
+		}
+	}
+
+	// Status checks.
+	{
+		capiutil.WaitForCondition(t, ctx, logger, cpCtrlClient, azureMachinePool, capi.ReadyCondition, capiconditions.IsTrue)
+
+		if azureMachinePool.Status.Replicas != machinePool.Status.Replicas {
+			c.Fatalf("AzureMachinePool %q: expected Status.Replicas to be %d (to match MachinePool), got %d", azureMachinePool.Name, machinePool.Status.Replicas, azureMachinePool.Status.Replicas)
+		}
+
+		if azureMachinePool.Status.ProvisioningState == nil || *azureMachinePool.Status.ProvisioningState != capz.Succeeded {
+			c.Fatalf("AzureMachinePool %q: expected Status.ProvisioningState to be %q", azureMachinePool.Name, capz.Succeeded)
+		}
+
+		if !azureMachinePool.Status.Ready {
+			c.Fatalf("AzureMachinePool %q: expected Status.Ready to be true", azureMachinePool.Name)
+		}
+	}
+}
+
+// getOperatorVersionLabel returns the label holding the version of the
+// provider specific operator reconciling the cluster, or an empty string when
+// the provider does not have one.
+func getOperatorVersionLabel() string {
+	switch provider.GetProvider() {
+	case "azure":
+		return label.AzureOperatorVersion
+	case "aws":
+		return label.AWSOperatorVersion
+	}
+
+	return ""
+}
+
+func parseReplicasAnnotation(t assert.TestingT, machinePool *capiexp.MachinePool, key string) (int32, bool) {
+	value, err := strconv.ParseInt(machinePool.GetAnnotations()[key], 10, 32)
+	if err != nil {
+		t.Fatalf("MachinePool %q: cannot parse annotation %q: %v", machinePool.Name, key, err)
+		return 0, false
+	}
+
+	return int32(value), true
+}
+
+// setGVK sets the GroupVersionKind of obj, which the controller-runtime client
+// strips from typed objects, so that the assertions can print the object kind
+// and match owner references.
+func setGVK(t *testing.T, obj runtime.Object) {
+	gvk, err := apiutil.GVKForObject(obj, ctrlclient.Scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+}
@@ -2,6 +2,7 @@ package ingress
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -19,6 +20,7 @@ import (
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/provider"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
 )
 
 func Test_AzureDelete(t *testing.T) {
@@ -36,17 +38,9 @@ func Test_AzureDelete(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	providerName, exists := os.LookupEnv(provider.ProviderEnvVarName)
-	if !exists {
-		t.Fatalf("missing %s environment variable", provider.ProviderEnvVarName)
-	}
-
-	if providerName != "azure" {
-		logger.Debugf(ctx, "Only Azure provider is supported by this test, skipping")
-		return
-	}
+	provider.SkipUnlessSupported(t, provider.DeletionVerification)
 
-	cpCtrlClient, err := ctrlclient.CreateCPCtrlClient()
+	cpCtrlClient, err := ctrlclient.ForTest(t).CPCtrlClient()
 	if err != nil {
 		t.Fatalf("error creating CP k8s client: %v", err)
 	}
@@ -95,22 +89,23 @@ func Test_AzureDelete(t *testing.T) {
 	// Wait for Cluster CR to be deleted.
 	{
 		logger.Debugf(ctx, "Waiting for cluster CR for cluster %s to be deleted", clusterID)
-		o := func() error {
-			clusters := &capi.ClusterList{}
-			err := cpCtrlClient.List(ctx, clusters, ctrl.MatchingLabels{capi.ClusterNameLabel: clusterID})
-			if err != nil {
-				return microerror.Mask(err)
-			}
 
-			if len(clusters.Items) > 0 {
-				return microerror.Maskf(customResourceStillExistsError, "Cluster CR for cluster %s still exists (%d found)", clusterID, len(clusters.Items))
-			}
+		waiter, err := wait.New(wait.Config{
+			Logger:   logger,
+			Client:   cpCtrlClient,
+			Interval: backoff.LongMaxInterval,
+			Timeout:  60 * time.Minute,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
 
-			return nil
+		clusters := &capi.ClusterList{}
+		deleted := func(ctrl.ObjectList) (bool, string) {
+			return len(clusters.Items) == 0, fmt.Sprintf("%d Cluster CRs found", len(clusters.Items))
 		}
-		b := backoff.NewConstant(60*time.Minute, backoff.LongMaxInterval)
-		n := backoff.NewNotifier(logger, ctx)
-		err = backoff.RetryNotify(o, b, n)
+
+		err = waiter.UntilList(ctx, clusters, "be deleted", deleted, ctrl.MatchingLabels{capi.ClusterNameLabel: clusterID})
 		if err != nil {
 			t.Fatalf("Failed waiting for Cluster CR to be deleted: %v", err)
 		}
@@ -121,21 +116,26 @@ func Test_AzureDelete(t *testing.T) {
 	{
 		logger.Debugf(ctx, "Waiting for resource group %s to be deleted", clusterID)
 
-		o := func() error {
+		waiter, err := wait.New(wait.Config{
+			Logger:   logger,
+			Client:   cpCtrlClient,
+			Interval: backoff.ShortMaxInterval,
+			Timeout:  backoff.MediumMaxWait,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		check := func(ctx context.Context) (bool, string, error) {
 			exists, err := azureClient.ResourceGroup.Exists(ctx, clusterID)
 			if err != nil {
-				return microerror.Maskf(executionFailedError, "Error checking if the resource group exists: %v", err)
-			}
-
-			if exists {
-				return microerror.Maskf(executionFailedError, "Resource group still exists: %v", err)
+				return false, fmt.Sprintf("Error checking if the resource group exists: %v", err), nil
 			}
 
-			return nil
+			return !exists, "Resource group still exists", nil
 		}
-		b := backoff.NewConstant(backoff.MediumMaxWait, backoff.ShortMaxInterval)
-		n := backoff.NewNotifier(logger, ctx)
-		err = backoff.RetryNotify(o, b, n)
+
+		err = waiter.Until(ctx, fmt.Sprintf("resource group %s", clusterID), "be deleted", check)
 		if err != nil {
 			t.Fatalf("Failed waiting for Resource Group to be deleted: %v", err)
 		}
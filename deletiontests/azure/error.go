@@ -16,7 +16,3 @@ var notFoundError = &microerror.Error{
 func IsNotFound(err error) bool {
 	return microerror.Cause(err) == notFoundError
 }
-
-var customResourceStillExistsError = &microerror.Error{
-	Kind: "customResourceStillExistsError",
-}
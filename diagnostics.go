@@ -0,0 +1,38 @@
+package sonobuoy_plugin
+
+import (
+	"testing"
+
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/apputil"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
+)
+
+// newAppDiagnostics returns the collector writing the diagnostics of the
+// failed apps of t into the Sonobuoy results directory.
+func newAppDiagnostics(t *testing.T, logger micrologger.Logger) *apputil.Diagnostics {
+	cpClients, err := ctrlclient.ForTest(t).CP()
+	if err != nil {
+		t.Fatalf("error creating CP k8s clients: %v", err)
+	}
+
+	tcClients, err := ctrlclient.ForTest(t).TC()
+	if err != nil {
+		t.Fatalf("error creating TC k8s clients: %v", err)
+	}
+
+	diagnostics, err := apputil.NewDiagnostics(apputil.DiagnosticsConfig{
+		Logger:       logger,
+		CPCtrlClient: cpClients.CtrlClient,
+		CPClientset:  cpClients.Clientset,
+		TCCtrlClient: tcClients.CtrlClient,
+		TCClientset:  tcClients.Clientset,
+		Dir:          apputil.DiagnosticsDir(t.Name()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return diagnostics
+}
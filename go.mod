@@ -17,11 +17,14 @@ require (
 	github.com/giantswarm/micrologger v1.1.1
 	github.com/google/go-github/v45 v45.2.0
 	github.com/kyverno/kyverno v1.9.5
+	github.com/xeipuuv/gojsonschema v1.2.0
+	golang.org/x/net v0.15.0
 	golang.org/x/oauth2 v0.12.0
 	k8s.io/api v0.26.2
 	k8s.io/apiextensions-apiserver v0.26.1
 	k8s.io/apimachinery v0.26.2
 	k8s.io/client-go v0.26.2
+	k8s.io/utils v0.0.0-20230220204549-a5ecb0141aa5
 	sigs.k8s.io/cluster-api v1.4.6
 	sigs.k8s.io/cluster-api-provider-azure v1.9.8
 	sigs.k8s.io/controller-runtime v0.14.5
@@ -243,7 +246,6 @@ require (
 	golang.org/x/crypto v0.15.0 // indirect
 	golang.org/x/exp v0.0.0-20221230185412-738e83a70c30 // indirect
 	golang.org/x/mod v0.10.0 // indirect
-	golang.org/x/net v0.15.0 // indirect
 	golang.org/x/sync v0.3.0 // indirect
 	golang.org/x/sys v0.14.0 // indirect
 	golang.org/x/term v0.14.0 // indirect
@@ -267,7 +269,6 @@ require (
 	k8s.io/kube-openapi v0.0.0-20221207184640-f3cff1453715 // indirect
 	k8s.io/kubectl v0.26.1 // indirect
 	k8s.io/pod-security-admission v0.26.0 // indirect
-	k8s.io/utils v0.0.0-20230220204549-a5ecb0141aa5 // indirect
 	sigs.k8s.io/json v0.0.0-20221116044647-bc3834ca7abd // indirect
 	sigs.k8s.io/kustomize/api v0.12.1 // indirect
 	sigs.k8s.io/kustomize/kyaml v0.13.9 // indirect
@@ -0,0 +1,104 @@
+package sonobuoy_plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/apputil"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/assert"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/timeline"
+)
+
+const conditionTimelineFile = "condition-timeline.txt"
+
+// conditionTimeline records the condition transitions of the Cluster API
+// objects of the tested cluster while the tests run. It is nil when the
+// recorder could not be started, e.g. when replaying API fixtures.
+var conditionTimeline *timeline.Recorder
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error creating logger: %s\n", err)
+		os.Exit(1)
+	}
+
+	conditionTimeline, err = startConditionTimeline(ctx, logger)
+	if err != nil {
+		logger.Debugf(ctx, "Not recording the condition timeline: %s", microerror.JSON(err))
+	}
+
+	code := m.Run()
+
+	if conditionTimeline != nil {
+		conditionTimeline.Stop()
+
+		path := filepath.Join(apputil.ResultsDir(), conditionTimelineFile)
+		err = conditionTimeline.WriteFile(path)
+		if err != nil {
+			logger.Debugf(ctx, "Error writing the condition timeline: %s", microerror.JSON(err))
+		} else {
+			logger.Debugf(ctx, "Condition timeline written to %s", path)
+		}
+	}
+
+	os.Exit(code)
+}
+
+func startConditionTimeline(ctx context.Context, logger micrologger.Logger) (*timeline.Recorder, error) {
+	cpCtrlClient, err := ctrlclient.CreateCPCtrlClient()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	watchClient, ok := cpCtrlClient.(ctrl.WithWatch)
+	if !ok {
+		return nil, microerror.Maskf(executionFailedError, "CP client %T cannot watch", cpCtrlClient)
+	}
+
+	recorder, err := timeline.NewRecorder(timeline.RecorderConfig{
+		Logger:    logger,
+		Client:    watchClient,
+		Kinds:     capiutil.TimelineKinds,
+		ClusterID: os.Getenv("CLUSTER_ID"),
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	recorder.Start(ctx)
+
+	return recorder, nil
+}
+
+// assertConditionNeverReturned fails when the recorded condition of obj
+// changed to status after having had another status, e.g. when Upgrading
+// flipped back to True once the cluster was up. Nothing is checked when the
+// timeline is not recorded.
+func assertConditionNeverReturned(c *assert.Collector, kind string, obj ctrl.Object, conditionType string, status corev1.ConditionStatus) {
+	if conditionTimeline == nil {
+		return
+	}
+
+	left := false
+	for _, t := range conditionTimeline.Transitions(kind, obj.GetNamespace(), obj.GetName(), conditionType) {
+		if t.Status != string(status) {
+			left = true
+		} else if left {
+			c.Fatalf("%s %q: condition %q changed back to %s at %s, reason %q", kind, obj.GetName(), conditionType, status, t.Time.UTC().Format("15:04:05"), t.Reason)
+			return
+		}
+	}
+}
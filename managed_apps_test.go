@@ -2,19 +2,28 @@ package sonobuoy_plugin
 
 import (
 	"context"
-	"fmt"
 	"os"
-	"strings"
 	"sync"
 	"testing"
 
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/apputil"
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/provider"
 )
 
-// Test_ManagedApps test the main giantswarm managed apps install successfully
+const (
+	managedAppsConfigEnvVarName = "MANAGED_APPS_CONFIG"
+	defaultManagedAppsConfig    = "managed-apps.yaml"
+)
+
+// Test_ManagedApps tests the managed apps listed in $MANAGED_APPS_CONFIG
+// (defaults to managed-apps.yaml) can be installed in their previous version,
+// upgraded to the latest one and uninstalled without leaving anything behind.
+// Every team becomes a subtest and every app a subtest of its team.
 func Test_ManagedApps(t *testing.T) {
 	t.Parallel()
 
@@ -22,11 +31,16 @@ func Test_ManagedApps(t *testing.T) {
 
 	ctx := context.Background()
 
-	cpCtrlClient, err := ctrlclient.CreateCPCtrlClient()
+	cpCtrlClient, err := ctrlclient.ForTest(t).CPCtrlClient()
 	if err != nil {
 		t.Fatalf("error creating CP k8s client: %v", err)
 	}
 
+	tcCtrlClient, err := ctrlclient.ForTest(t).TCCtrlClient()
+	if err != nil {
+		t.Fatalf("error creating TC k8s client: %v", err)
+	}
+
 	regularLogger, err := micrologger.New(micrologger.Config{})
 	if err != nil {
 		t.Fatal(err)
@@ -39,82 +53,78 @@ func Test_ManagedApps(t *testing.T) {
 		t.Fatal("missing CLUSTER_ID environment variable")
 	}
 
-	apps := map[string][]apputil.AppConfig{
-		"phoenix": {
-			apputil.AppConfig{Name: "aws-load-balancer-controller"},
-			apputil.AppConfig{Name: "karpenter"},
-			apputil.AppConfig{Name: "aws-efs-csi-driver"},
-		},
-		"cabbage": {
-			apputil.AppConfig{Name: "kong-app"},
-			// apputil.AppConfig{Name: "ingress-nginx"}, // tested as part of the "ingress" test.
-			// apputil.AppConfig{Name: "cloudflared"}, // failed (requires custom value)
-		},
-		"teddyfriends": {
-			apputil.AppConfig{Name: "k8s-initiator-app", Catalog: "giantswarm-playground"}, // PSS failing
-		},
-		"atlas": {
-			apputil.AppConfig{Name: "fluent-logshipping-app"},
-			apputil.AppConfig{Name: "keda"},
-			apputil.AppConfig{Name: "grafana"},
-			apputil.AppConfig{Name: "loki"},
-			apputil.AppConfig{Name: "datadog"},
-		},
-		"shield": {
-			apputil.AppConfig{Name: "starboard-exporter"},
-		},
-		"honeybadger": {
-			// apputil.AppConfig{Name: "flux-app" }, // failed PSS
-			// apputil.AppConfig{Name: "external-secrets"}, // failed PSS
-		},
-		"bigmac": {
-			apputil.AppConfig{Name: "athena"},
-			apputil.AppConfig{Name: "dex-app"},
-			apputil.AppConfig{Name: "rbac-bootstrap"},
-		},
+	path := os.Getenv(managedAppsConfigEnvVarName)
+	if path == "" {
+		path = defaultManagedAppsConfig
 	}
 
-	failed := make([]string, 0)
-
-	var wg sync.WaitGroup
-	var mutex sync.Mutex
-
-	markFailed := func(msg string) {
-		mutex.Lock()
-		failed = append(failed, msg)
-		mutex.Unlock()
+	matrix, err := apputil.LoadMatrix(path)
+	if err != nil {
+		t.Fatalf("error loading managed apps from %q: %s", path, microerror.JSON(err))
 	}
 
-	for team, teamApps := range apps {
-		for _, appCfg := range teamApps {
-			wg.Add(1)
-			go func(appCfg apputil.AppConfig, team string) {
-				defer wg.Done()
-				app, err := apputil.GetApp(clusterID, appCfg)
-				if err != nil {
-					logger.Debugf(ctx, "[%s] Error getting CR for app %q: %s", team, appCfg.Name, err)
-					markFailed(fmt.Sprintf("%s (team %s)", appCfg.Name, team))
-					return
-				}
-
-				err = apputil.InstallAndWait(ctx, logger, cpCtrlClient, app)
-				if err != nil {
-					markFailed(fmt.Sprintf("%s (team %s)", app.Name, team))
-				}
-
-				// Delete app.
-				err = cpCtrlClient.Delete(ctx, app)
-				if err != nil && !IsNotFound(err) {
-					logger.Debugf(ctx, "[%s] Error deleting app %q: %s", team, app.Name, err)
-				}
-			}(appCfg, team)
-		}
+	before, err := apputil.TakeSnapshot(ctx, tcCtrlClient)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	wg.Wait()
+	installed := make([]*appv1alpha1.App, 0)
+	var mutex sync.Mutex
 
-	if len(failed) > 0 {
-		logger.Debugf(ctx, fmt.Sprintf("%d apps failed deploying: %s", len(failed), strings.Join(failed, ", ")))
-		t.Fatalf("%d apps failed deploying", len(failed))
+	// Cleanup functions run once all the parallel subtests are done, so the
+	// leftovers of all apps are checked at once.
+	t.Cleanup(func() {
+		err := apputil.WaitForNoLeftovers(ctx, logger, tcCtrlClient, before, installed)
+		if err != nil {
+			t.Errorf("uninstalled apps left objects behind: %s", err)
+		}
+	})
+
+	for _, team := range matrix.Teams() {
+		team := team
+		t.Run(team, func(t *testing.T) {
+			t.Parallel()
+
+			for _, entry := range matrix.TeamApps(team) {
+				entry := entry
+				t.Run(entry.Name, func(t *testing.T) {
+					t.Parallel()
+
+					if !entry.AppliesTo(provider.GetProvider()) {
+						t.Skipf("app %s is not tested on provider %q", entry, provider.GetProvider())
+					}
+					if entry.KnownFailure != "" {
+						t.Skipf("app %s is known to fail: %s", entry, entry.KnownFailure)
+					}
+					if missing := entry.MissingEnv(); len(missing) > 0 {
+						t.Skipf("app %s requires the env vars %v", entry, missing)
+					}
+
+					logger := NewTestLogger(regularLogger, t)
+
+					lifecycle, err := apputil.NewLifecycle(apputil.LifecycleConfig{
+						Logger:       logger,
+						CPCtrlClient: cpCtrlClient,
+						TCCtrlClient: tcCtrlClient,
+						ClusterID:    clusterID,
+						AppConfig:    entry.AppConfig(),
+						Diagnostics:  newAppDiagnostics(t, logger),
+					})
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					app, err := lifecycle.Run(ctx)
+					if app != nil {
+						mutex.Lock()
+						installed = append(installed, app)
+						mutex.Unlock()
+					}
+					if err != nil {
+						t.Fatalf("lifecycle of app %s failed: %s", entry, err)
+					}
+				})
+			}
+		})
 	}
 }
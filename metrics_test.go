@@ -2,9 +2,7 @@ package sonobuoy_plugin
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/url"
 	"os"
 	"testing"
 	"time"
@@ -15,12 +13,14 @@ import (
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
-	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/podrunner"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/promclient"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
 )
 
 func Test_Metrics(t *testing.T) {
@@ -30,7 +30,7 @@ func Test_Metrics(t *testing.T) {
 
 	ctx := context.Background()
 
-	cpCtrlClient, err := ctrlclient.CreateCPCtrlClient()
+	cpCtrlClient, err := ctrlclient.ForTest(t).CPCtrlClient()
 	if err != nil {
 		t.Fatalf("error creating CP k8s client: %v", err)
 	}
@@ -75,27 +75,28 @@ func Test_Metrics(t *testing.T) {
 	}
 
 	namespace := fmt.Sprintf("%s-prometheus", clusterID)
-	podName := fmt.Sprintf("prometheus-%s-0", clusterID)
 
 	logger.Debugf(ctx, "Waiting for prometheus namespace %q to exist", namespace)
 
+	waiter, err := wait.New(wait.Config{
+		Logger:   logger,
+		Client:   cpCtrlClient,
+		Interval: 1 * time.Minute,
+		Timeout:  backoff.LongMaxWait,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// Wait for prometheus namespace to exist.
 	{
-		o := func() error {
-			ns := &corev1.Namespace{}
-			err = cpCtrlClient.Get(ctx, client.ObjectKey{Name: namespace}, ns)
-			if err != nil {
-				return microerror.Mask(err)
-			}
-
-			return nil
+		exists := func(client.Object) (bool, string) {
+			return true, ""
 		}
 
-		b := backoff.NewConstant(backoff.LongMaxWait, 1*time.Minute)
-		n := backoff.NewNotifier(logger, ctx)
-		err = backoff.RetryNotify(o, b, n)
+		err = waiter.UntilPredicate(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, "exist", exists)
 		if err != nil {
-			t.Fatalf("Error waiting for prometheus namespace to exist.")
+			t.Fatalf("Error waiting for prometheus namespace to exist: %s", microerror.JSON(err))
 		}
 	}
 
@@ -135,72 +136,53 @@ func Test_Metrics(t *testing.T) {
 		"coredns_dns_request_duration_seconds_bucket",
 	}
 
+	cpClients, err := ctrlclient.ForTest(t).CP()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	promClient, err := promclient.NewServiceProxy(cpClients.RESTConfig, namespace, "prometheus-operated", 9090, "/"+clusterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		queries = append(queries, fmt.Sprintf("absent(%s) or vector(0)", metric))
+	}
+
 	// Wait for all queries to be compliant with expectations.
 	{
-		o := func() error {
-			kc, err := ctrlclient.GetCPKubeConfig()
+		check := func(ctx context.Context) (bool, string, error) {
+			results, err := promClient.QueryBatch(ctx, queries, time.Time{})
 			if err != nil {
-				t.Fatal(err)
+				return false, fmt.Sprintf("cannot run queries: %s", err), nil
 			}
 
-			for _, metric := range metrics {
-				query := fmt.Sprintf("absent(%s) or vector(0)", metric)
-				stdout, _, err := podrunner.ExecInPod(ctx, logger, podName, namespace, "prometheus", []string{"wget", "-q", "-O-", fmt.Sprintf("prometheus-operated.%s-prometheus:9090/%s/api/v1/query?query=%s", clusterID, clusterID, url.QueryEscape(query))}, kc)
-				if err != nil {
-					return microerror.Maskf(podExecError, "Can't exec command in pod %s: %s.", podName, err)
-				}
-
-				// {"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1681718763.145,"1"]}]}}
+			for i, result := range results {
+				query := queries[i]
 
-				type result struct {
-					Value []any
+				if result.ResultType != promclient.ResultTypeVector {
+					return false, fmt.Sprintf("Unexpected response type %s when running query %q (wanted vector)", result.ResultType, query), nil
 				}
 
-				response := struct {
-					Status string
-					Data   struct {
-						ResultType string
-						Result     []result
-					}
-				}{}
-
-				err = json.Unmarshal([]byte(stdout), &response)
-				if err != nil {
-					return microerror.Maskf(unexpectedAnswerError, "Can't parse prometheus query output: %s", err)
+				if len(result.Vector) != 1 {
+					return false, fmt.Sprintf("Unexpected count of results when running query %q (wanted 1, got %d)", query, len(result.Vector)), nil
 				}
 
-				if response.Status != "success" {
-					return microerror.Maskf(prometheusQueryError, "Unexpected response status %s when running query %q", response.Status, query)
-				}
-
-				if response.Data.ResultType != "vector" {
-					return microerror.Maskf(prometheusQueryError, "Unexpected response type %s when running query %q (wanted vector)", response.Status, query)
-				}
-
-				if len(response.Data.Result) != 1 {
-					return microerror.Maskf(prometheusQueryError, "Unexpected count of results when running query %q (wanted 1, got %d)", query, len(response.Data.Result))
-				}
-
-				// Second field of first result is the metric value. [1681718763.145,"1"] => "1"
-				str, ok := (response.Data.Result[0].Value[1]).(string)
-				if !ok {
-					return microerror.Maskf(prometheusQueryError, "Cannot cast result value to string when running query %q", query)
-				}
-				if str != "0" {
-					return microerror.Maskf(prometheusQueryError, "Unexpected value for query %q (wanted '0', got %q)", query, str)
+				if value := result.Vector[0].Value.Value; value != 0 {
+					return false, fmt.Sprintf("Unexpected value for query %q (wanted 0, got %v)", query, value), nil
 				}
 
-				logger.Debugf(ctx, "Metric %q was found", metric)
+				logger.Debugf(ctx, "Metric %q was found", metrics[i])
 			}
 
-			return nil
+			return true, "", nil
 		}
 
-		b := backoff.NewConstant(backoff.LongMaxWait, 1*time.Minute)
-		n := backoff.NewNotifier(logger, ctx)
-		err = backoff.RetryNotify(o, b, n)
+		err = waiter.Until(ctx, "prometheus metrics", "be present", check)
 		if err != nil {
-			t.Fatalf("Error waiting for prometheus metrics to be present.")
+			t.Fatalf("Error waiting for prometheus metrics to be present: %s", microerror.JSON(err))
 		}
 	}
 }
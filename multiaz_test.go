@@ -23,6 +23,8 @@ import (
 func Test_AvailabilityZones(t *testing.T) {
 	t.Parallel()
 
+	provider.SkipUnlessSupported(t, provider.NodePools, provider.AvailabilityZones)
+
 	var err error
 
 	ctx := context.Background()
@@ -34,7 +36,7 @@ func Test_AvailabilityZones(t *testing.T) {
 
 	logger := NewTestLogger(regularLogger, t)
 
-	cpCtrlClient, err := ctrlclient.CreateCPCtrlClient()
+	cpCtrlClient, err := ctrlclient.ForTest(t).CPCtrlClient()
 	if err != nil {
 		t.Fatalf("error creating CP k8s client: %v", err)
 	}
@@ -54,8 +56,17 @@ func Test_AvailabilityZones(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	machinePoolObjectKey, err := providerSupport.CreateNodePoolAndWaitReady(ctx, cpCtrlClient, cluster, providerSupport.GetProviderAZs(), false)
-	if err != nil {
+	azs := providerSupport.GetProviderAZs()
+	if len(azs) == 0 {
+		t.Fatalf("provider %q reports no availability zones", provider.GetProvider())
+	}
+
+	machinePoolObjectKey, err := providerSupport.CreateNodePoolAndWaitReady(ctx, cpCtrlClient, cluster, provider.NodePoolSpec{
+		AvailabilityZones: azs,
+	})
+	if provider.IsUnsupportedNodePoolSpec(err) {
+		t.Skipf("node pools of the cluster can't be spread over %d availability zones: %s", len(azs), microerror.JSON(err))
+	} else if err != nil {
 		t.Fatal(err)
 	}
 
@@ -76,6 +87,10 @@ func Test_AvailabilityZones(t *testing.T) {
 	sort.Strings(actualZones)
 	sort.Strings(k8sZones)
 
+	if len(k8sZones) == 0 {
+		t.Fatalf("The node pool has no AZs set, expected %s", azs)
+	}
+
 	if !reflect.DeepEqual(actualZones, k8sZones) {
 		t.Fatalf("The AZs used are not correct. Expected %s, got %s", k8sZones, actualZones)
 	}
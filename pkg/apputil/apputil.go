@@ -9,7 +9,6 @@ import (
 	"time"
 
 	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
-	"github.com/giantswarm/backoff"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	"github.com/google/go-github/v45/github"
@@ -17,56 +16,120 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
+)
+
+const (
+	defaultCatalog = "giantswarm"
 )
 
 type AppConfig struct {
-	Catalog    string
-	Name       string
-	Namespace  string
+	Catalog   string
+	Name      string
+	Namespace string
+	// ValuesYAML is the YAML of the user values stored in a ConfigMap, see
+	// CreateUserConfig.
 	ValuesYAML string
+	// SecretValuesYAML is the YAML of the user values stored in a Secret,
+	// e.g. credentials.
+	SecretValuesYAML string
+	// Version is a semver range the installed version must match, e.g.
+	// ">=2.0.0 <3.0.0". The latest version is used when empty.
+	Version string
+	// PreRelease allows resolving Version to a pre-release.
+	PreRelease bool
+	// ExtraConfigs are merged into the values of the app according to their
+	// priority, next to the psp-removal-patch ConfigMap unless
+	// SkipPSPRemovalPatch is set.
+	ExtraConfigs        []ExtraConfig
+	SkipPSPRemovalPatch bool
+	// ClusterValues makes the app use the cluster-values ConfigMap and Secret
+	// of the cluster as its config.
+	ClusterValues bool
+	// InCluster installs the app in the control plane instead of the
+	// workload cluster.
+	InCluster bool
+	// KubeConfigSecret is the name of the Secret in the cluster namespace
+	// holding the kubeconfig of the workload cluster. app-operator resolves
+	// the kubeconfig on its own when empty. It must be empty when InCluster
+	// is set.
+	KubeConfigSecret string
+	// KubeConfigContext is the name of the context of the kubeconfig in
+	// KubeConfigSecret, <cluster>-admin@<cluster> as in the kubeconfigs
+	// generated by Cluster API by default.
+	KubeConfigContext string
 }
 
-func InstallAndWait(ctx context.Context, logger micrologger.Logger, ctrlClient client.Client, app *appv1alpha1.App) error {
-	err := ctrlClient.Create(ctx, app)
+// InstallAndWait validates the values of the app against the chart schema,
+// creates the App CR and waits for it to be deployed. When the app is not
+// deployed in time and diagnostics is not nil, the diagnostics of the app are
+// collected before returning the error.
+func InstallAndWait(ctx context.Context, logger micrologger.Logger, ctrlClient client.Client, app *appv1alpha1.App, diagnostics *Diagnostics) error {
+	validator, err := NewSchemaValidator(SchemaValidatorConfig{
+		Logger:     logger,
+		CtrlClient: ctrlClient,
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = validator.Validate(ctx, app)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = ctrlClient.Create(ctx, app)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
-	check := func(ok client.ObjectKey) error {
-		app := appv1alpha1.App{}
-		err = ctrlClient.Get(ctx, ok, &app)
+	waiter, err := wait.New(wait.Config{
+		Logger:  logger,
+		Client:  ctrlClient,
+		Timeout: 10 * time.Minute,
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	check := func(ctx context.Context) (bool, string, error) {
+		current := appv1alpha1.App{}
+		err := ctrlClient.Get(ctx, client.ObjectKeyFromObject(app), &current)
 		if err != nil {
-			logger.Debugf(ctx, "Error creating App CR for %q: %s", app.Name, err)
-			return microerror.Mask(err)
+			return false, fmt.Sprintf("cannot get App: %s", err), nil
 		}
 
-		switch app.Status.Release.Status {
-		case "failed":
-			return microerror.Maskf(appFailedError, "App %q with version %q is in failed state: %s", app.Name, app.Spec.Version, app.Status.Release.Reason)
+		switch current.Status.Release.Status {
 		case "deployed":
-			logger.Debugf(ctx, "App %q with version %s deployed correctly.", app.Name, app.Spec.Version)
-
-			// Delete app.
-			err = ctrlClient.Delete(ctx, &app)
-			if err != nil {
-				logger.Debugf(ctx, "Error deleting app %q: %s", app.Name, err)
-			}
+			return true, "", nil
+		case "failed":
+			// app-operator and chart-operator often recover from failed.
+			return false, fmt.Sprintf("version %q is in failed state: %s", app.Spec.Version, current.Status.Release.Reason), nil
 		default:
-			return microerror.Maskf(appNotReadyError, "App %q with version %q is still in state %q.", app.Name, app.Spec.Version, app.Status.Release.Status)
+			return false, fmt.Sprintf("version %q is in state %q", app.Spec.Version, current.Status.Release.Status), nil
 		}
-		return nil
 	}
 
-	b := backoff.NewConstant(10*time.Minute, 30*time.Second)
-	n := backoff.NewNotifier(logger, ctx)
-	err = backoff.RetryNotify(func() error {
-		return check(client.ObjectKey{Name: app.Name, Namespace: app.Namespace})
-	}, b, n)
+	err = waiter.Until(ctx, fmt.Sprintf("App %s", client.ObjectKeyFromObject(app)), "be deployed", check)
 	if err != nil {
 		logger.Debugf(ctx, "Installation of %q app failed", app.Name)
+		if diagnostics != nil {
+			_, diagErr := diagnostics.Collect(ctx, app)
+			if diagErr != nil {
+				logger.Debugf(ctx, "Error collecting diagnostics of app %q: %s", app.Name, diagErr)
+			}
+		}
 		return microerror.Mask(err)
 	}
 
+	logger.Debugf(ctx, "App %q with version %s deployed correctly.", app.Name, app.Spec.Version)
+
+	err = ctrlClient.Delete(ctx, app)
+	if err != nil {
+		logger.Debugf(ctx, "Error deleting app %q: %s", app.Name, err)
+	}
+
 	return nil
 }
 
@@ -92,28 +155,87 @@ func CreateAppConfigCM(ctx context.Context, logger micrologger.Logger, ctrlClien
 	return &cm, nil
 }
 
-func GetApp(clusterID string, appCfg AppConfig) (*appv1alpha1.App, error) {
-	version, err := getLatestGithubRelease("giantswarm", appCfg.Name)
+func CreateAppConfigSecret(ctx context.Context, logger micrologger.Logger, ctrlClient client.Client, clusterID string, appCfg AppConfig) (*v1.Secret, error) {
+	secret := v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getSecretName(appCfg),
+			Namespace: clusterID,
+			Labels: map[string]string{
+				"giantswarm.io/cluster": clusterID,
+			},
+		},
+		StringData: map[string]string{
+			"values": appCfg.SecretValuesYAML,
+		},
+	}
+
+	err := ctrlClient.Create(ctx, &secret)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &secret, nil
+}
+
+// CreateUserConfig creates the ConfigMap and the Secret holding the user
+// values of appCfg, if it has any, and returns the created objects.
+func CreateUserConfig(ctx context.Context, logger micrologger.Logger, ctrlClient client.Client, clusterID string, appCfg AppConfig) ([]client.Object, error) {
+	var objects []client.Object
+
+	if appCfg.ValuesYAML != "" {
+		cm, err := CreateAppConfigCM(ctx, logger, ctrlClient, clusterID, appCfg)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		objects = append(objects, cm)
+	}
+
+	if appCfg.SecretValuesYAML != "" {
+		secret, err := CreateAppConfigSecret(ctx, logger, ctrlClient, clusterID, appCfg)
+		if err != nil {
+			return objects, microerror.Mask(err)
+		}
+		objects = append(objects, secret)
+	}
+
+	return objects, nil
+}
+
+// GetApp returns the App CR installing appCfg in the given cluster. The
+// version is resolved from the catalog known to ctrlClient, see
+// VersionResolver.
+func GetApp(ctx context.Context, ctrlClient client.Client, clusterID string, appCfg AppConfig) (*appv1alpha1.App, error) {
+	err := appCfg.validate()
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
+	resolver, err := NewVersionResolver(VersionResolverConfig{
+		CtrlClient:     ctrlClient,
+		GithubFallback: githubFallbackFromEnv(),
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	version, err := resolver.Resolve(ctx, appCfg)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return newApp(clusterID, appCfg, version), nil
+}
+
+func newApp(clusterID string, appCfg AppConfig, version string) *appv1alpha1.App {
 	if appCfg.Catalog == "" {
-		appCfg.Catalog = "giantswarm"
+		appCfg.Catalog = defaultCatalog
 	}
 
 	if appCfg.Namespace == "" {
 		appCfg.Namespace = appCfg.Name
 	}
 
-	var userConfigName string
-	var userConfigNamespace string
-	if appCfg.ValuesYAML != "" {
-		userConfigName = getCmName(appCfg)
-		userConfigNamespace = clusterID
-	}
-
-	return &appv1alpha1.App{
+	app := &appv1alpha1.App{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      appCfg.Name,
 			Namespace: clusterID,
@@ -122,26 +244,62 @@ func GetApp(clusterID string, appCfg AppConfig) (*appv1alpha1.App, error) {
 			},
 		},
 		Spec: appv1alpha1.AppSpec{
-			Catalog: appCfg.Catalog,
-			ExtraConfigs: []appv1alpha1.AppExtraConfig{
-				{
-					Kind:      "configMap",
-					Name:      "psp-removal-patch",
-					Namespace: clusterID,
-					Priority:  150,
-				},
+			Catalog:      appCfg.Catalog,
+			ExtraConfigs: extraConfigs(clusterID, appCfg),
+			Name:         appCfg.Name,
+			Namespace:    appCfg.Namespace,
+			Version:      version,
+		},
+	}
+
+	if appCfg.ValuesYAML != "" {
+		app.Spec.UserConfig.ConfigMap = appv1alpha1.AppSpecUserConfigConfigMap{
+			Name:      getCmName(appCfg),
+			Namespace: clusterID,
+		}
+	}
+	if appCfg.SecretValuesYAML != "" {
+		app.Spec.UserConfig.Secret = appv1alpha1.AppSpecUserConfigSecret{
+			Name:      getSecretName(appCfg),
+			Namespace: clusterID,
+		}
+	}
+
+	if appCfg.ClusterValues {
+		app.Spec.Config = appv1alpha1.AppSpecConfig{
+			ConfigMap: appv1alpha1.AppSpecConfigConfigMap{
+				Name:      clusterValuesName(clusterID),
+				Namespace: clusterID,
 			},
-			UserConfig: appv1alpha1.AppSpecUserConfig{
-				ConfigMap: appv1alpha1.AppSpecUserConfigConfigMap{
-					Name:      userConfigName,
-					Namespace: userConfigNamespace,
-				},
+			Secret: appv1alpha1.AppSpecConfigSecret{
+				Name:      clusterValuesName(clusterID),
+				Namespace: clusterID,
 			},
-			Name:      appCfg.Name,
-			Namespace: appCfg.Namespace,
-			Version:   version,
-		},
-	}, nil
+		}
+	}
+
+	if appCfg.InCluster {
+		app.Spec.KubeConfig = appv1alpha1.AppSpecKubeConfig{
+			InCluster: true,
+		}
+	} else if appCfg.KubeConfigSecret != "" {
+		kubeConfigContext := appCfg.KubeConfigContext
+		if kubeConfigContext == "" {
+			kubeConfigContext = fmt.Sprintf("%s-admin@%s", clusterID, clusterID)
+		}
+
+		app.Spec.KubeConfig = appv1alpha1.AppSpecKubeConfig{
+			Context: appv1alpha1.AppSpecKubeConfigContext{
+				Name: kubeConfigContext,
+			},
+			Secret: appv1alpha1.AppSpecKubeConfigSecret{
+				Name:      appCfg.KubeConfigSecret,
+				Namespace: clusterID,
+			},
+		}
+	}
+
+	return app
 }
 
 func getLatestGithubRelease(owner string, name string) (string, error) {
@@ -192,3 +350,7 @@ func getLatestGithubRelease(owner string, name string) (string, error) {
 func getCmName(appCfg AppConfig) string {
 	return fmt.Sprintf("%s-user-values", appCfg.Name)
 }
+
+func getSecretName(appCfg AppConfig) string {
+	return fmt.Sprintf("%s-user-secrets", appCfg.Name)
+}
@@ -0,0 +1,303 @@
+package apputil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/ghodss/yaml"
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// GithubFallbackEnvVar enables looking up the latest GitHub release of
+	// apps the catalogs do not know when set to "true".
+	GithubFallbackEnvVar = "APP_VERSION_GITHUB_FALLBACK"
+
+	labelAppName = "app.kubernetes.io/name"
+)
+
+type VersionResolverConfig struct {
+	// CtrlClient is a client of the cluster holding the Catalog and
+	// AppCatalogEntry CRs.
+	CtrlClient client.Client
+	HTTPClient *http.Client
+	// GithubFallback makes Resolve use the latest GitHub release of the app
+	// when no catalog version matches.
+	GithubFallback bool
+}
+
+// VersionResolver resolves the version of an app from the index.yaml of its
+// catalog, falling back to the AppCatalogEntry CRs and optionally to GitHub.
+type VersionResolver struct {
+	ctrlClient     client.Client
+	httpClient     *http.Client
+	githubFallback bool
+}
+
+func NewVersionResolver(config VersionResolverConfig) (*VersionResolver, error) {
+	if config.CtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CtrlClient must not be empty", config)
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+
+	r := &VersionResolver{
+		ctrlClient:     config.CtrlClient,
+		httpClient:     config.HTTPClient,
+		githubFallback: config.GithubFallback,
+	}
+
+	return r, nil
+}
+
+// Resolve returns the highest version of the app in appCfg.Catalog matching
+// appCfg.Version. Pre-releases are only considered when appCfg.PreRelease is
+// set.
+func (r *VersionResolver) Resolve(ctx context.Context, appCfg AppConfig) (string, error) {
+	versions, err := r.Versions(ctx, appCfg)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return versions[0], nil
+}
+
+// Versions returns the versions of the app matching appCfg.Version, highest
+// first, from the first source knowing any of them.
+func (r *VersionResolver) Versions(ctx context.Context, appCfg AppConfig) ([]string, error) {
+	if appCfg.Catalog == "" {
+		appCfg.Catalog = defaultCatalog
+	}
+
+	versionRange := func(semver.Version) bool { return true }
+	if appCfg.Version != "" {
+		var err error
+		versionRange, err = semver.ParseRange(appCfg.Version)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "cannot parse version range %q of app %q: %s", appCfg.Version, appCfg.Name, err)
+		}
+	}
+
+	match := func(versions []string) []string {
+		return matchingVersions(versions, versionRange, appCfg.PreRelease)
+	}
+
+	var errs []string
+
+	versions, err := r.indexVersions(ctx, appCfg.Catalog, appCfg.Name)
+	if err != nil {
+		errs = append(errs, err.Error())
+	} else if matched := match(versions); len(matched) > 0 {
+		return matched, nil
+	}
+
+	versions, err = r.entryVersions(ctx, appCfg.Catalog, appCfg.Name)
+	if err != nil {
+		errs = append(errs, err.Error())
+	} else if matched := match(versions); len(matched) > 0 {
+		return matched, nil
+	}
+
+	if r.githubFallback {
+		v, err := getLatestGithubRelease("giantswarm", appCfg.Name)
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else if matched := match([]string{v}); len(matched) > 0 {
+			return matched, nil
+		}
+	}
+
+	msg := fmt.Sprintf("no version of app %q in catalog %q matches %q", appCfg.Name, appCfg.Catalog, appCfg.Version)
+	if len(errs) > 0 {
+		msg += fmt.Sprintf(" (%s)", strings.Join(errs, "; "))
+	}
+
+	return nil, microerror.Maskf(versionNotFoundError, "%s", msg)
+}
+
+// indexVersions returns the versions of the app listed in the index.yaml of
+// the catalog repositories.
+func (r *VersionResolver) indexVersions(ctx context.Context, catalogName string, appName string) ([]string, error) {
+	catalog, err := getCatalog(ctx, r.ctrlClient, catalogName)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	_, index, err := getCatalogIndex(ctx, r.httpClient, catalog)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var versions []string
+	for _, entry := range index.Entries[appName] {
+		versions = append(versions, entry.Version)
+	}
+
+	return versions, nil
+}
+
+type catalogIndex struct {
+	Entries map[string][]catalogIndexEntry `json:"entries"`
+}
+
+type catalogIndexEntry struct {
+	Version string `json:"version"`
+	// URLs are the URLs of the chart tarball, possibly relative to the
+	// repository URL.
+	URLs []string `json:"urls"`
+}
+
+func getCatalog(ctx context.Context, ctrlClient client.Client, catalogName string) (*appv1alpha1.Catalog, error) {
+	var catalogs appv1alpha1.CatalogList
+	err := ctrlClient.List(ctx, &catalogs)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for _, catalog := range catalogs.Items {
+		if catalog.Name == catalogName {
+			return &catalog, nil
+		}
+	}
+
+	return nil, microerror.Maskf(notFoundError, "catalog %q not found", catalogName)
+}
+
+// getCatalogIndex returns the index.yaml of the first repository of the
+// catalog that serves one, along with the URL of that repository.
+func getCatalogIndex(ctx context.Context, httpClient *http.Client, catalog *appv1alpha1.Catalog) (string, *catalogIndex, error) {
+	var urls []string
+	for _, repository := range catalog.Spec.Repositories {
+		urls = append(urls, repository.URL)
+	}
+	if len(urls) == 0 && catalog.Spec.Storage.URL != "" {
+		urls = append(urls, catalog.Spec.Storage.URL)
+	}
+
+	if len(urls) == 0 {
+		return "", nil, microerror.Maskf(notFoundError, "catalog %q has no repositories", catalog.Name)
+	}
+
+	var lastErr error
+	for _, u := range urls {
+		index, err := getIndex(ctx, httpClient, u)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		return u, index, nil
+	}
+
+	return "", nil, microerror.Mask(lastErr)
+}
+
+func getIndex(ctx context.Context, httpClient *http.Client, repositoryURL string) (*catalogIndex, error) {
+	u := strings.TrimSuffix(repositoryURL, "/") + "/index.yaml"
+
+	b, err := httpGet(ctx, httpClient, u)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var index catalogIndex
+	err = yaml.Unmarshal(b, &index)
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "cannot parse %s: %s", u, err)
+	}
+
+	return &index, nil
+}
+
+func httpGet(ctx context.Context, httpClient *http.Client, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, microerror.Maskf(executionFailedError, "GET %s returned status %d", u, res.StatusCode)
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return b, nil
+}
+
+// entryVersions returns the versions of the app in the AppCatalogEntry CRs of
+// the catalog.
+func (r *VersionResolver) entryVersions(ctx context.Context, catalogName string, appName string) ([]string, error) {
+	var entries appv1alpha1.AppCatalogEntryList
+	err := r.ctrlClient.List(ctx, &entries, client.MatchingLabels{labelAppName: appName})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var versions []string
+	for _, entry := range entries.Items {
+		if entry.Spec.Catalog.Name == catalogName && entry.Spec.AppName == appName {
+			versions = append(versions, entry.Spec.Version)
+		}
+	}
+
+	return versions, nil
+}
+
+// matchingVersions returns the versions within versionRange, highest first.
+// Versions that are not valid semver are ignored.
+func matchingVersions(versions []string, versionRange semver.Range, preRelease bool) []string {
+	type candidate struct {
+		raw     string
+		version semver.Version
+	}
+
+	var candidates []candidate
+	for _, raw := range versions {
+		v, err := semver.ParseTolerant(raw)
+		if err != nil {
+			continue
+		}
+		if len(v.Pre) > 0 && !preRelease {
+			continue
+		}
+		if !versionRange(v) {
+			continue
+		}
+
+		candidates = append(candidates, candidate{raw: raw, version: v})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].version.GT(candidates[j].version)
+	})
+
+	matched := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		// Catalogs list versions without the "v" prefix GitHub releases use.
+		matched = append(matched, strings.TrimPrefix(c.raw, "v"))
+	}
+
+	return matched
+}
+
+func githubFallbackFromEnv() bool {
+	return os.Getenv(GithubFallbackEnvVar) == "true"
+}
@@ -0,0 +1,137 @@
+package apputil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const testIndex = `apiVersion: v1
+entries:
+  kong-app:
+  - name: kong-app
+    version: 2.3.0
+  - name: kong-app
+    version: 3.0.0-rc.1
+  - name: kong-app
+    version: 2.10.1
+  - name: kong-app
+    version: 1.9.0
+  - name: kong-app
+    version: not-a-version
+`
+
+func newTestResolver(t *testing.T, objects ...client.Object) *VersionResolver {
+	t.Helper()
+
+	server := newTestCatalogServer(t, map[string][]byte{
+		"/giantswarm-catalog/index.yaml": []byte(testIndex),
+	})
+
+	objects = append(objects,
+		newTestCatalog("giantswarm", server.URL+"/giantswarm-catalog/"),
+		// Its index.yaml is not served, so its versions come from AppCatalogEntries.
+		&appv1alpha1.Catalog{
+			ObjectMeta: metav1.ObjectMeta{Name: "broken", Namespace: "default"},
+			Spec: appv1alpha1.CatalogSpec{
+				Storage: appv1alpha1.CatalogSpecStorage{Type: "helm", URL: server.URL + "/broken-catalog/"},
+			},
+		},
+	)
+
+	r, err := NewVersionResolver(VersionResolverConfig{
+		CtrlClient: newFakeClient(t, objects...),
+		HTTPClient: server.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func newTestEntry(catalog string, app string, version string) *appv1alpha1.AppCatalogEntry {
+	return &appv1alpha1.AppCatalogEntry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s-%s", catalog, app, version),
+			Namespace: "default",
+			Labels:    map[string]string{labelAppName: app},
+		},
+		Spec: appv1alpha1.AppCatalogEntrySpec{
+			AppName: app,
+			Catalog: appv1alpha1.AppCatalogEntrySpecCatalog{Name: catalog},
+			Version: version,
+		},
+	}
+}
+
+func Test_VersionResolver_Resolve(t *testing.T) {
+	testCases := []struct {
+		name            string
+		appCfg          AppConfig
+		expectedVersion string
+		expectedErr     func(error) bool
+	}{
+		{
+			name:            "case 0: latest release from index.yaml",
+			appCfg:          AppConfig{Name: "kong-app"},
+			expectedVersion: "2.10.1",
+		},
+		{
+			name:            "case 1: latest release matching range",
+			appCfg:          AppConfig{Name: "kong-app", Version: ">=2.0.0 <2.5.0"},
+			expectedVersion: "2.3.0",
+		},
+		{
+			name:            "case 2: pre-release allowed",
+			appCfg:          AppConfig{Name: "kong-app", PreRelease: true},
+			expectedVersion: "3.0.0-rc.1",
+		},
+		{
+			name:        "case 3: no version matching range",
+			appCfg:      AppConfig{Name: "kong-app", Version: ">=4.0.0"},
+			expectedErr: IsVersionNotFound,
+		},
+		{
+			name:            "case 4: fall back to AppCatalogEntry CRs when index.yaml is unavailable",
+			appCfg:          AppConfig{Name: "hello-world", Catalog: "broken"},
+			expectedVersion: "0.3.1",
+		},
+		{
+			name:        "case 5: unknown app",
+			appCfg:      AppConfig{Name: "unknown"},
+			expectedErr: IsVersionNotFound,
+		},
+	}
+
+	r := newTestResolver(t,
+		newTestEntry("broken", "hello-world", "0.2.0"),
+		newTestEntry("broken", "hello-world", "0.3.1"),
+		newTestEntry("giantswarm", "hello-world", "1.0.0"),
+	)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			version, err := r.Resolve(context.Background(), tc.appCfg)
+
+			switch {
+			case err == nil && tc.expectedErr == nil:
+				// correct; carry on
+			case err != nil && tc.expectedErr == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.expectedErr != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.expectedErr(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if version != tc.expectedVersion {
+				t.Fatalf("version == %q, want %q", version, tc.expectedVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package apputil
+
+import (
+	"fmt"
+
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	ExtraConfigKindConfigMap = "configMap"
+	ExtraConfigKindSecret    = "secret"
+
+	// Priorities of extra configs range from 1 to 150. Extra configs with a
+	// priority above 100 override the user config.
+	minExtraConfigPriority     = 1
+	maxExtraConfigPriority     = 150
+	defaultExtraConfigPriority = 25
+
+	pspRemovalPatchName = "psp-removal-patch"
+)
+
+// ExtraConfig references a ConfigMap or Secret merged into the values of an
+// app. See https://github.com/giantswarm/rfc/tree/main/multi-layer-app-config.
+type ExtraConfig struct {
+	// Kind is either "configMap" or "secret". Defaults to "configMap".
+	Kind string `json:"kind,omitempty"`
+	Name string `json:"name"`
+	// Namespace defaults to the cluster namespace.
+	Namespace string `json:"namespace,omitempty"`
+	// Priority defines the order the extra configs are merged in. Defaults
+	// to 25.
+	Priority int `json:"priority,omitempty"`
+}
+
+func (c AppConfig) validate() error {
+	if c.Name == "" {
+		return microerror.Maskf(invalidConfigError, "%T.Name must not be empty", c)
+	}
+	if c.InCluster && c.KubeConfigSecret != "" {
+		return microerror.Maskf(invalidConfigError, "%T.KubeConfigSecret of app %q must be empty when %T.InCluster is set", c, c.Name, c)
+	}
+
+	for _, extraConfig := range c.ExtraConfigs {
+		if extraConfig.Name == "" {
+			return microerror.Maskf(invalidConfigError, "extra config of app %q has no name", c.Name)
+		}
+
+		switch extraConfig.Kind {
+		case "", ExtraConfigKindConfigMap, ExtraConfigKindSecret:
+		default:
+			return microerror.Maskf(invalidConfigError, "extra config %q of app %q has kind %q, want %q or %q", extraConfig.Name, c.Name, extraConfig.Kind, ExtraConfigKindConfigMap, ExtraConfigKindSecret)
+		}
+
+		if extraConfig.Priority != 0 && (extraConfig.Priority < minExtraConfigPriority || extraConfig.Priority > maxExtraConfigPriority) {
+			return microerror.Maskf(invalidConfigError, "extra config %q of app %q has priority %d, want a priority between %d and %d", extraConfig.Name, c.Name, extraConfig.Priority, minExtraConfigPriority, maxExtraConfigPriority)
+		}
+	}
+
+	return nil
+}
+
+// extraConfigs returns the extra configs of the App CR installing appCfg,
+// with their defaults applied.
+func extraConfigs(clusterID string, appCfg AppConfig) []appv1alpha1.AppExtraConfig {
+	var extraConfigs []appv1alpha1.AppExtraConfig
+
+	if !appCfg.SkipPSPRemovalPatch {
+		extraConfigs = append(extraConfigs, appv1alpha1.AppExtraConfig{
+			Kind:      ExtraConfigKindConfigMap,
+			Name:      pspRemovalPatchName,
+			Namespace: clusterID,
+			Priority:  maxExtraConfigPriority,
+		})
+	}
+
+	for _, c := range appCfg.ExtraConfigs {
+		extraConfig := appv1alpha1.AppExtraConfig{
+			Kind:      c.Kind,
+			Name:      c.Name,
+			Namespace: c.Namespace,
+			Priority:  c.Priority,
+		}
+		if extraConfig.Kind == "" {
+			extraConfig.Kind = ExtraConfigKindConfigMap
+		}
+		if extraConfig.Namespace == "" {
+			extraConfig.Namespace = clusterID
+		}
+		if extraConfig.Priority == 0 {
+			extraConfig.Priority = defaultExtraConfigPriority
+		}
+
+		extraConfigs = append(extraConfigs, extraConfig)
+	}
+
+	return extraConfigs
+}
+
+func clusterValuesName(clusterID string) string {
+	return fmt.Sprintf("%s-cluster-values", clusterID)
+}
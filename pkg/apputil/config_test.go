@@ -0,0 +1,105 @@
+package apputil
+
+import (
+	"reflect"
+	"testing"
+
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+)
+
+func Test_AppConfig_validate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		appCfg      AppConfig
+		expectedErr bool
+	}{
+		{
+			name: "case 0: valid config",
+			appCfg: AppConfig{
+				Name:         "datadog",
+				ExtraConfigs: []ExtraConfig{{Kind: ExtraConfigKindSecret, Name: "datadog-credentials", Priority: 100}},
+			},
+		},
+		{
+			name:        "case 1: missing name",
+			appCfg:      AppConfig{},
+			expectedErr: true,
+		},
+		{
+			name:        "case 2: in-cluster app with kubeconfig secret",
+			appCfg:      AppConfig{Name: "cloudflared", InCluster: true, KubeConfigSecret: "abc12-kubeconfig"},
+			expectedErr: true,
+		},
+		{
+			name:        "case 3: unknown extra config kind",
+			appCfg:      AppConfig{Name: "datadog", ExtraConfigs: []ExtraConfig{{Kind: "file", Name: "datadog-overrides"}}},
+			expectedErr: true,
+		},
+		{
+			name:        "case 4: priority out of range",
+			appCfg:      AppConfig{Name: "datadog", ExtraConfigs: []ExtraConfig{{Name: "datadog-overrides", Priority: 151}}},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.appCfg.validate()
+			if tc.expectedErr {
+				if !IsInvalidConfig(err) {
+					t.Fatalf("error == %#v, want invalidConfigError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func Test_newApp_Config(t *testing.T) {
+	app := newApp("abc12", AppConfig{
+		Name:             "cloudflared",
+		SecretValuesYAML: "token: secret",
+		ExtraConfigs: []ExtraConfig{
+			{Name: "cloudflared-overrides"},
+			{Kind: ExtraConfigKindSecret, Name: "cloudflared-credentials", Namespace: "giantswarm", Priority: 120},
+		},
+		ClusterValues:    true,
+		KubeConfigSecret: "abc12-kubeconfig",
+	}, "0.3.0")
+
+	expectedExtraConfigs := []appv1alpha1.AppExtraConfig{
+		{Kind: ExtraConfigKindConfigMap, Name: pspRemovalPatchName, Namespace: "abc12", Priority: 150},
+		{Kind: ExtraConfigKindConfigMap, Name: "cloudflared-overrides", Namespace: "abc12", Priority: 25},
+		{Kind: ExtraConfigKindSecret, Name: "cloudflared-credentials", Namespace: "giantswarm", Priority: 120},
+	}
+	if !reflect.DeepEqual(app.Spec.ExtraConfigs, expectedExtraConfigs) {
+		t.Fatalf("extra configs == %#v, want %#v", app.Spec.ExtraConfigs, expectedExtraConfigs)
+	}
+
+	if app.Spec.UserConfig.ConfigMap.Name != "" {
+		t.Fatalf("user config map == %q, want none", app.Spec.UserConfig.ConfigMap.Name)
+	}
+	if app.Spec.UserConfig.Secret.Name != "cloudflared-user-secrets" {
+		t.Fatalf("user config secret == %q, want %q", app.Spec.UserConfig.Secret.Name, "cloudflared-user-secrets")
+	}
+	if app.Spec.Config.ConfigMap.Name != "abc12-cluster-values" || app.Spec.Config.Secret.Name != "abc12-cluster-values" {
+		t.Fatalf("config == %#v, want the cluster values", app.Spec.Config)
+	}
+	if app.Spec.KubeConfig.InCluster || app.Spec.KubeConfig.Secret.Name != "abc12-kubeconfig" || app.Spec.KubeConfig.Secret.Namespace != "abc12" {
+		t.Fatalf("kubeconfig == %#v, want secret abc12/abc12-kubeconfig", app.Spec.KubeConfig)
+	}
+	if app.Spec.KubeConfig.Context.Name != "abc12-admin@abc12" {
+		t.Fatalf("kubeconfig context == %q, want %q", app.Spec.KubeConfig.Context.Name, "abc12-admin@abc12")
+	}
+
+	app = newApp("abc12", AppConfig{Name: "cloudflared", SkipPSPRemovalPatch: true, InCluster: true}, "0.3.0")
+	if len(app.Spec.ExtraConfigs) != 0 {
+		t.Fatalf("extra configs == %#v, want none", app.Spec.ExtraConfigs)
+	}
+	if !app.Spec.KubeConfig.InCluster {
+		t.Fatal("expected in-cluster kubeconfig")
+	}
+}
@@ -0,0 +1,292 @@
+package apputil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ResultsDirEnvVar is the directory the Sonobuoy worker collects the
+	// results from.
+	ResultsDirEnvVar  = "RESULTS_DIR"
+	defaultResultsDir = "/tmp/results"
+
+	chartNamespace    = "giantswarm"
+	operatorLogLines  = int64(500)
+	operatorNameLabel = "app.kubernetes.io/name"
+)
+
+type DiagnosticsConfig struct {
+	Logger micrologger.Logger
+	// CPCtrlClient and CPClientset are clients of the control plane running
+	// app-operator and holding the App CRs.
+	CPCtrlClient client.Client
+	CPClientset  kubernetes.Interface
+	// TCCtrlClient and TCClientset are clients of the workload cluster
+	// running chart-operator and holding the Chart CRs.
+	TCCtrlClient client.Client
+	TCClientset  kubernetes.Interface
+	// Dir is the directory the diagnostics are written to, in a subdirectory
+	// per app. See DiagnosticsDir.
+	Dir string
+}
+
+// Diagnostics collects what is needed to debug a failed app install once the
+// clusters are gone.
+type Diagnostics struct {
+	logger       micrologger.Logger
+	cpCtrlClient client.Client
+	cpClientset  kubernetes.Interface
+	tcCtrlClient client.Client
+	tcClientset  kubernetes.Interface
+	dir          string
+}
+
+func NewDiagnostics(config DiagnosticsConfig) (*Diagnostics, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.CPCtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CPCtrlClient must not be empty", config)
+	}
+	if config.CPClientset == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CPClientset must not be empty", config)
+	}
+	if config.TCCtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.TCCtrlClient must not be empty", config)
+	}
+	if config.TCClientset == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.TCClientset must not be empty", config)
+	}
+	if config.Dir == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Dir must not be empty", config)
+	}
+
+	d := &Diagnostics{
+		logger:       config.Logger,
+		cpCtrlClient: config.CPCtrlClient,
+		cpClientset:  config.CPClientset,
+		tcCtrlClient: config.TCCtrlClient,
+		tcClientset:  config.TCClientset,
+		dir:          config.Dir,
+	}
+
+	return d, nil
+}
+
+// ResultsDir returns the Sonobuoy results directory, $RESULTS_DIR.
+func ResultsDir() string {
+	resultsDir := os.Getenv(ResultsDirEnvVar)
+	if resultsDir == "" {
+		resultsDir = defaultResultsDir
+	}
+
+	return resultsDir
+}
+
+// DiagnosticsDir returns the directory holding the diagnostics of the given
+// test inside the Sonobuoy results directory, $RESULTS_DIR/diagnostics/<test>.
+func DiagnosticsDir(testName string) string {
+	return filepath.Join(ResultsDir(), "diagnostics", strings.NewReplacer("/", "_", " ", "_").Replace(testName))
+}
+
+// Collect writes the App CR, the Chart CR, the app-operator and
+// chart-operator logs, the metadata of the Helm release Secrets and the events
+// of the app namespaces into a directory named after the app, and returns
+// that directory. Every item is collected on a best-effort basis: the items
+// that cannot be collected are listed in errors.txt.
+func (d *Diagnostics) Collect(ctx context.Context, app *appv1alpha1.App) (string, error) {
+	dir := filepath.Join(d.dir, app.Name)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	items := []struct {
+		file    string
+		collect func(context.Context, *appv1alpha1.App) ([]byte, error)
+	}{
+		{file: "app.yaml", collect: d.app},
+		{file: "chart.yaml", collect: d.chart},
+		{file: "app-operator.log", collect: d.appOperatorLogs},
+		{file: "chart-operator.log", collect: d.chartOperatorLogs},
+		{file: "helm-releases.yaml", collect: d.helmReleases},
+		{file: "events.txt", collect: d.events},
+	}
+
+	var errs []string
+	for _, item := range items {
+		data, err := item.collect(ctx, app)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", item.file, err))
+			continue
+		}
+
+		err = os.WriteFile(filepath.Join(dir, item.file), data, 0644)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+	}
+
+	if len(errs) > 0 {
+		err = os.WriteFile(filepath.Join(dir, "errors.txt"), []byte(strings.Join(errs, "\n")+"\n"), 0644)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+	}
+
+	d.logger.Debugf(ctx, "Diagnostics of app %q written to %s", app.Name, dir)
+
+	return dir, nil
+}
+
+func (d *Diagnostics) app(ctx context.Context, app *appv1alpha1.App) ([]byte, error) {
+	current := appv1alpha1.App{}
+	err := d.cpCtrlClient.Get(ctx, client.ObjectKeyFromObject(app), &current)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return marshalObject(&current)
+}
+
+func (d *Diagnostics) chart(ctx context.Context, app *appv1alpha1.App) ([]byte, error) {
+	chart := appv1alpha1.Chart{}
+	err := d.tcCtrlClient.Get(ctx, client.ObjectKey{Namespace: chartNamespace, Name: app.Name}, &chart)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return marshalObject(&chart)
+}
+
+// appOperatorLogs returns the logs of the app-operator of the cluster, which
+// runs in the cluster namespace, or of the unique app-operator otherwise.
+func (d *Diagnostics) appOperatorLogs(ctx context.Context, app *appv1alpha1.App) ([]byte, error) {
+	logs, err := podLogs(ctx, d.cpClientset, app.Namespace, "app-operator")
+	if IsNotFound(err) {
+		logs, err = podLogs(ctx, d.cpClientset, chartNamespace, "app-operator")
+	}
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return logs, nil
+}
+
+func (d *Diagnostics) chartOperatorLogs(ctx context.Context, app *appv1alpha1.App) ([]byte, error) {
+	logs, err := podLogs(ctx, d.tcClientset, chartNamespace, "chart-operator")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return logs, nil
+}
+
+// helmReleases returns the metadata of the Helm release Secrets of the app.
+// Their data holds the rendered values and is left out on purpose.
+func (d *Diagnostics) helmReleases(ctx context.Context, app *appv1alpha1.App) ([]byte, error) {
+	secrets := corev1.SecretList{}
+	err := d.tcCtrlClient.List(ctx, &secrets, client.InNamespace(app.Spec.Namespace), client.MatchingLabels{"owner": "helm", "name": app.Name})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	releases := make([]metav1.ObjectMeta, 0, len(secrets.Items))
+	for _, s := range secrets.Items {
+		releases = append(releases, metav1.ObjectMeta{
+			Name:              s.Name,
+			Namespace:         s.Namespace,
+			Labels:            s.Labels,
+			CreationTimestamp: s.CreationTimestamp,
+		})
+	}
+
+	data, err := yaml.Marshal(releases)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return data, nil
+}
+
+// events returns the events of the App CR namespace in the control plane and
+// of the app namespace in the workload cluster, oldest first.
+func (d *Diagnostics) events(ctx context.Context, app *appv1alpha1.App) ([]byte, error) {
+	var b strings.Builder
+
+	for _, source := range []struct {
+		cluster    string
+		ctrlClient client.Client
+		namespace  string
+	}{
+		{cluster: "CP", ctrlClient: d.cpCtrlClient, namespace: app.Namespace},
+		{cluster: "TC", ctrlClient: d.tcCtrlClient, namespace: app.Spec.Namespace},
+	} {
+		events := corev1.EventList{}
+		err := source.ctrlClient.List(ctx, &events, client.InNamespace(source.namespace))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		sort.Slice(events.Items, func(i, j int) bool {
+			return eventTime(events.Items[i]).Before(eventTime(events.Items[j]))
+		})
+
+		fmt.Fprintf(&b, "# %s namespace %s\n", source.cluster, source.namespace)
+		for _, e := range events.Items {
+			fmt.Fprintf(&b, "%s %s %s/%s: %s: %s\n", eventTime(e).UTC().Format("2006-01-02T15:04:05Z"), e.Type, e.InvolvedObject.Kind, e.InvolvedObject.Name, e.Reason, e.Message)
+		}
+	}
+
+	return []byte(b.String()), nil
+}
+
+// podLogs returns the last lines of the logs of the pods labeled with the
+// given app.kubernetes.io/name in namespace.
+func podLogs(ctx context.Context, clientset kubernetes.Interface, namespace string, name string) ([]byte, error) {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", operatorNameLabel, name)})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, microerror.Maskf(notFoundError, "no %s pod in namespace %q", name, namespace)
+	}
+
+	var b strings.Builder
+	for _, pod := range pods.Items {
+		tailLines := operatorLogLines
+		logs, err := clientset.CoreV1().Pods(namespace).GetLogs(pod.Name, &corev1.PodLogOptions{TailLines: &tailLines}).DoRaw(ctx)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		fmt.Fprintf(&b, "# pod %s/%s\n%s\n", namespace, pod.Name, logs)
+	}
+
+	return []byte(b.String()), nil
+}
+
+func marshalObject(o client.Object) ([]byte, error) {
+	o.SetManagedFields(nil)
+
+	data, err := yaml.Marshal(o)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return data, nil
+}
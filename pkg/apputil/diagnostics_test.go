@@ -0,0 +1,101 @@
+package apputil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_Diagnostics_Collect(t *testing.T) {
+	ctx := context.Background()
+
+	app := &appv1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{Name: "keda", Namespace: "abc12"},
+		Spec:       appv1alpha1.AppSpec{Name: "keda", Namespace: "keda", Version: "2.10.1"},
+		Status:     appv1alpha1.AppStatus{Release: appv1alpha1.AppStatusRelease{Status: "failed", Reason: "values don't meet the specifications of the schema"}},
+	}
+
+	cpCtrlClient := newFakeClient(t,
+		app,
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "keda.1", Namespace: "abc12"},
+			InvolvedObject: corev1.ObjectReference{Kind: "App", Name: "keda"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "InstallFailed",
+			Message:        "chart failed",
+		},
+	)
+	tcCtrlClient := newFakeClient(t,
+		&appv1alpha1.Chart{ObjectMeta: metav1.ObjectMeta{Name: "keda", Namespace: "giantswarm"}},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "sh.helm.release.v1.keda.v1",
+				Namespace: "keda",
+				Labels:    map[string]string{"owner": "helm", "name": "keda", "status": "failed", "version": "1"},
+			},
+			Data: map[string][]byte{"release": []byte("secret values")},
+		},
+	)
+
+	cpClientset := kubefake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-operator-abc12-5d8f7", Namespace: "abc12", Labels: map[string]string{"app.kubernetes.io/name": "app-operator"}},
+	})
+	// chart-operator is missing from the workload cluster.
+	tcClientset := kubefake.NewSimpleClientset()
+
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diagnostics, err := NewDiagnostics(DiagnosticsConfig{
+		Logger:       logger,
+		CPCtrlClient: cpCtrlClient,
+		CPClientset:  cpClientset,
+		TCCtrlClient: tcCtrlClient,
+		TCClientset:  tcClientset,
+		Dir:          t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := diagnostics.Collect(ctx, app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"app.yaml":           "values don't meet the specifications of the schema",
+		"chart.yaml":         "name: keda",
+		"app-operator.log":   "# pod abc12/app-operator-abc12-5d8f7",
+		"helm-releases.yaml": "sh.helm.release.v1.keda.v1",
+		"events.txt":         "App/keda: InstallFailed: chart failed",
+		"errors.txt":         "chart-operator.log:",
+	}
+	for file, content := range expected {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), content) {
+			t.Fatalf("%s == %q, want it to contain %q", file, data, content)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "helm-releases.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret values") {
+		t.Fatalf("helm-releases.yaml contains the release data: %q", data)
+	}
+}
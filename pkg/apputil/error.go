@@ -5,12 +5,53 @@ import (
 	"github.com/google/go-github/v45/github"
 )
 
-var appFailedError = &microerror.Error{
-	Kind: "appFailedError",
+var executionFailedError = &microerror.Error{
+	Kind: "executionFailedError",
 }
 
-var appNotReadyError = &microerror.Error{
-	Kind: "appNotReadyError",
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidValuesError = &microerror.Error{
+	Kind: "invalidValuesError",
+}
+
+// IsInvalidValues asserts invalidValuesError.
+func IsInvalidValues(err error) bool {
+	return microerror.Cause(err) == invalidValuesError
+}
+
+var notFoundError = &microerror.Error{
+	Kind: "notFoundError",
+}
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == notFoundError
+}
+
+var unhealthyWorkloadError = &microerror.Error{
+	Kind: "unhealthyWorkloadError",
+}
+
+// IsUnhealthyWorkload asserts unhealthyWorkloadError.
+func IsUnhealthyWorkload(err error) bool {
+	return microerror.Cause(err) == unhealthyWorkloadError
+}
+
+var versionNotFoundError = &microerror.Error{
+	Kind: "versionNotFoundError",
+}
+
+// IsVersionNotFound asserts versionNotFoundError.
+func IsVersionNotFound(err error) bool {
+	return microerror.Cause(err) == versionNotFoundError
 }
 
 func IsGithubNotFound(err error) bool {
@@ -0,0 +1,329 @@
+package apputil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
+)
+
+const (
+	maxEvents = 5
+)
+
+// Workload is a Deployment, StatefulSet, DaemonSet or Job of a Helm release.
+type Workload struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (w Workload) String() string {
+	return fmt.Sprintf("%s %s/%s", w.Kind, w.Namespace, w.Name)
+}
+
+// ReleaseWorkloads returns the workloads in the manifest of the deployed Helm
+// release of app, read from the Helm release Secret in the workload cluster.
+func ReleaseWorkloads(ctx context.Context, tcCtrlClient client.Client, app *appv1alpha1.App) ([]Workload, error) {
+	secrets := corev1.SecretList{}
+	err := tcCtrlClient.List(ctx, &secrets, client.InNamespace(app.Spec.Namespace), client.MatchingLabels{"owner": "helm", "name": app.Name, "status": "deployed"})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Helm keeps a Secret per revision. Only the latest one should be
+	// deployed, but pick the highest revision to be safe.
+	var secret *corev1.Secret
+	var revision int
+	for i, s := range secrets.Items {
+		r, err := strconv.Atoi(s.Labels["version"])
+		if err != nil {
+			continue
+		}
+		if secret == nil || r > revision {
+			secret = &secrets.Items[i]
+			revision = r
+		}
+	}
+	if secret == nil {
+		return nil, microerror.Maskf(notFoundError, "no deployed Helm release %q in namespace %q", app.Name, app.Spec.Namespace)
+	}
+
+	manifest, err := decodeRelease(secret.Data["release"])
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "cannot decode Helm release Secret %s/%s: %s", secret.Namespace, secret.Name, err)
+	}
+
+	var workloads []Workload
+	for _, doc := range strings.Split(manifest, "\n---") {
+		var o struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		err = yaml.Unmarshal([]byte(doc), &o)
+		if err != nil {
+			return nil, microerror.Maskf(executionFailedError, "cannot parse manifest of Helm release %q: %s", app.Name, err)
+		}
+
+		switch o.Kind {
+		case "Deployment", "StatefulSet", "DaemonSet", "Job":
+		default:
+			continue
+		}
+
+		namespace := o.Metadata.Namespace
+		if namespace == "" {
+			namespace = app.Spec.Namespace
+		}
+
+		workloads = append(workloads, Workload{Kind: o.Kind, Namespace: namespace, Name: o.Metadata.Name})
+	}
+
+	return workloads, nil
+}
+
+// decodeRelease returns the manifest of a Helm release as stored in the
+// "release" key of its Secret: base64 encoded, gzipped JSON.
+func decodeRelease(data []byte) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return "", err
+	}
+
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return "", err
+		}
+		defer r.Close()
+
+		b, err = io.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var release struct {
+		Manifest string `json:"manifest"`
+	}
+	err = json.Unmarshal(b, &release)
+	if err != nil {
+		return "", err
+	}
+
+	return release.Manifest, nil
+}
+
+// CheckWorkloads ensures every Deployment, StatefulSet and DaemonSet of the
+// Helm release of app is ready and every Job completed. The error names the
+// unhealthy workloads along with their recent events.
+func CheckWorkloads(ctx context.Context, tcCtrlClient client.Client, app *appv1alpha1.App) error {
+	workloads, err := ReleaseWorkloads(ctx, tcCtrlClient, app)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var unhealthy []string
+	for _, w := range workloads {
+		reason, err := workloadNotReadyReason(ctx, tcCtrlClient, w)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if reason == "" {
+			continue
+		}
+
+		events, err := recentEvents(ctx, tcCtrlClient, w)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		msg := fmt.Sprintf("%s: %s", w, reason)
+		for _, e := range events {
+			msg += fmt.Sprintf("\n    %s", e)
+		}
+		unhealthy = append(unhealthy, msg)
+	}
+
+	if len(unhealthy) > 0 {
+		return microerror.Maskf(unhealthyWorkloadError, "App %q has %d unhealthy workloads:\n  %s", app.Name, len(unhealthy), strings.Join(unhealthy, "\n  "))
+	}
+
+	return nil
+}
+
+// WaitForWorkloads waits until CheckWorkloads succeeds.
+func WaitForWorkloads(ctx context.Context, logger micrologger.Logger, tcCtrlClient client.Client, app *appv1alpha1.App) error {
+	waiter, err := wait.New(wait.Config{
+		Logger:  logger,
+		Client:  tcCtrlClient,
+		Timeout: 10 * time.Minute,
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	check := func(ctx context.Context) (bool, string, error) {
+		err := CheckWorkloads(ctx, tcCtrlClient, app)
+		if err != nil {
+			return false, err.Error(), nil
+		}
+
+		return true, "", nil
+	}
+
+	err = waiter.Until(ctx, fmt.Sprintf("workloads of App %s", client.ObjectKeyFromObject(app)), "be ready", check)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// workloadNotReadyReason returns why w is not ready, or an empty string when
+// it is.
+func workloadNotReadyReason(ctx context.Context, ctrlClient client.Client, w Workload) (string, error) {
+	key := client.ObjectKey{Namespace: w.Namespace, Name: w.Name}
+
+	switch w.Kind {
+	case "Deployment":
+		d := appsv1.Deployment{}
+		err := ctrlClient.Get(ctx, key, &d)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		replicas := replicasOrDefault(d.Spec.Replicas)
+		if d.Status.ObservedGeneration < d.Generation {
+			return "rollout not observed yet", nil
+		}
+		if d.Status.UpdatedReplicas < replicas || d.Status.AvailableReplicas < replicas {
+			return fmt.Sprintf("%d/%d replicas updated, %d/%d available", d.Status.UpdatedReplicas, replicas, d.Status.AvailableReplicas, replicas), nil
+		}
+	case "StatefulSet":
+		s := appsv1.StatefulSet{}
+		err := ctrlClient.Get(ctx, key, &s)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		replicas := replicasOrDefault(s.Spec.Replicas)
+		if s.Status.ObservedGeneration < s.Generation {
+			return "rollout not observed yet", nil
+		}
+		if s.Status.ReadyReplicas < replicas {
+			return fmt.Sprintf("%d/%d replicas ready", s.Status.ReadyReplicas, replicas), nil
+		}
+	case "DaemonSet":
+		d := appsv1.DaemonSet{}
+		err := ctrlClient.Get(ctx, key, &d)
+		if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		if d.Status.ObservedGeneration < d.Generation {
+			return "rollout not observed yet", nil
+		}
+		if d.Status.UpdatedNumberScheduled < d.Status.DesiredNumberScheduled || d.Status.NumberReady < d.Status.DesiredNumberScheduled {
+			return fmt.Sprintf("%d/%d pods updated, %d/%d ready", d.Status.UpdatedNumberScheduled, d.Status.DesiredNumberScheduled, d.Status.NumberReady, d.Status.DesiredNumberScheduled), nil
+		}
+	case "Job":
+		j := batchv1.Job{}
+		err := ctrlClient.Get(ctx, key, &j)
+		if apierrors.IsNotFound(err) {
+			// Jobs only disappear once they finished, deleted by their
+			// ttlSecondsAfterFinished or by a Helm hook deletion policy.
+			return "", nil
+		} else if err != nil {
+			return "", microerror.Mask(err)
+		}
+
+		for _, c := range j.Status.Conditions {
+			if c.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch c.Type {
+			case batchv1.JobComplete:
+				return "", nil
+			case batchv1.JobFailed:
+				return fmt.Sprintf("failed: %s", c.Message), nil
+			}
+		}
+
+		return fmt.Sprintf("not complete, %d pods active, %d failed", j.Status.Active, j.Status.Failed), nil
+	}
+
+	return "", nil
+}
+
+// recentEvents returns the latest events of w and of the objects named after
+// it, e.g. its pods and ReplicaSets.
+func recentEvents(ctx context.Context, ctrlClient client.Client, w Workload) ([]string, error) {
+	events := corev1.EventList{}
+	err := ctrlClient.List(ctx, &events, client.InNamespace(w.Namespace))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var matching []corev1.Event
+	for _, e := range events.Items {
+		if e.InvolvedObject.Name == w.Name || strings.HasPrefix(e.InvolvedObject.Name, w.Name+"-") {
+			matching = append(matching, e)
+		}
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		return eventTime(matching[i]).After(eventTime(matching[j]))
+	})
+	if len(matching) > maxEvents {
+		matching = matching[:maxEvents]
+	}
+
+	var formatted []string
+	for _, e := range matching {
+		formatted = append(formatted, fmt.Sprintf("%s %s/%s: %s: %s", e.Type, e.InvolvedObject.Kind, e.InvolvedObject.Name, e.Reason, e.Message))
+	}
+
+	return formatted, nil
+}
+
+func eventTime(e corev1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime.Time
+	}
+
+	return e.CreationTimestamp.Time
+}
+
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+
+	return *replicas
+}
@@ -0,0 +1,138 @@
+package apputil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const testManifest = `
+---
+# Source: keda/templates/serviceaccount.yaml
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: keda
+---
+# Source: keda/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: keda-operator
+---
+# Source: keda/templates/statefulset.yaml
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: keda-store
+  namespace: keda-store
+---
+# Source: keda/templates/job.yaml
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: keda-migrate
+`
+
+func newTestReleaseSecret(t *testing.T, name string, namespace string, revision string, status string, manifest string) *corev1.Secret {
+	t.Helper()
+
+	release, err := json.Marshal(map[string]string{"name": name, "manifest": manifest})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err = w.Write(release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sh.helm.release.v1." + name + ".v" + revision,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"owner":   "helm",
+				"name":    name,
+				"status":  status,
+				"version": revision,
+			},
+		},
+		Data: map[string][]byte{
+			"release": []byte(base64.StdEncoding.EncodeToString(buf.Bytes())),
+		},
+	}
+}
+
+func Test_CheckWorkloads(t *testing.T) {
+	ctx := context.Background()
+
+	replicas := int32(2)
+	objects := []client.Object{
+		newTestReleaseSecret(t, "keda", "keda", "1", "superseded", "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: keda-old\n"),
+		newTestReleaseSecret(t, "keda", "keda", "2", "deployed", testManifest),
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "keda-operator", Namespace: "keda"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "keda-store", Namespace: "keda-store"},
+			Status:     appsv1.StatefulSetStatus{Replicas: 1},
+		},
+		// The keda-migrate Job was already removed after it finished.
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "keda-store-0.1", Namespace: "keda-store"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "keda-store-0"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "FailedScheduling",
+			Message:        "0/3 nodes are available",
+			LastTimestamp:  metav1.NewTime(time.Now()),
+		},
+	}
+
+	ctrlClient := newFakeClient(t, objects...)
+
+	app := &appv1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{Name: "keda", Namespace: "abc12"},
+		Spec:       appv1alpha1.AppSpec{Name: "keda", Namespace: "keda"},
+	}
+
+	workloads, err := ReleaseWorkloads(ctx, ctrlClient, app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workloads) != 3 {
+		t.Fatalf("workloads == %v, want 3 workloads", workloads)
+	}
+
+	err = CheckWorkloads(ctx, ctrlClient, app)
+	if !IsUnhealthyWorkload(err) {
+		t.Fatalf("error == %#v, want unhealthyWorkloadError", err)
+	}
+	for _, expected := range []string{"StatefulSet keda-store/keda-store: 0/1 replicas ready", "FailedScheduling: 0/3 nodes are available"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("error %q does not contain %q", err.Error(), expected)
+		}
+	}
+	if strings.Contains(err.Error(), "keda-operator") || strings.Contains(err.Error(), "keda-migrate") {
+		t.Fatalf("error %q names healthy workloads", err.Error())
+	}
+}
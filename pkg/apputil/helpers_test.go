@@ -0,0 +1,61 @@
+package apputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newFakeClient returns a fake client knowing the Kubernetes, CRD and App
+// Platform types, holding objects.
+func newFakeClient(t *testing.T, objects ...client.Object) client.Client {
+	t.Helper()
+
+	s := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{scheme.AddToScheme, apiextensionsv1.AddToScheme, appv1alpha1.AddToScheme} {
+		err := addToScheme(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+}
+
+// newTestCatalogServer serves files, keyed by their path, like a Helm
+// repository.
+func newTestCatalogServer(t *testing.T, files map[string][]byte) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(file)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newTestCatalog returns a Catalog with a single Helm repository at url.
+func newTestCatalog(name string, url string) *appv1alpha1.Catalog {
+	return &appv1alpha1.Catalog{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: appv1alpha1.CatalogSpec{
+			Repositories: []appv1alpha1.CatalogSpecRepository{
+				{Type: "helm", URL: url},
+			},
+		},
+	}
+}
@@ -0,0 +1,169 @@
+package apputil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
+)
+
+const (
+	annotationReleaseName = "meta.helm.sh/release-name"
+	labelInstance         = "app.kubernetes.io/instance"
+)
+
+// Snapshot holds the cluster-wide objects apps commonly leave behind, keyed by
+// kind and name. The values are the names of the Helm releases owning the
+// objects, if any, taken from the Helm release annotation or, for objects
+// created by the workloads of a release, from the app.kubernetes.io/instance
+// label.
+type Snapshot map[string]map[string]string
+
+// TakeSnapshot lists the namespaces, CRDs, webhook configurations and
+// cluster-scoped RBAC of the workload cluster.
+func TakeSnapshot(ctx context.Context, ctrlClient client.Client) (Snapshot, error) {
+	lists := map[string]client.ObjectList{
+		"Namespace":                      &corev1.NamespaceList{},
+		"CustomResourceDefinition":       &apiextensionsv1.CustomResourceDefinitionList{},
+		"ValidatingWebhookConfiguration": &admissionregistrationv1.ValidatingWebhookConfigurationList{},
+		"MutatingWebhookConfiguration":   &admissionregistrationv1.MutatingWebhookConfigurationList{},
+		"ClusterRole":                    &rbacv1.ClusterRoleList{},
+		"ClusterRoleBinding":             &rbacv1.ClusterRoleBindingList{},
+	}
+
+	snapshot := Snapshot{}
+	for kind, list := range lists {
+		err := ctrlClient.List(ctx, list)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		objects := map[string]string{}
+		for _, item := range items {
+			o, err := meta.Accessor(item)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			release := o.GetAnnotations()[annotationReleaseName]
+			if release == "" {
+				release = o.GetLabels()[labelInstance]
+			}
+			objects[o.GetName()] = release
+		}
+		snapshot[kind] = objects
+	}
+
+	return snapshot, nil
+}
+
+// Leftovers returns the objects of s missing in before that were left behind
+// by the given apps, as "<kind>/<name>". Objects are attributed to an app by
+// their Helm release or, when they have none, by a name made of the app or
+// chart name, e.g. the keda-jobs namespace or the scaledobjects.keda.sh CRD
+// of keda. Objects that can't be attributed to any of the apps, e.g. the ones
+// created by tests running in parallel, are ignored, and so are the target
+// namespaces of the apps, which app-operator creates but never deletes.
+func (s Snapshot) Leftovers(before Snapshot, apps []*appv1alpha1.App) []string {
+	releases := map[string]bool{}
+	var names []string
+	ignored := map[string]bool{}
+	for _, app := range apps {
+		releases[app.Name] = true
+		names = append(names, app.Name, app.Spec.Name)
+		ignored[fmt.Sprintf("Namespace/%s", app.Spec.Namespace)] = true
+	}
+
+	var leftovers []string
+	for kind, objects := range s {
+		for name, release := range objects {
+			if _, ok := before[kind][name]; ok {
+				continue
+			}
+			if release != "" && !releases[release] {
+				continue
+			}
+			if release == "" && !namedAfter(name, names) {
+				continue
+			}
+
+			object := fmt.Sprintf("%s/%s", kind, name)
+			if !ignored[object] {
+				leftovers = append(leftovers, object)
+			}
+		}
+	}
+	sort.Strings(leftovers)
+
+	return leftovers
+}
+
+// namedAfter returns whether name is one of names or is made of one of them
+// and other dash or dot separated parts.
+func namedAfter(name string, names []string) bool {
+	for _, n := range names {
+		if n == "" {
+			continue
+		}
+
+		if name == n ||
+			strings.HasPrefix(name, n+"-") || strings.HasPrefix(name, n+".") ||
+			strings.HasSuffix(name, "-"+n) || strings.Contains(name, "."+n+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WaitForNoLeftovers waits until the workload cluster holds no object left
+// behind by the uninstalled apps, see Snapshot.Leftovers. Namespaces can take
+// a while to terminate.
+func WaitForNoLeftovers(ctx context.Context, logger micrologger.Logger, ctrlClient client.Client, before Snapshot, apps []*appv1alpha1.App) error {
+	waiter, err := wait.New(wait.Config{
+		Logger:  logger,
+		Client:  ctrlClient,
+		Timeout: 5 * time.Minute,
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	check := func(ctx context.Context) (bool, string, error) {
+		after, err := TakeSnapshot(ctx, ctrlClient)
+		if err != nil {
+			return false, fmt.Sprintf("cannot take snapshot: %s", err), nil
+		}
+
+		leftovers := after.Leftovers(before, apps)
+		if len(leftovers) > 0 {
+			return false, fmt.Sprintf("%d objects left behind: %s", len(leftovers), strings.Join(leftovers, ", ")), nil
+		}
+
+		return true, "", nil
+	}
+
+	err = waiter.Until(ctx, "workload cluster", "hold no leftovers of the uninstalled apps", check)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package apputil
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_Snapshot_Leftovers(t *testing.T) {
+	ctx := context.Background()
+
+	ctrlClient := newFakeClient(t,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}},
+	)
+
+	before, err := TakeSnapshot(ctx, ctrlClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owned := func(name string, release string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Annotations: map[string]string{annotationReleaseName: release}}
+	}
+
+	for _, o := range []client.Object{
+		// Target namespace of the app, created by app-operator.
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "keda"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "keda-jobs"}},
+		&apiextensionsv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: "scaledobjects.keda.sh"}},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: owned("keda-admission", "keda")},
+		// Owned by an app installed in parallel.
+		&rbacv1.ClusterRole{ObjectMeta: owned("ingress-nginx", "ingress-nginx")},
+		// Created by a test running in parallel.
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "e2e-4zxet"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "e2e-nodepool"}},
+	} {
+		err = ctrlClient.Create(ctx, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	after, err := TakeSnapshot(ctx, ctrlClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apps := []*appv1alpha1.App{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "keda"},
+			Spec:       appv1alpha1.AppSpec{Namespace: "keda"},
+		},
+	}
+
+	expected := []string{
+		"CustomResourceDefinition/scaledobjects.keda.sh",
+		"Namespace/keda-jobs",
+		"ValidatingWebhookConfiguration/keda-admission",
+	}
+
+	leftovers := after.Leftovers(before, apps)
+	if !reflect.DeepEqual(leftovers, expected) {
+		t.Fatalf("leftovers == %v, want %v", leftovers, expected)
+	}
+}
@@ -0,0 +1,282 @@
+package apputil
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
+)
+
+type LifecycleConfig struct {
+	Logger micrologger.Logger
+	// CPCtrlClient is a client of the control plane holding the App CRs and
+	// the catalogs.
+	CPCtrlClient client.Client
+	// TCCtrlClient is a client of the workload cluster the app is installed
+	// in.
+	TCCtrlClient client.Client
+	ClusterID    string
+	AppConfig    AppConfig
+	// Diagnostics collects the diagnostics of the app when a step fails.
+	// Optional.
+	Diagnostics *Diagnostics
+}
+
+// Lifecycle installs the previous catalog version of an app, upgrades it to
+// the latest one and uninstalls it, verifying the App status and the app
+// workloads after every step.
+type Lifecycle struct {
+	logger       micrologger.Logger
+	cpCtrlClient client.Client
+	tcCtrlClient client.Client
+	clusterID    string
+	appCfg       AppConfig
+	resolver     *VersionResolver
+	validator    *SchemaValidator
+	diagnostics  *Diagnostics
+}
+
+func NewLifecycle(config LifecycleConfig) (*Lifecycle, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.CPCtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CPCtrlClient must not be empty", config)
+	}
+	if config.TCCtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.TCCtrlClient must not be empty", config)
+	}
+	if config.ClusterID == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ClusterID must not be empty", config)
+	}
+	err := config.AppConfig.validate()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	resolver, err := NewVersionResolver(VersionResolverConfig{
+		CtrlClient:     config.CPCtrlClient,
+		GithubFallback: githubFallbackFromEnv(),
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	validator, err := NewSchemaValidator(SchemaValidatorConfig{
+		Logger:     config.Logger,
+		CtrlClient: config.CPCtrlClient,
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	l := &Lifecycle{
+		logger:       config.Logger,
+		cpCtrlClient: config.CPCtrlClient,
+		tcCtrlClient: config.TCCtrlClient,
+		clusterID:    config.ClusterID,
+		appCfg:       config.AppConfig,
+		resolver:     resolver,
+		validator:    validator,
+		diagnostics:  config.Diagnostics,
+	}
+
+	return l, nil
+}
+
+// Run executes the lifecycle and returns the App CR as it was last installed.
+// The app is installed directly in its latest version when the catalog holds
+// no previous one. Run always tries to uninstall the app, even when an earlier
+// step failed.
+func (l *Lifecycle) Run(ctx context.Context) (*appv1alpha1.App, error) {
+	versions, err := l.resolver.Versions(ctx, l.appCfg)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	latest := versions[0]
+	first := latest
+	if len(versions) > 1 {
+		first = versions[1]
+	} else {
+		l.logger.Debugf(ctx, "App %q has no version older than %s, skipping upgrade", l.appCfg.Name, latest)
+	}
+
+	app := newApp(l.clusterID, l.appCfg, first)
+
+	userConfig, err := CreateUserConfig(ctx, l.logger, l.cpCtrlClient, l.clusterID, l.appCfg)
+	defer func() {
+		for _, o := range userConfig {
+			err := l.cpCtrlClient.Delete(ctx, o)
+			if err != nil && !apierrors.IsNotFound(err) {
+				l.logger.Debugf(ctx, "Error deleting user config %s of app %q: %s", o.GetName(), app.Name, err)
+			}
+		}
+	}()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = l.install(ctx, app)
+	if err == nil && first != latest {
+		err = l.upgrade(ctx, app, latest)
+	}
+	// Invalid values are rejected before app-operator sees the app, so there
+	// is nothing to diagnose.
+	if err != nil && !IsInvalidValues(err) && l.diagnostics != nil {
+		_, diagErr := l.diagnostics.Collect(ctx, app)
+		if diagErr != nil {
+			l.logger.Debugf(ctx, "Error collecting diagnostics of app %q: %s", app.Name, diagErr)
+		}
+	}
+
+	uninstallErr := l.uninstall(ctx, app)
+	if err != nil {
+		return app, microerror.Mask(err)
+	}
+	if uninstallErr != nil {
+		return app, microerror.Mask(uninstallErr)
+	}
+
+	return app, nil
+}
+
+func (l *Lifecycle) install(ctx context.Context, app *appv1alpha1.App) error {
+	l.logger.Debugf(ctx, "Installing app %q with version %s", app.Name, app.Spec.Version)
+
+	err := l.validator.Validate(ctx, app)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = l.cpCtrlClient.Create(ctx, app)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = l.verify(ctx, app)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (l *Lifecycle) upgrade(ctx context.Context, app *appv1alpha1.App, version string) error {
+	l.logger.Debugf(ctx, "Upgrading app %q from version %s to %s", app.Name, app.Spec.Version, version)
+
+	err := l.cpCtrlClient.Get(ctx, client.ObjectKeyFromObject(app), app)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	app.Spec.Version = version
+	err = l.validator.Validate(ctx, app)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = l.cpCtrlClient.Update(ctx, app)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = l.verify(ctx, app)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (l *Lifecycle) uninstall(ctx context.Context, app *appv1alpha1.App) error {
+	l.logger.Debugf(ctx, "Uninstalling app %q", app.Name)
+
+	err := l.cpCtrlClient.Delete(ctx, app)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	waiter, err := wait.New(wait.Config{
+		Logger:  l.logger,
+		Client:  l.cpCtrlClient,
+		Timeout: 5 * time.Minute,
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = waiter.UntilDeleted(ctx, app)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// verify waits for the App to report its release as deployed in
+// app.Spec.Version and for the workloads of the release to be ready.
+func (l *Lifecycle) verify(ctx context.Context, app *appv1alpha1.App) error {
+	version := app.Spec.Version
+
+	check := func(ctx context.Context) (bool, string, error) {
+		current := appv1alpha1.App{}
+		err := l.cpCtrlClient.Get(ctx, client.ObjectKeyFromObject(app), &current)
+		if err != nil {
+			return false, fmt.Sprintf("cannot get App: %s", err), nil
+		}
+
+		if current.Status.Version != version {
+			return false, fmt.Sprintf("reports version %q", current.Status.Version), nil
+		}
+
+		switch current.Status.Release.Status {
+		case "deployed":
+		case "failed":
+			// app-operator and chart-operator often recover from failed.
+			return false, fmt.Sprintf("release is in failed state: %s", current.Status.Release.Reason), nil
+		default:
+			return false, fmt.Sprintf("release is in state %q", current.Status.Release.Status), nil
+		}
+
+		// Apps installed in the control plane run their workloads there.
+		workloadCtrlClient := l.tcCtrlClient
+		if l.appCfg.InCluster {
+			workloadCtrlClient = l.cpCtrlClient
+		}
+
+		err = CheckWorkloads(ctx, workloadCtrlClient, &current)
+		if err != nil {
+			return false, err.Error(), nil
+		}
+
+		return true, "", nil
+	}
+
+	waiter, err := wait.New(wait.Config{
+		Logger:  l.logger,
+		Client:  l.cpCtrlClient,
+		Timeout: 10 * time.Minute,
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = waiter.Until(ctx, fmt.Sprintf("App %s", client.ObjectKeyFromObject(app)), fmt.Sprintf("deploy version %s", version), check)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	l.logger.Debugf(ctx, "App %q with version %s deployed correctly", app.Name, version)
+
+	return nil
+}
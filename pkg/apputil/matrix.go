@@ -0,0 +1,202 @@
+package apputil
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/ghodss/yaml"
+	"github.com/giantswarm/microerror"
+)
+
+// Matrix is the list of managed apps tested by Test_ManagedApps, e.g.
+//
+//	apps:
+//	  - name: karpenter
+//	    team: phoenix
+//	    providers: [aws]
+//	  - name: cloudflared
+//	    team: cabbage
+//	    secretValues: ${CLOUDFLARED_VALUES}
+//	    env: [CLOUDFLARED_VALUES]
+type Matrix struct {
+	Apps []MatrixEntry `json:"apps"`
+}
+
+type MatrixEntry struct {
+	Name string `json:"name"`
+	// Team is the team owning the app. Test results are grouped by team.
+	Team      string `json:"team"`
+	Catalog   string `json:"catalog,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Values is the YAML of the user values of the app.
+	Values string `json:"values,omitempty"`
+	// SecretValues is the YAML of the user values of the app stored in a
+	// Secret. The environment variables listed in Env are expanded where
+	// they are referenced as ${NAME}, so credentials do not end up in the
+	// matrix file. Anything else, including other $ signs, is kept as is.
+	SecretValues string `json:"secretValues,omitempty"`
+	// Env lists the environment variables referenced by SecretValues. The
+	// app is skipped when any of them is not set.
+	Env        []string `json:"env,omitempty"`
+	Version    string   `json:"version,omitempty"`
+	PreRelease bool     `json:"preRelease,omitempty"`
+	// ExtraConfigs, SkipPSPRemovalPatch, ClusterValues, InCluster,
+	// KubeConfigSecret and KubeConfigContext are passed on to the AppConfig
+	// of the app.
+	ExtraConfigs        []ExtraConfig `json:"extraConfigs,omitempty"`
+	SkipPSPRemovalPatch bool          `json:"skipPSPRemovalPatch,omitempty"`
+	ClusterValues       bool          `json:"clusterValues,omitempty"`
+	InCluster           bool          `json:"inCluster,omitempty"`
+	KubeConfigSecret    string        `json:"kubeConfigSecret,omitempty"`
+	KubeConfigContext   string        `json:"kubeConfigContext,omitempty"`
+	// Providers limits the app to the listed providers. The app is tested on
+	// all providers when empty.
+	Providers []string `json:"providers,omitempty"`
+	// KnownFailure is the reason the app is known to fail. Apps with a known
+	// failure are skipped.
+	KnownFailure string `json:"knownFailure,omitempty"`
+}
+
+// LoadMatrix loads and validates a matrix file.
+func LoadMatrix(path string) (*Matrix, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var matrix Matrix
+	err = yaml.UnmarshalStrict(data, &matrix, yaml.DisallowUnknownFields)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "cannot parse matrix file %q: %s", path, err)
+	}
+
+	seen := map[string]bool{}
+	for i, entry := range matrix.Apps {
+		if entry.Name == "" {
+			return nil, microerror.Maskf(invalidConfigError, "app %d of matrix file %q has no name", i, path)
+		}
+		if entry.Team == "" {
+			return nil, microerror.Maskf(invalidConfigError, "app %q of matrix file %q has no team", entry.Name, path)
+		}
+
+		if entry.Version != "" {
+			_, err = semver.ParseRange(entry.Version)
+			if err != nil {
+				return nil, microerror.Maskf(invalidConfigError, "app %q of matrix file %q has an invalid version range: %s", entry.Name, path, err)
+			}
+		}
+
+		for _, name := range entry.Env {
+			if !envVarName.MatchString(name) {
+				return nil, microerror.Maskf(invalidConfigError, "app %q of matrix file %q has an invalid env var name %q", entry.Name, path, name)
+			}
+			if !strings.Contains(entry.SecretValues, envVarReference(name)) {
+				return nil, microerror.Maskf(invalidConfigError, "app %q of matrix file %q does not reference env var %q in its secretValues", entry.Name, path, name)
+			}
+		}
+
+		err = entry.AppConfig().validate()
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "app %q of matrix file %q: %s", entry.Name, path, err)
+		}
+
+		// App CRs are named after the app, so every app can be tested once.
+		if seen[entry.Name] {
+			return nil, microerror.Maskf(invalidConfigError, "app %q is listed more than once in matrix file %q", entry.Name, path)
+		}
+		seen[entry.Name] = true
+	}
+
+	return &matrix, nil
+}
+
+// Teams returns the names of the teams owning apps, sorted.
+func (m *Matrix) Teams() []string {
+	seen := map[string]bool{}
+	var teams []string
+	for _, entry := range m.Apps {
+		if !seen[entry.Team] {
+			seen[entry.Team] = true
+			teams = append(teams, entry.Team)
+		}
+	}
+	sort.Strings(teams)
+
+	return teams
+}
+
+// TeamApps returns the apps owned by team, in file order.
+func (m *Matrix) TeamApps(team string) []MatrixEntry {
+	var entries []MatrixEntry
+	for _, entry := range m.Apps {
+		if entry.Team == team {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// AppliesTo reports whether the app is tested on provider.
+func (e MatrixEntry) AppliesTo(provider string) bool {
+	if len(e.Providers) == 0 {
+		return true
+	}
+
+	for _, p := range e.Providers {
+		if p == provider {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MissingEnv returns the env vars of Env that are not set or empty.
+func (e MatrixEntry) MissingEnv() []string {
+	var missing []string
+	for _, name := range e.Env {
+		if os.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing
+}
+
+func (e MatrixEntry) AppConfig() AppConfig {
+	secretValues := e.SecretValues
+	for _, name := range e.Env {
+		secretValues = strings.ReplaceAll(secretValues, envVarReference(name), os.Getenv(name))
+	}
+
+	return AppConfig{
+		Catalog:             e.Catalog,
+		Name:                e.Name,
+		Namespace:           e.Namespace,
+		ValuesYAML:          e.Values,
+		SecretValuesYAML:    secretValues,
+		Version:             e.Version,
+		PreRelease:          e.PreRelease,
+		ExtraConfigs:        e.ExtraConfigs,
+		SkipPSPRemovalPatch: e.SkipPSPRemovalPatch,
+		ClusterValues:       e.ClusterValues,
+		InCluster:           e.InCluster,
+		KubeConfigSecret:    e.KubeConfigSecret,
+		KubeConfigContext:   e.KubeConfigContext,
+	}
+}
+
+func (e MatrixEntry) String() string {
+	return fmt.Sprintf("%s (team %s)", e.Name, e.Team)
+}
+
+var envVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func envVarReference(name string) string {
+	return fmt.Sprintf("${%s}", name)
+}
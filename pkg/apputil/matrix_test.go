@@ -0,0 +1,160 @@
+package apputil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_LoadMatrix(t *testing.T) {
+	testCases := []struct {
+		name          string
+		content       string
+		expectedTeams []string
+		expectedErr   bool
+	}{
+		{
+			name: "case 0: valid matrix",
+			content: `apps:
+  - name: karpenter
+    team: phoenix
+    providers: [aws]
+  - name: keda
+    team: atlas
+  - name: loki
+    team: atlas
+    knownFailure: flaky
+`,
+			expectedTeams: []string{"atlas", "phoenix"},
+		},
+		{
+			name: "case 1: missing team",
+			content: `apps:
+  - name: keda
+`,
+			expectedErr: true,
+		},
+		{
+			name: "case 2: duplicated app",
+			content: `apps:
+  - name: keda
+    team: atlas
+  - name: keda
+    team: phoenix
+`,
+			expectedErr: true,
+		},
+		{
+			name: "case 3: invalid version range",
+			content: `apps:
+  - name: keda
+    team: atlas
+    version: ">=two"
+`,
+			expectedErr: true,
+		},
+		{
+			name: "case 4: unknown field",
+			content: `apps:
+  - name: keda
+    team: atlas
+    owner: atlas
+`,
+			expectedErr: true,
+		},
+		{
+			name: "case 5: env var not referenced",
+			content: `apps:
+  - name: datadog
+    team: atlas
+    secretValues: "apiKey: $DATADOG_API_KEY"
+    env: [DATADOG_API_KEY]
+`,
+			expectedErr: true,
+		},
+		{
+			name: "case 6: invalid extra config priority",
+			content: `apps:
+  - name: datadog
+    team: atlas
+    extraConfigs:
+      - name: datadog-overrides
+        priority: 200
+`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "managed-apps.yaml")
+			err := os.WriteFile(path, []byte(tc.content), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			matrix, err := LoadMatrix(path)
+			if tc.expectedErr {
+				if !IsInvalidConfig(err) {
+					t.Fatalf("error == %#v, want invalidConfigError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if teams := matrix.Teams(); !reflect.DeepEqual(teams, tc.expectedTeams) {
+				t.Fatalf("teams == %v, want %v", teams, tc.expectedTeams)
+			}
+		})
+	}
+}
+
+func Test_LoadMatrix_Repository(t *testing.T) {
+	_, err := LoadMatrix("../../managed-apps.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_MatrixEntry_AppliesTo(t *testing.T) {
+	entry := MatrixEntry{Name: "karpenter", Team: "phoenix", Providers: []string{"aws"}}
+	if !entry.AppliesTo("aws") {
+		t.Fatal("expected app to apply to aws")
+	}
+	if entry.AppliesTo("azure") {
+		t.Fatal("expected app not to apply to azure")
+	}
+
+	entry.Providers = nil
+	if !entry.AppliesTo("azure") {
+		t.Fatal("expected app without providers to apply to azure")
+	}
+}
+
+func Test_MatrixEntry_Env(t *testing.T) {
+	entry := MatrixEntry{
+		Name:         "datadog",
+		Team:         "atlas",
+		SecretValues: "apiKey: ${DATADOG_API_KEY}\nsite: ${DATADOG_SITE}\npassword: pa$$word\n",
+		Env:          []string{"DATADOG_API_KEY"},
+	}
+
+	t.Setenv("DATADOG_API_KEY", "")
+	if missing := entry.MissingEnv(); !reflect.DeepEqual(missing, []string{"DATADOG_API_KEY"}) {
+		t.Fatalf("missing env == %v, want [DATADOG_API_KEY]", missing)
+	}
+
+	t.Setenv("DATADOG_API_KEY", "secret")
+	t.Setenv("DATADOG_SITE", "datadoghq.eu")
+	if missing := entry.MissingEnv(); len(missing) != 0 {
+		t.Fatalf("missing env == %v, want none", missing)
+	}
+
+	expected := "apiKey: secret\nsite: ${DATADOG_SITE}\npassword: pa$$word\n"
+	if values := entry.AppConfig().SecretValuesYAML; values != expected {
+		t.Fatalf("secret values == %q, want %q", values, expected)
+	}
+}
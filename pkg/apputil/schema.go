@@ -0,0 +1,371 @@
+package apputil
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	appv1alpha1 "github.com/giantswarm/apiextensions-application/api/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/xeipuuv/gojsonschema"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Priorities app-operator merges the catalog, cluster and user configs
+	// with, next to the extra configs.
+	catalogConfigPriority = 0
+	clusterConfigPriority = 50
+	userConfigPriority    = 100
+
+	valuesKey = "values"
+)
+
+type SchemaValidatorConfig struct {
+	Logger micrologger.Logger
+	// CtrlClient is a client of the cluster holding the App CRs, their
+	// configs and the Catalog CRs.
+	CtrlClient client.Client
+	HTTPClient *http.Client
+}
+
+// SchemaValidator validates the values of an App against the
+// values.schema.json of its chart before the App CR is created, so invalid
+// values fail right away instead of when app-operator gives up.
+type SchemaValidator struct {
+	logger     micrologger.Logger
+	ctrlClient client.Client
+	httpClient *http.Client
+}
+
+func NewSchemaValidator(config SchemaValidatorConfig) (*SchemaValidator, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.CtrlClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.CtrlClient must not be empty", config)
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+
+	v := &SchemaValidator{
+		logger:     config.Logger,
+		ctrlClient: config.CtrlClient,
+		httpClient: config.HTTPClient,
+	}
+
+	return v, nil
+}
+
+// Validate merges the chart values with the catalog, cluster, user and extra
+// configs of app the way app-operator does and validates the result against
+// the values.schema.json of the chart. Schema violations are returned as
+// invalidValuesError, listing the JSON pointer of every invalid value. Charts
+// without a schema, or that cannot be fetched, are not validated.
+func (v *SchemaValidator) Validate(ctx context.Context, app *appv1alpha1.App) error {
+	catalogName := app.Spec.Catalog
+	if catalogName == "" {
+		catalogName = defaultCatalog
+	}
+
+	catalog, err := getCatalog(ctx, v.ctrlClient, catalogName)
+	if err != nil {
+		v.logger.Debugf(ctx, "Skipping values validation of app %q: %s", app.Name, err)
+		return nil
+	}
+
+	files, err := v.chartFiles(ctx, catalog, app, "values.yaml", "values.schema.json")
+	if err != nil {
+		v.logger.Debugf(ctx, "Skipping values validation of app %q: %s", app.Name, err)
+		return nil
+	}
+	schema, ok := files["values.schema.json"]
+	if !ok {
+		v.logger.Debugf(ctx, "Skipping values validation of app %q: chart %s has no values.schema.json", app.Name, app.Spec.Version)
+		return nil
+	}
+
+	values, err := parseValues(files["values.yaml"])
+	if err != nil {
+		return microerror.Maskf(executionFailedError, "cannot parse values.yaml of chart %q: %s", app.Spec.Name, err)
+	}
+
+	layers, err := v.configLayers(ctx, catalog, app)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	for _, l := range layers {
+		layerValues, err := parseValues(l.values)
+		if err != nil {
+			return microerror.Maskf(invalidValuesError, "cannot parse values of %s of app %q: %s", l.source, app.Name, err)
+		}
+
+		values = mergeValues(values, layerValues)
+	}
+
+	return validateValues(app.Name, schema, values)
+}
+
+// chartFiles returns the given files of the top-level chart of the app,
+// fetched from catalog. Files missing from the chart are left out.
+func (v *SchemaValidator) chartFiles(ctx context.Context, catalog *appv1alpha1.Catalog, app *appv1alpha1.App, names ...string) (map[string][]byte, error) {
+	repositoryURL, index, err := getCatalogIndex(ctx, v.httpClient, catalog)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var chartURLs []string
+	for _, entry := range index.Entries[app.Spec.Name] {
+		if strings.TrimPrefix(entry.Version, "v") == strings.TrimPrefix(app.Spec.Version, "v") {
+			chartURLs = entry.URLs
+			break
+		}
+	}
+	if len(chartURLs) == 0 {
+		return nil, microerror.Maskf(notFoundError, "catalog %q has no chart %q in version %s", catalog.Name, app.Spec.Name, app.Spec.Version)
+	}
+
+	chartURL, err := resolveURL(repositoryURL, chartURLs[0])
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	tarball, err := httpGet(ctx, v.httpClient, chartURL)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	files, err := extractChartFiles(tarball, names...)
+	if err != nil {
+		return nil, microerror.Maskf(executionFailedError, "cannot read chart %s: %s", chartURL, err)
+	}
+
+	return files, nil
+}
+
+type configLayer struct {
+	source   string
+	priority int
+	values   []byte
+}
+
+// configLayers returns the values of the configs of app, in the order
+// app-operator merges them. Layers with the same priority keep the order of
+// the App spec.
+func (v *SchemaValidator) configLayers(ctx context.Context, catalog *appv1alpha1.Catalog, app *appv1alpha1.App) ([]configLayer, error) {
+	type reference struct {
+		kind      string
+		name      string
+		namespace string
+		priority  int
+	}
+
+	var references []reference
+
+	if catalog.Spec.Config != nil {
+		if cm := catalog.Spec.Config.ConfigMap; cm != nil {
+			references = append(references, reference{kind: ExtraConfigKindConfigMap, name: cm.Name, namespace: cm.Namespace, priority: catalogConfigPriority})
+		}
+		if secret := catalog.Spec.Config.Secret; secret != nil {
+			references = append(references, reference{kind: ExtraConfigKindSecret, name: secret.Name, namespace: secret.Namespace, priority: catalogConfigPriority})
+		}
+	}
+
+	references = append(references,
+		reference{kind: ExtraConfigKindConfigMap, name: app.Spec.Config.ConfigMap.Name, namespace: app.Spec.Config.ConfigMap.Namespace, priority: clusterConfigPriority},
+		reference{kind: ExtraConfigKindSecret, name: app.Spec.Config.Secret.Name, namespace: app.Spec.Config.Secret.Namespace, priority: clusterConfigPriority},
+		reference{kind: ExtraConfigKindConfigMap, name: app.Spec.UserConfig.ConfigMap.Name, namespace: app.Spec.UserConfig.ConfigMap.Namespace, priority: userConfigPriority},
+		reference{kind: ExtraConfigKindSecret, name: app.Spec.UserConfig.Secret.Name, namespace: app.Spec.UserConfig.Secret.Namespace, priority: userConfigPriority},
+	)
+	for _, c := range app.Spec.ExtraConfigs {
+		references = append(references, reference{kind: c.Kind, name: c.Name, namespace: c.Namespace, priority: c.Priority})
+	}
+
+	var layers []configLayer
+	for _, r := range references {
+		if r.name == "" {
+			continue
+		}
+
+		key := client.ObjectKey{Namespace: r.namespace, Name: r.name}
+		layer := configLayer{
+			source:   fmt.Sprintf("%s %s", r.kind, key),
+			priority: r.priority,
+		}
+
+		// app-operator skips missing configs too, so they are not an error
+		// here either.
+		switch r.kind {
+		case ExtraConfigKindSecret:
+			secret := corev1.Secret{}
+			err := v.ctrlClient.Get(ctx, key, &secret)
+			if apierrors.IsNotFound(err) {
+				v.logger.Debugf(ctx, "Skipping missing %s of app %q in values validation", layer.source, app.Name)
+				continue
+			} else if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			layer.values = secret.Data[valuesKey]
+		default:
+			cm := corev1.ConfigMap{}
+			err := v.ctrlClient.Get(ctx, key, &cm)
+			if apierrors.IsNotFound(err) {
+				v.logger.Debugf(ctx, "Skipping missing %s of app %q in values validation", layer.source, app.Name)
+				continue
+			} else if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			layer.values = []byte(cm.Data[valuesKey])
+		}
+
+		layers = append(layers, layer)
+	}
+
+	sort.SliceStable(layers, func(i, j int) bool {
+		return layers[i].priority < layers[j].priority
+	})
+
+	return layers, nil
+}
+
+// validateValues validates values against the JSON schema.
+func validateValues(appName string, schema []byte, values map[string]interface{}) error {
+	result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(schema), gojsonschema.NewGoLoader(values))
+	if err != nil {
+		return microerror.Maskf(executionFailedError, "cannot validate values of app %q: %s", appName, err)
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	violations := make([]string, 0, len(result.Errors()))
+	for _, e := range result.Errors() {
+		violations = append(violations, fmt.Sprintf("%s: %s", jsonPointer(e.Context()), e.Description()))
+	}
+	sort.Strings(violations)
+
+	return microerror.Maskf(invalidValuesError, "values of app %q do not match the chart schema:\n%s", appName, strings.Join(violations, "\n"))
+}
+
+// jsonPointer returns the RFC 6901 JSON pointer of the value context points
+// to, e.g. "/controller/replicas".
+func jsonPointer(context *gojsonschema.JsonContext) string {
+	const delimiter = "\x00"
+
+	tokens := strings.Split(context.String(delimiter), delimiter)
+	// The first token is the root of the document.
+	tokens = tokens[1:]
+	if len(tokens) == 0 {
+		return "/"
+	}
+
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	for i, t := range tokens {
+		tokens[i] = escaper.Replace(t)
+	}
+
+	return "/" + strings.Join(tokens, "/")
+}
+
+func parseValues(data []byte) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	err := yaml.Unmarshal(data, &values)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+
+	return values, nil
+}
+
+// mergeValues merges override into base like Helm does: maps are merged
+// recursively, other values are replaced and null values remove the key.
+func mergeValues(base map[string]interface{}, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base))
+	for k, v := range base {
+		merged[k] = v
+	}
+
+	for k, v := range override {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+
+		baseMap, baseIsMap := merged[k].(map[string]interface{})
+		overrideMap, overrideIsMap := v.(map[string]interface{})
+		if baseIsMap && overrideIsMap {
+			merged[k] = mergeValues(baseMap, overrideMap)
+		} else {
+			merged[k] = v
+		}
+	}
+
+	return merged
+}
+
+// extractChartFiles returns the given files of the top-level chart in a chart
+// tarball, whose entries are prefixed with the chart directory.
+func extractChartFiles(tarball []byte, names ...string) (map[string][]byte, error) {
+	wanted := map[string]bool{}
+	for _, n := range names {
+		wanted[n] = true
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer gz.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		parts := strings.SplitN(header.Name, "/", 2)
+		if len(parts) != 2 || !wanted[parts[1]] {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		files[parts[1]] = data
+	}
+
+	return files, nil
+}
+
+func resolveURL(base string, ref string) (string, error) {
+	b, err := url.Parse(strings.TrimSuffix(base, "/") + "/")
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return b.ResolveReference(r).String(), nil
+}
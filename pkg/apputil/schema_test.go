@@ -0,0 +1,232 @@
+package apputil
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	testSchemaIndex = `apiVersion: v1
+entries:
+  ingress-nginx:
+  - name: ingress-nginx
+    version: 3.0.0
+    urls:
+    - ingress-nginx-3.0.0.tgz
+  - name: ingress-nginx
+    version: 2.0.0
+    urls:
+    - ingress-nginx-2.0.0.tgz
+`
+
+	testSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["controller"],
+  "properties": {
+    "baseDomain": {"type": "string"},
+    "controller": {
+      "type": "object",
+      "properties": {
+        "replicaCount": {"type": "integer"},
+        "service": {
+          "type": "object",
+          "properties": {
+            "type": {"enum": ["LoadBalancer", "NodePort"]}
+          }
+        }
+      }
+    }
+  },
+  "additionalProperties": false
+}`
+
+	testChartValues = `controller:
+  replicaCount: 1
+  service:
+    type: LoadBalancer
+`
+)
+
+func Test_SchemaValidator_Validate(t *testing.T) {
+	testCases := []struct {
+		name          string
+		version       string
+		userValues    string
+		extraValues   string
+		expectedErr   bool
+		expectedPaths []string
+	}{
+		{
+			name:       "case 0: valid values",
+			version:    "3.0.0",
+			userValues: "baseDomain: abc12.example.com\ncontroller:\n  replicaCount: 2\n",
+		},
+		{
+			name:          "case 1: invalid user values",
+			version:       "3.0.0",
+			userValues:    "controller:\n  replicaCount: two\n  service:\n    type: ClusterIP\nbaseDomian: abc12.example.com\n",
+			expectedErr:   true,
+			expectedPaths: []string{"/: Additional property baseDomian", "/controller/replicaCount:", "/controller/service/type:"},
+		},
+		{
+			name:        "case 2: extra config overriding the user values",
+			version:     "3.0.0",
+			userValues:  "controller:\n  replicaCount: 2\n",
+			extraValues: "controller:\n  replicaCount: \"2\"\n",
+			expectedErr: true,
+			expectedPaths: []string{
+				"/controller/replicaCount:",
+			},
+		},
+		{
+			name:        "case 3: null removing a required value",
+			version:     "3.0.0",
+			userValues:  "controller: null\n",
+			expectedErr: true,
+			expectedPaths: []string{
+				"/: controller is required",
+			},
+		},
+		{
+			name:       "case 4: chart without schema",
+			version:    "2.0.0",
+			userValues: "controller:\n  replicaCount: two\n",
+		},
+		{
+			name:       "case 5: chart missing from the catalog",
+			version:    "4.0.0",
+			userValues: "controller:\n  replicaCount: two\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validator := newTestSchemaValidator(t, tc.userValues, tc.extraValues)
+
+			app := newApp("abc12", AppConfig{
+				Name:                "ingress-nginx",
+				Namespace:           "kube-system",
+				ValuesYAML:          tc.userValues,
+				SkipPSPRemovalPatch: true,
+				// ingress-nginx-missing doesn't exist and is skipped.
+				ExtraConfigs: []ExtraConfig{{Name: "ingress-nginx-overrides", Priority: 120}, {Name: "ingress-nginx-missing"}},
+			}, tc.version)
+
+			err := validator.Validate(context.Background(), app)
+			if !tc.expectedErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			if !IsInvalidValues(err) {
+				t.Fatalf("error == %#v, want invalidValuesError", err)
+			}
+			for _, p := range tc.expectedPaths {
+				if !strings.Contains(err.Error(), p) {
+					t.Fatalf("error == %q, want it to contain %q", err.Error(), p)
+				}
+			}
+		})
+	}
+}
+
+func Test_jsonPointer(t *testing.T) {
+	err := validateValues("test", []byte(`{"properties": {"a/b": {"properties": {"c~d": {"type": "integer"}}}}}`), map[string]interface{}{
+		"a/b": map[string]interface{}{"c~d": "one"},
+	})
+	if !IsInvalidValues(err) {
+		t.Fatalf("error == %#v, want invalidValuesError", err)
+	}
+	if !strings.Contains(err.Error(), "/a~1b/c~0d: ") {
+		t.Fatalf("error == %q, want it to contain the escaped JSON pointer", err.Error())
+	}
+}
+
+func newTestSchemaValidator(t *testing.T, userValues string, extraValues string) *SchemaValidator {
+	t.Helper()
+
+	server := newTestCatalogServer(t, map[string][]byte{
+		"/catalog/index.yaml": []byte(testSchemaIndex),
+		"/catalog/ingress-nginx-3.0.0.tgz": newTestChart(t, "ingress-nginx", map[string]string{
+			"Chart.yaml":                    "name: ingress-nginx\nversion: 3.0.0\n",
+			"values.yaml":                   testChartValues,
+			"values.schema.json":            testSchema,
+			"charts/sub/values.yaml":        "ignored: true\n",
+			"charts/sub/values.schema.json": `{"required": ["ignored"]}`,
+		}),
+		"/catalog/ingress-nginx-2.0.0.tgz": newTestChart(t, "ingress-nginx", map[string]string{
+			"Chart.yaml":  "name: ingress-nginx\nversion: 2.0.0\n",
+			"values.yaml": testChartValues,
+		}),
+	})
+
+	objects := []client.Object{
+		newTestCatalog("giantswarm", server.URL+"/catalog/"),
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "ingress-nginx-user-values", Namespace: "abc12"},
+			Data:       map[string]string{valuesKey: userValues},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "ingress-nginx-overrides", Namespace: "abc12"},
+			Data:       map[string]string{valuesKey: extraValues},
+		},
+	}
+
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validator, err := NewSchemaValidator(SchemaValidatorConfig{
+		Logger:     logger,
+		CtrlClient: newFakeClient(t, objects...),
+		HTTPClient: server.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return validator
+}
+
+// newTestChart returns a chart tarball holding files in the chart directory.
+func newTestChart(t *testing.T, name string, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for path, content := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name + "/" + path, Mode: 0644, Size: int64(len(content))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tw.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := tw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gz.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,148 @@
+package assert
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type Mode int
+
+const (
+	// FatalMode stops the test on the first violation, like the assertions
+	// do when called with a *testing.T.
+	FatalMode Mode = iota
+	// SoftMode records violations and reports all of them together when the
+	// test finishes.
+	SoftMode
+)
+
+// Violation is a single failed assertion.
+type Violation struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Message   string
+	Expected  string
+	Actual    string
+}
+
+func (v Violation) String() string {
+	if v.Kind == "" && v.Name == "" {
+		return v.Message
+	}
+
+	return fmt.Sprintf("%s '%s/%s': %s", v.Kind, v.Namespace, v.Name, v.Message)
+}
+
+// Collector is a TestingT recording the violations of the assertions it is
+// passed to. It is bound to the test it was created for and reports all the
+// recorded violations as a single test error when that test finishes.
+type Collector struct {
+	t *testing.T
+
+	mutex      sync.Mutex
+	mode       Mode
+	violations []Violation
+}
+
+func NewCollector(t *testing.T, mode Mode) *Collector {
+	c := &Collector{
+		t:    t,
+		mode: mode,
+	}
+
+	t.Cleanup(c.Report)
+
+	return c
+}
+
+// SetMode switches the collector between fatal and soft mode. Violations
+// recorded so far are kept.
+func (c *Collector) SetMode(mode Mode) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.mode = mode
+}
+
+func (c *Collector) Helper() {
+	c.t.Helper()
+}
+
+// Fatalf records a violation without object details. It stops the test only
+// in fatal mode.
+func (c *Collector) Fatalf(format string, args ...interface{}) {
+	c.t.Helper()
+	c.record(Violation{Message: fmt.Sprintf(format, args...)})
+}
+
+// Violations returns the violations recorded so far.
+func (c *Collector) Violations() []Violation {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]Violation(nil), c.violations...)
+}
+
+// Report fails the test with all the violations recorded so far and forgets
+// them. It is called automatically when the test finishes.
+func (c *Collector) Report() {
+	c.t.Helper()
+
+	violations := c.Violations()
+	if len(violations) == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	c.violations = nil
+	c.mutex.Unlock()
+
+	lines := make([]string, 0, len(violations))
+	for _, v := range violations {
+		lines = append(lines, "- "+v.String())
+	}
+
+	c.t.Errorf("%d assertion(s) failed:\n%s", len(violations), strings.Join(lines, "\n"))
+}
+
+func (c *Collector) record(v Violation) {
+	c.t.Helper()
+
+	c.mutex.Lock()
+	mode := c.mode
+	if mode == SoftMode {
+		c.violations = append(c.violations, v)
+	}
+	c.mutex.Unlock()
+
+	if mode == FatalMode {
+		c.t.Fatalf("%s", v.String())
+	}
+}
+
+// report sends v to the collector if t is one, and fails the test otherwise.
+func report(t TestingT, v Violation) {
+	t.Helper()
+
+	c, ok := t.(*Collector)
+	if ok {
+		c.record(v)
+		return
+	}
+
+	t.Fatalf("%s", v.String())
+}
+
+func newViolation(object TestedObject, expected string, actual string, format string, args ...interface{}) Violation {
+	return Violation{
+		Kind:      object.GetObjectKind().GroupVersionKind().Kind,
+		Namespace: object.GetNamespace(),
+		Name:      object.GetName(),
+		Message:   fmt.Sprintf(format, args...),
+		Expected:  expected,
+		Actual:    actual,
+	}
+}
@@ -0,0 +1,150 @@
+package assert
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// FieldValue evaluates the JSONPath expression path against object and
+// returns the result as a string. Missing fields evaluate to an empty string.
+// The surrounding braces of the expression are optional.
+func FieldValue(object TestedObject, path string) (string, error) {
+	var content map[string]interface{}
+	{
+		u, ok := object.(*unstructured.Unstructured)
+		if ok {
+			content = u.UnstructuredContent()
+		} else {
+			var err error
+			content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+			if err != nil {
+				return "", microerror.Mask(err)
+			}
+		}
+	}
+
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+
+	j := jsonpath.New(path)
+	j.AllowMissingKeys(true)
+
+	err := j.Parse(path)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	var buf bytes.Buffer
+	err = j.Execute(&buf, content)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return buf.String(), nil
+}
+
+func FieldIsSet(t TestingT, object TestedObject, path string) {
+	t.Helper()
+
+	value, ok := fieldValue(t, object, path)
+	if ok && value == "" {
+		report(t, newViolation(object, "", "",
+			"expected that field %q is set",
+			path))
+	}
+}
+
+func FieldHasValue(t TestingT, object TestedObject, path string, expected string) {
+	t.Helper()
+
+	value, ok := fieldValue(t, object, path)
+	if ok && value != expected {
+		report(t, newViolation(object, expected, value,
+			"expected field %q to have value %q, but got %q",
+			path,
+			expected,
+			value))
+	}
+}
+
+func FieldIsEqual(t TestingT, referenceObject TestedObject, referencePath string, otherObject TestedObject, otherPath string) {
+	t.Helper()
+
+	referenceValue, ok := fieldValue(t, referenceObject, referencePath)
+	if !ok {
+		return
+	}
+	otherValue, ok := fieldValue(t, otherObject, otherPath)
+	if !ok {
+		return
+	}
+
+	if otherValue != referenceValue {
+		report(t, newViolation(otherObject, referenceValue, otherValue,
+			"expected field %q to have value %q (to match field %q of %s %q), but got %q",
+			otherPath,
+			referenceValue,
+			referencePath,
+			referenceObject.GetObjectKind().GroupVersionKind().Kind,
+			referenceObject.GetName(),
+			otherValue))
+	}
+}
+
+func FieldMatches(t TestingT, object TestedObject, path string, pattern *regexp.Regexp) {
+	t.Helper()
+
+	value, ok := fieldValue(t, object, path)
+	if ok && !pattern.MatchString(value) {
+		report(t, newViolation(object, pattern.String(), value,
+			"expected field %q to match %q, but got %q",
+			path,
+			pattern.String(),
+			value))
+	}
+}
+
+func FieldIsWithinRange(t TestingT, object TestedObject, path string, min float64, max float64) {
+	t.Helper()
+
+	value, ok := fieldValue(t, object, path)
+	if !ok {
+		return
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < min || number > max {
+		report(t, newViolation(object, fmt.Sprintf("[%v, %v]", min, max), value,
+			"expected field %q to be a number between %v and %v, but got %q",
+			path,
+			min,
+			max,
+			value))
+	}
+}
+
+// fieldValue evaluates path against object and reports a violation when the
+// expression cannot be evaluated.
+func fieldValue(t TestingT, object TestedObject, path string) (string, bool) {
+	t.Helper()
+
+	value, err := FieldValue(object, path)
+	if err != nil {
+		report(t, newViolation(object, "", "",
+			"cannot evaluate field %q: %s",
+			path,
+			microerror.JSON(err)))
+		return "", false
+	}
+
+	return value, true
+}
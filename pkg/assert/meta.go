@@ -2,81 +2,76 @@ package assert
 
 import (
 	"fmt"
-	"testing"
 
 	"sigs.k8s.io/cluster-api/util"
 )
 
-func LabelIsSet(t *testing.T, object TestedObject, label string) {
+func LabelIsSet(t TestingT, object TestedObject, label string) {
+	t.Helper()
+
 	_, isAnnotationSet := object.GetLabels()[label]
 	if !isAnnotationSet {
-		t.Fatalf("%s '%s/%s': expected that label %q is set",
-			object.GetObjectKind().GroupVersionKind().Kind,
-			object.GetNamespace(),
-			object.GetName(),
-			label)
+		report(t, newViolation(object, "", "",
+			"expected that label %q is set",
+			label))
 	}
 }
 
-func AnnotationIsSet(t *testing.T, object TestedObject, annotation string) {
+func AnnotationIsSet(t TestingT, object TestedObject, annotation string) {
+	t.Helper()
+
 	_, isAnnotationSet := object.GetAnnotations()[annotation]
 	if !isAnnotationSet {
-		t.Fatalf("%s '%s/%s': expected that annotation %q is set",
-			object.GetObjectKind().GroupVersionKind().Kind,
-			object.GetNamespace(),
-			object.GetName(),
-			annotation)
+		report(t, newViolation(object, "", "",
+			"expected that annotation %q is set",
+			annotation))
 	}
 }
 
-func LabelIsEqual(t *testing.T, referenceObject TestedObject, otherObject TestedObject, label string) {
+func LabelIsEqual(t TestingT, referenceObject TestedObject, otherObject TestedObject, label string) {
+	t.Helper()
+
 	referenceLabel := referenceObject.GetLabels()[label]
 	otherLabel := otherObject.GetLabels()[label]
 	referenceObjectKind := referenceObject.GetObjectKind()
-	otherObjectKind := otherObject.GetObjectKind()
 
 	if otherLabel != referenceLabel {
-		t.Fatalf("%s '%s/%s': expected label %q to have value %q (to match %s CR), but got %q",
-			otherObjectKind.GroupVersionKind().Kind,
-			otherObject.GetNamespace(),
-			otherObject.GetName(),
+		report(t, newViolation(otherObject, referenceLabel, otherLabel,
+			"expected label %q to have value %q (to match %s CR), but got %q",
 			label,
 			referenceLabel,
 			referenceObjectKind.GroupVersionKind().Kind,
-			otherLabel)
+			otherLabel))
 	}
 }
 
-func AnnotationIsEqual(t *testing.T, referenceObject TestedObject, otherObject TestedObject, annotation string) {
+func AnnotationIsEqual(t TestingT, referenceObject TestedObject, otherObject TestedObject, annotation string) {
+	t.Helper()
+
 	referenceAnnotation := referenceObject.GetAnnotations()[annotation]
 	otherAnnotation := otherObject.GetAnnotations()[annotation]
 	referenceObjectKind := referenceObject.GetObjectKind()
-	otherObjectKind := otherObject.GetObjectKind()
 
 	if otherAnnotation != referenceAnnotation {
-		t.Fatalf("%s '%s/%s': expected annotation %q to have value %q (to match %s CR), but got %q",
-			otherObjectKind.GroupVersionKind().Kind,
-			otherObject.GetNamespace(),
-			otherObject.GetName(),
+		report(t, newViolation(otherObject, referenceAnnotation, otherAnnotation,
+			"expected annotation %q to have value %q (to match %s CR), but got %q",
 			annotation,
 			referenceAnnotation,
 			referenceObjectKind.GroupVersionKind().Kind,
-			otherAnnotation)
+			otherAnnotation))
 	}
 }
 
-func ExpectedOwnerReferenceIsSet(t *testing.T, obj TestedObject, expectedOwner TestedObject) {
-	objectName := obj.GetName()
-	objectKind := obj.GetObjectKind().GroupVersionKind().Kind
+func ExpectedOwnerReferenceIsSet(t TestingT, obj TestedObject, expectedOwner TestedObject) {
+	t.Helper()
+
 	expectedOwnerGVK := expectedOwner.GetObjectKind().GroupVersionKind()
 	expectedOwnerGVKString := fmt.Sprintf("%s (%s)", expectedOwnerGVK.Kind, expectedOwnerGVK.GroupVersion())
 
 	if !util.IsOwnedByObject(obj, expectedOwner) {
-		t.Fatalf(
-			"%s %q does not have owner reference set to expected %s %q",
-			objectKind,
-			objectName,
+		report(t, newViolation(obj, fmt.Sprintf("%s %q", expectedOwnerGVKString, expectedOwner.GetName()), "",
+			"does not have owner reference set to expected %s %q",
 			expectedOwnerGVKString,
-			expectedOwner.GetName())
+			expectedOwner.GetName()))
 	}
 }
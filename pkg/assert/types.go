@@ -9,3 +9,10 @@ type TestedObject interface {
 	metav1.Object
 	runtime.Object
 }
+
+// TestingT is the subset of *testing.T used by the assertions. It is
+// implemented by *testing.T and by *Collector.
+type TestingT interface {
+	Helper()
+	Fatalf(format string, args ...interface{})
+}
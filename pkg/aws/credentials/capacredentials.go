@@ -0,0 +1,168 @@
+package credentials
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awscredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// identityGroup is the API group of CAPA identities, which are served in
+	// the version of the AWSCluster or AWSManagedControlPlane referencing them.
+	identityGroup = "infrastructure.cluster.x-k8s.io"
+
+	controllerIdentityKind = "AWSClusterControllerIdentity"
+	roleIdentityKind       = "AWSClusterRoleIdentity"
+	staticIdentityKind     = "AWSClusterStaticIdentity"
+
+	// ControllerNamespaceEnvVarName names the namespace CAPA runs in, which
+	// holds the Secrets of AWSClusterStaticIdentities.
+	ControllerNamespaceEnvVarName = "CAPA_CONTROLLER_NAMESPACE"
+	defaultControllerNamespace    = "giantswarm"
+
+	// maxIdentityChain limits the number of roles assumed through
+	// sourceIdentityRef, which could otherwise loop.
+	maxIdentityChain = 5
+)
+
+// ForCluster returns a session with the credentials of the CAPA identity of
+// the cluster, in the region of the cluster. The identity is referenced by
+// the AWSCluster or, for EKS clusters, by the AWSManagedControlPlane.
+// AWSClusterControllerIdentity uses the default credentials of the plugin,
+// e.g. from its environment or service account.
+func ForCluster(ctx context.Context, client ctrl.Client, cluster *capi.Cluster) (*session.Session, error) {
+	ref := cluster.Spec.InfrastructureRef
+	if ref != nil && ref.Kind == "AWSManagedCluster" {
+		ref = cluster.Spec.ControlPlaneRef
+	}
+	if ref == nil {
+		return nil, microerror.Maskf(executionFailedError, "cluster %q does not reference an AWSCluster or AWSManagedControlPlane", cluster.Name)
+	}
+
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = cluster.Namespace
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(ref.APIVersion)
+	obj.SetKind(ref.Kind)
+	err := client.Get(ctx, ctrl.ObjectKey{Namespace: namespace, Name: ref.Name}, obj)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	region, _, _ := unstructured.NestedString(obj.Object, "spec", "region")
+	if region == "" {
+		return nil, microerror.Maskf(executionFailedError, "%s %q does not have a region", ref.Kind, ref.Name)
+	}
+
+	kind, _, _ := unstructured.NestedString(obj.Object, "spec", "identityRef", "kind")
+	name, _, _ := unstructured.NestedString(obj.Object, "spec", "identityRef", "name")
+	apiVersion := schema.GroupVersion{Group: identityGroup, Version: obj.GroupVersionKind().Version}.String()
+
+	s, err := identitySession(ctx, client, region, apiVersion, kind, name, 0)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return s, nil
+}
+
+func identitySession(ctx context.Context, client ctrl.Client, region string, apiVersion string, kind string, name string, depth int) (*session.Session, error) {
+	if depth > maxIdentityChain {
+		return nil, microerror.Maskf(executionFailedError, "more than %d identities chained through sourceIdentityRef", maxIdentityChain)
+	}
+
+	config := &aws.Config{
+		Region: aws.String(region),
+	}
+
+	switch kind {
+	case "", controllerIdentityKind:
+		// Default credentials chain.
+
+	case staticIdentityKind:
+		identity, err := getIdentity(ctx, client, apiVersion, kind, name)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		secretName, _, _ := unstructured.NestedString(identity.Object, "spec", "secretRef")
+
+		secret := &corev1.Secret{}
+		err = client.Get(ctx, ctrl.ObjectKey{Namespace: controllerNamespace(), Name: secretName}, secret)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		config.Credentials = awscredentials.NewStaticCredentials(string(secret.Data["AccessKeyID"]), string(secret.Data["SecretAccessKey"]), string(secret.Data["SessionToken"]))
+
+	case roleIdentityKind:
+		identity, err := getIdentity(ctx, client, apiVersion, kind, name)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		roleARN, _, _ := unstructured.NestedString(identity.Object, "spec", "roleARN")
+		externalID, _, _ := unstructured.NestedString(identity.Object, "spec", "externalID")
+		sessionName, _, _ := unstructured.NestedString(identity.Object, "spec", "sessionName")
+		sourceKind, _, _ := unstructured.NestedString(identity.Object, "spec", "sourceIdentityRef", "kind")
+		sourceName, _, _ := unstructured.NestedString(identity.Object, "spec", "sourceIdentityRef", "name")
+
+		source, err := identitySession(ctx, client, region, apiVersion, sourceKind, sourceName, depth+1)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		config.Credentials = stscreds.NewCredentials(source, roleARN, func(p *stscreds.AssumeRoleProvider) {
+			if externalID != "" {
+				p.ExternalID = aws.String(externalID)
+			}
+			if sessionName != "" {
+				p.RoleSessionName = sessionName
+			}
+		})
+
+	default:
+		return nil, microerror.Maskf(executionFailedError, "unsupported identity kind %q", kind)
+	}
+
+	s, err := session.NewSession(config)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return s, nil
+}
+
+func getIdentity(ctx context.Context, client ctrl.Client, apiVersion string, kind string, name string) (*unstructured.Unstructured, error) {
+	identity := &unstructured.Unstructured{}
+	identity.SetAPIVersion(apiVersion)
+	identity.SetKind(kind)
+
+	err := client.Get(ctx, ctrl.ObjectKey{Name: name}, identity)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return identity, nil
+}
+
+func controllerNamespace() string {
+	if namespace := os.Getenv(ControllerNamespaceEnvVarName); namespace != "" {
+		return namespace
+	}
+
+	return defaultControllerNamespace
+}
@@ -0,0 +1,177 @@
+package credentials
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_ForCluster(t *testing.T) {
+	awsCluster := &corev1.ObjectReference{APIVersion: "infrastructure.cluster.x-k8s.io/v1beta2", Kind: "AWSCluster", Name: "abc12"}
+
+	testCases := []struct {
+		name                string
+		objects             []ctrl.Object
+		infrastructureRef   *corev1.ObjectReference
+		controlPlaneRef     *corev1.ObjectReference
+		expectedAccessKeyID string
+		expectedErr         bool
+	}{
+		{
+			name: "case 0: controller identity",
+			objects: []ctrl.Object{
+				newTestAWSObject(awsCluster, "eu-west-1", controllerIdentityKind, "default"),
+			},
+			infrastructureRef: awsCluster,
+		},
+		{
+			name: "case 1: static identity in the version of the AWSCluster",
+			objects: []ctrl.Object{
+				newTestAWSObject(&corev1.ObjectReference{APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1", Kind: "AWSCluster", Name: "abc12"}, "eu-west-1", staticIdentityKind, "static"),
+				newTestIdentity("infrastructure.cluster.x-k8s.io/v1beta1", staticIdentityKind, "static", map[string]interface{}{"secretRef": "static-credentials"}),
+				newTestSecret("static-credentials", "AKIASTATIC"),
+			},
+			infrastructureRef:   &corev1.ObjectReference{APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1", Kind: "AWSCluster", Name: "abc12"},
+			expectedAccessKeyID: "AKIASTATIC",
+		},
+		{
+			name: "case 2: static identity of an EKS cluster",
+			objects: []ctrl.Object{
+				newTestAWSObject(&corev1.ObjectReference{APIVersion: "controlplane.cluster.x-k8s.io/v1beta2", Kind: "AWSManagedControlPlane", Name: "abc12"}, "eu-west-1", staticIdentityKind, "static"),
+				newTestIdentity("infrastructure.cluster.x-k8s.io/v1beta2", staticIdentityKind, "static", map[string]interface{}{"secretRef": "static-credentials"}),
+				newTestSecret("static-credentials", "AKIAEKS"),
+			},
+			infrastructureRef:   &corev1.ObjectReference{APIVersion: "infrastructure.cluster.x-k8s.io/v1beta2", Kind: "AWSManagedCluster", Name: "abc12"},
+			controlPlaneRef:     &corev1.ObjectReference{APIVersion: "controlplane.cluster.x-k8s.io/v1beta2", Kind: "AWSManagedControlPlane", Name: "abc12"},
+			expectedAccessKeyID: "AKIAEKS",
+		},
+		{
+			name: "case 3: role identity assumed from a static identity",
+			objects: []ctrl.Object{
+				newTestAWSObject(awsCluster, "eu-west-1", roleIdentityKind, "role"),
+				newTestIdentity("infrastructure.cluster.x-k8s.io/v1beta2", roleIdentityKind, "role", map[string]interface{}{
+					"roleARN":           "arn:aws:iam::123456789012:role/capa",
+					"sourceIdentityRef": map[string]interface{}{"kind": staticIdentityKind, "name": "static"},
+				}),
+				newTestIdentity("infrastructure.cluster.x-k8s.io/v1beta2", staticIdentityKind, "static", map[string]interface{}{"secretRef": "static-credentials"}),
+				newTestSecret("static-credentials", "AKIASTATIC"),
+			},
+			infrastructureRef: awsCluster,
+		},
+		{
+			name: "case 4: role identity assumed from itself",
+			objects: []ctrl.Object{
+				newTestAWSObject(awsCluster, "eu-west-1", roleIdentityKind, "role"),
+				newTestIdentity("infrastructure.cluster.x-k8s.io/v1beta2", roleIdentityKind, "role", map[string]interface{}{
+					"roleARN":           "arn:aws:iam::123456789012:role/capa",
+					"sourceIdentityRef": map[string]interface{}{"kind": roleIdentityKind, "name": "role"},
+				}),
+			},
+			infrastructureRef: awsCluster,
+			expectedErr:       true,
+		},
+		{
+			name: "case 5: unsupported identity kind",
+			objects: []ctrl.Object{
+				newTestAWSObject(awsCluster, "eu-west-1", "AWSClusterUnknownIdentity", "unknown"),
+			},
+			infrastructureRef: awsCluster,
+			expectedErr:       true,
+		},
+		{
+			name: "case 6: AWSCluster without region",
+			objects: []ctrl.Object{
+				newTestAWSObject(awsCluster, "", controllerIdentityKind, "default"),
+			},
+			infrastructureRef: awsCluster,
+			expectedErr:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			err := corev1.AddToScheme(scheme)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.objects...).Build()
+
+			cluster := &capi.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "abc12", Namespace: "org-test"},
+				Spec: capi.ClusterSpec{
+					InfrastructureRef: tc.infrastructureRef,
+					ControlPlaneRef:   tc.controlPlaneRef,
+				},
+			}
+
+			s, err := ForCluster(context.Background(), client, cluster)
+			if tc.expectedErr {
+				if !IsExecutionFailed(err) {
+					t.Fatalf("expected executionFailedError, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *s.Config.Region != "eu-west-1" {
+				t.Fatalf("expected region eu-west-1, got %q", *s.Config.Region)
+			}
+			if tc.expectedAccessKeyID != "" {
+				value, err := s.Config.Credentials.Get()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if value.AccessKeyID != tc.expectedAccessKeyID {
+					t.Fatalf("expected access key ID %q, got %q", tc.expectedAccessKeyID, value.AccessKeyID)
+				}
+			}
+		})
+	}
+}
+
+func newTestAWSObject(ref *corev1.ObjectReference, region string, identityKind string, identityName string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"identityRef": map[string]interface{}{"kind": identityKind, "name": identityName},
+		},
+	}}
+	obj.SetAPIVersion(ref.APIVersion)
+	obj.SetKind(ref.Kind)
+	obj.SetNamespace("org-test")
+	obj.SetName(ref.Name)
+	if region != "" {
+		_ = unstructured.SetNestedField(obj.Object, region, "spec", "region")
+	}
+
+	return obj
+}
+
+func newTestIdentity(apiVersion string, kind string, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	identity := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	identity.SetAPIVersion(apiVersion)
+	identity.SetKind(kind)
+	identity.SetName(name)
+
+	return identity
+}
+
+func newTestSecret(name string, accessKeyID string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: defaultControllerNamespace, Name: name},
+		Data: map[string][]byte{
+			"AccessKeyID":     []byte(accessKeyID),
+			"SecretAccessKey": []byte("secret"),
+		},
+	}
+}
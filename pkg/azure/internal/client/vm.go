@@ -0,0 +1,30 @@
+package client
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest"
+)
+
+// Type wrapper
+type VM *compute.VirtualMachine
+
+// VMClient wraps an Azure SDK VirtualMachinesClient.
+type VMClient struct {
+	compute.VirtualMachinesClient
+}
+
+func NewVMClient(authorizer autorest.Authorizer, subscriptionID string) *VMClient {
+	client := compute.NewVirtualMachinesClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	return &VMClient{
+		VirtualMachinesClient: client,
+	}
+}
+
+func (c *VMClient) Get(ctx context.Context, resourceGroupName, vmName string) (VM, error) {
+	vm, err := c.VirtualMachinesClient.Get(ctx, resourceGroupName, vmName, "")
+	return &vm, err
+}
@@ -9,3 +9,7 @@ type ResourceGroupsClient interface {
 type VMSSClient interface {
 	Get(ctx context.Context, resourceGroupName, vmssName string) (VMSS, error)
 }
+
+type VMClient interface {
+	Get(ctx context.Context, resourceGroupName, vmName string) (VM, error)
+}
@@ -6,6 +6,7 @@ import "github.com/giantswarm/sonobuoy-plugin/v5/pkg/azure/internal/client"
 type Client struct {
 	ResourceGroup ResourceGroupsClient
 	VMSS          VMSSClient
+	VM            VMClient
 }
 
 /*
@@ -13,3 +14,4 @@ type Client struct {
  */
 
 type VMSS = client.VMSS
+type VM = client.VM
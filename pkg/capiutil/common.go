@@ -2,73 +2,57 @@ package capiutil
 
 import (
 	"context"
-	"reflect"
+	"fmt"
 	"testing"
 	"time"
 
-	"github.com/giantswarm/backoff"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	corev1 "k8s.io/api/core/v1"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 	capiconditions "sigs.k8s.io/cluster-api/util/conditions"
-)
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
 
-// WaitForCondition checks if the specified object obj passes the specified
-// condition check. If the check fails, it will sleep 1 minute and check again.
-// When the check is passed, the function just returns.
-func WaitForCondition(t *testing.T, ctx context.Context, logger micrologger.Logger, obj TestedObject, conditionType capi.ConditionType, check ConditionCheck, objGetterFunc ObjGetterFunc) {
-	objectName := obj.GetName()
-	objectKind := obj.GetObjectKind().GroupVersionKind().Kind
-	retryInterval := backoff.LongMaxInterval
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
+)
 
-	o := func() error {
-		updatedObject := objGetterFunc(objectName)
+// WaitForCondition waits until the specified object obj passes the specified
+// condition check, watching it with ctrlClient. obj is updated with the last
+// observed state of the object. The test fails when the check does not pass
+// within 20 minutes.
+func WaitForCondition(t *testing.T, ctx context.Context, logger micrologger.Logger, ctrlClient ctrl.Client, obj TestedObject, conditionType capi.ConditionType, check ConditionCheck) {
+	predicate := func(ctrl.Object) (bool, string) {
+		currentConditionValue := capiconditions.Get(obj, conditionType)
+		if currentConditionValue == nil {
+			return check(obj, conditionType), fmt.Sprintf("condition %q is not set", conditionType)
+		}
 
-		// Underlying type for TestedObject is a pointer to some struct, so this
-		// is like saying:
-		//
-		//    *obj = *updatedObject
-		//
-		// The object obj that is passed into the function will be modified.
-		reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(updatedObject).Elem())
+		return check(obj, conditionType), fmt.Sprintf("condition %q has Status=%q, Reason=%q", conditionType, currentConditionValue.Status, currentConditionValue.Reason)
+	}
 
-		conditionCheckPassed := check(obj, conditionType)
-		if conditionCheckPassed {
-			return nil
-		}
+	WaitForPredicate(t, ctx, logger, ctrlClient, obj, fmt.Sprintf("pass the check of condition %q", conditionType), predicate)
+}
 
-		currentConditionValue := capiconditions.Get(obj, conditionType)
-		if currentConditionValue != nil {
-			return microerror.Maskf(
-				unexpectedConditionValueError,
-				"%s %q currently has condition %q with Status=%q, Reason=%q, waiting %s before checking again...",
-				objectKind,
-				objectName,
-				conditionType,
-				currentConditionValue.Status,
-				currentConditionValue.Reason,
-				retryInterval)
-		} else {
-			return microerror.Maskf(
-				unexpectedConditionValueError,
-				"%s %q does not have condition %q, waiting %s before checking again...",
-				objectName,
-				objectKind,
-				conditionType,
-				retryInterval)
-		}
+// WaitForPredicate waits until predicate holds for the specified object obj,
+// watching it with ctrlClient. obj is updated with the last observed state of
+// the object. The test fails when predicate does not hold within 20 minutes.
+func WaitForPredicate(t *testing.T, ctx context.Context, logger micrologger.Logger, ctrlClient ctrl.Client, obj ctrl.Object, awaited string, predicate wait.Predicate) {
+	waiter, err := wait.New(wait.Config{
+		Logger:  logger,
+		Client:  ctrlClient,
+		Timeout: 20 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("error creating waiter: %s", microerror.JSON(err))
 	}
 
-	b := backoff.NewExponential(20*time.Minute, retryInterval)
-	n := backoff.NewNotifier(logger, ctx)
-	err := backoff.RetryNotify(o, b, n)
+	// The client drops the GroupVersionKind of typed objects it decodes.
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	defer obj.GetObjectKind().SetGroupVersionKind(gvk)
+
+	err = waiter.UntilPredicate(ctx, obj, awaited, predicate)
 	if err != nil {
-		t.Fatalf(
-			"error while waiting for condition %q on %s %q",
-			conditionType,
-			objectKind,
-			objectName)
+		t.Fatalf("error while waiting for %T %q to %s: %s", obj, obj.GetName(), awaited, microerror.JSON(err))
 	}
 }
 
@@ -0,0 +1,32 @@
+package capiutil
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	kcp "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+)
+
+// Condition types and reasons set by Giant Swarm operators on top of the
+// upstream Cluster API ones.
+const (
+	CreatingCondition       capi.ConditionType = "Creating"
+	UpgradingCondition      capi.ConditionType = "Upgrading"
+	NodePoolsReadyCondition capi.ConditionType = "NodePoolsReady"
+	ReplicasReadyCondition  capi.ConditionType = "ReplicasReady"
+
+	CreationCompletedReason = "CreationCompleted"
+)
+
+// TimelineKinds are the kinds whose condition transitions are recorded while
+// the tests run, see timeline.Recorder.
+var TimelineKinds = []schema.GroupVersionKind{
+	capi.GroupVersion.WithKind("Cluster"),
+	kcp.GroupVersion.WithKind("KubeadmControlPlane"),
+	capi.GroupVersion.WithKind("MachineDeployment"),
+	capiexp.GroupVersion.WithKind("MachinePool"),
+	capz.GroupVersion.WithKind("AzureCluster"),
+	capzexp.GroupVersion.WithKind("AzureMachinePool"),
+}
@@ -29,11 +29,11 @@ func IsTooManyObjectsError(err error) bool {
 	return microerror.Cause(err) == tooManyObjectsError
 }
 
-var unexpectedConditionValueError = &microerror.Error{
-	Kind: "unexpectedConditionValue",
+var nodeRefNotSetError = &microerror.Error{
+	Kind: "nodeRefNotSetError",
 }
 
-// IsUnexpectedConditionValue asserts unexpectedConditionValueError.
-func IsUnexpectedConditionValue(err error) bool {
-	return microerror.Cause(err) == unexpectedConditionValueError
+// IsNodeRefNotSet asserts nodeRefNotSetError.
+func IsNodeRefNotSet(err error) bool {
+	return microerror.Cause(err) == nodeRefNotSetError
 }
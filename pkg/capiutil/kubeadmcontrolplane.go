@@ -0,0 +1,33 @@
+package capiutil
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	kcp "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FindKubeadmControlPlane returns the `KubeadmControlPlane` referenced by
+// `Cluster.Spec.ControlPlaneRef`. It returns notFoundError when the control
+// plane of the cluster is not managed by a `KubeadmControlPlane`.
+func FindKubeadmControlPlane(ctx context.Context, client ctrl.Client, cluster *capi.Cluster) (*kcp.KubeadmControlPlane, error) {
+	ref := cluster.Spec.ControlPlaneRef
+	if ref == nil || ref.Kind != "KubeadmControlPlane" {
+		return nil, microerror.Maskf(notFoundError, "control plane of cluster %q is not a KubeadmControlPlane", cluster.Name)
+	}
+
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = cluster.Namespace
+	}
+
+	kubeadmControlPlane := &kcp.KubeadmControlPlane{}
+	err := client.Get(ctx, ctrl.ObjectKey{Namespace: namespace, Name: ref.Name}, kubeadmControlPlane)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return kubeadmControlPlane, nil
+}
@@ -0,0 +1,133 @@
+package capiutil
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FindMachinesForCluster returns list of `Machine` belonging to the specified
+// cluster ID, both control plane and worker ones.
+func FindMachinesForCluster(ctx context.Context, client ctrl.Client, clusterID string) ([]capi.Machine, error) {
+	var machineList capi.MachineList
+	err := client.List(ctx, &machineList, ctrl.MatchingLabels{capi.ClusterNameLabel: clusterID})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return machineList.Items, nil
+}
+
+// FindNonTestingMachinesForCluster returns list of `Machine` belonging to the
+// specified cluster ID.
+// It filters out `Machine` of node pools created by other e2e tests.
+func FindNonTestingMachinesForCluster(ctx context.Context, client ctrl.Client, clusterID string) ([]capi.Machine, error) {
+	// e2eNodePools holds the kind and name of the e2e node pools, e.g.
+	// MachinePool/abc12-e2e.
+	e2eNodePools := map[string]bool{}
+	{
+		machineDeployments, err := FindAllMachineDeploymentsForCluster(ctx, client, clusterID)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		for _, machineDeployment := range machineDeployments {
+			_, isE2E := machineDeployment.Labels[E2ENodepool]
+			if isE2E {
+				e2eNodePools["MachineDeployment/"+machineDeployment.Name] = true
+			}
+		}
+
+		machinePools, err := FindAllMachinePoolsForCluster(ctx, client, clusterID)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		for _, machinePool := range machinePools {
+			_, isE2E := machinePool.Labels[E2ENodepool]
+			if isE2E {
+				e2eNodePools["MachinePool/"+machinePool.Name] = true
+			}
+		}
+	}
+
+	var machines []capi.Machine
+	{
+		machineList, err := FindMachinesForCluster(ctx, client, clusterID)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		for _, machine := range machineList {
+			// Machines of a MachineDeployment are owned by its MachineSets,
+			// but carry its name in a label.
+			isE2E := e2eNodePools["MachineDeployment/"+machine.Labels[capi.MachineDeploymentNameLabel]]
+			for _, owner := range machine.OwnerReferences {
+				isE2E = isE2E || e2eNodePools[owner.Kind+"/"+owner.Name]
+			}
+			if isE2E {
+				continue
+			}
+
+			machines = append(machines, machine)
+		}
+	}
+
+	return machines, nil
+}
+
+// FindControlPlaneMachinesForCluster returns list of control plane `Machine`
+// belonging to the specified cluster ID.
+func FindControlPlaneMachinesForCluster(ctx context.Context, client ctrl.Client, clusterID string) ([]capi.Machine, error) {
+	var machineList capi.MachineList
+	err := client.List(ctx, &machineList, ctrl.MatchingLabels{capi.ClusterNameLabel: clusterID}, ctrl.HasLabels{capi.MachineControlPlaneLabel})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return machineList.Items, nil
+}
+
+// FindMachinesForMachineDeployment returns list of `Machine` created by the
+// specified `MachineDeployment`.
+func FindMachinesForMachineDeployment(ctx context.Context, client ctrl.Client, machineDeployment *capi.MachineDeployment) ([]capi.Machine, error) {
+	var machineList capi.MachineList
+	err := client.List(ctx, &machineList,
+		ctrl.InNamespace(machineDeployment.Namespace),
+		ctrl.MatchingLabels{
+			capi.ClusterNameLabel:           machineDeployment.Spec.ClusterName,
+			capi.MachineDeploymentNameLabel: machineDeployment.Name,
+		},
+	)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return machineList.Items, nil
+}
+
+// FindNodeForMachine returns the workload cluster `Node` referenced by
+// `Machine.Status.NodeRef`, using a client of the workload cluster.
+func FindNodeForMachine(ctx context.Context, tcClient ctrl.Client, machine *capi.Machine) (*corev1.Node, error) {
+	if machine.Status.NodeRef == nil {
+		return nil, microerror.Maskf(nodeRefNotSetError, "Machine %q does not reference a Node yet", machine.Name)
+	}
+
+	node := &corev1.Node{}
+	err := tcClient.Get(ctx, ctrl.ObjectKey{Name: machine.Status.NodeRef.Name}, node)
+	if apierrors.IsNotFound(err) {
+		return nil, microerror.Maskf(notFoundError, "can't find Node %q referenced by Machine %q", machine.Status.NodeRef.Name, machine.Name)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if machine.Status.NodeRef.UID != "" && node.UID != machine.Status.NodeRef.UID {
+		return nil, microerror.Maskf(notFoundError, "Node %q referenced by Machine %q was replaced, UID is %q instead of %q", node.Name, machine.Name, node.UID, machine.Status.NodeRef.UID)
+	}
+
+	return node, nil
+}
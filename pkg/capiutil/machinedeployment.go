@@ -0,0 +1,62 @@
+package capiutil
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FindMachineDeployment returns the `MachineDeployment` with the specified
+// name belonging to the specified cluster ID.
+func FindMachineDeployment(ctx context.Context, client ctrl.Client, clusterID string, machineDeploymentName string) (*capi.MachineDeployment, error) {
+	machineDeployments, err := FindAllMachineDeploymentsForCluster(ctx, client, clusterID)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for i := range machineDeployments {
+		if machineDeployments[i].Name == machineDeploymentName {
+			return &machineDeployments[i], nil
+		}
+	}
+
+	return nil, microerror.Maskf(notFoundError, "can't find MachineDeployment %q of cluster %q", machineDeploymentName, clusterID)
+}
+
+// FindNonTestingMachineDeploymentsForCluster returns list of `MachineDeployment`
+// belonging to the specified cluster ID.
+// It filters out potential `MachineDeployment` created by other e2e tests.
+func FindNonTestingMachineDeploymentsForCluster(ctx context.Context, client ctrl.Client, clusterID string) ([]capi.MachineDeployment, error) {
+	var machineDeployments []capi.MachineDeployment
+	{
+		machineDeploymentList, err := FindAllMachineDeploymentsForCluster(ctx, client, clusterID)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		for _, machineDeployment := range machineDeploymentList {
+			_, isE2E := machineDeployment.Labels[E2ENodepool]
+			if isE2E {
+				continue
+			}
+
+			machineDeployments = append(machineDeployments, machineDeployment)
+		}
+	}
+
+	return machineDeployments, nil
+}
+
+// FindAllMachineDeploymentsForCluster returns list of `MachineDeployment`
+// belonging to the specified cluster ID.
+func FindAllMachineDeploymentsForCluster(ctx context.Context, client ctrl.Client, clusterID string) ([]capi.MachineDeployment, error) {
+	var machineDeploymentList capi.MachineDeploymentList
+	err := client.List(ctx, &machineDeploymentList, ctrl.MatchingLabels{capi.ClusterNameLabel: clusterID})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return machineDeploymentList.Items, nil
+}
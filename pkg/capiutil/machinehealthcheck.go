@@ -0,0 +1,28 @@
+package capiutil
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FindMachineHealthChecksForCluster returns list of `MachineHealthCheck`
+// targeting the machines of the specified cluster.
+func FindMachineHealthChecksForCluster(ctx context.Context, client ctrl.Client, cluster *capi.Cluster) ([]capi.MachineHealthCheck, error) {
+	var machineHealthCheckList capi.MachineHealthCheckList
+	err := client.List(ctx, &machineHealthCheckList, ctrl.InNamespace(cluster.Namespace))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var machineHealthChecks []capi.MachineHealthCheck
+	for _, machineHealthCheck := range machineHealthCheckList.Items {
+		if machineHealthCheck.Spec.ClusterName == cluster.Name {
+			machineHealthChecks = append(machineHealthChecks, machineHealthCheck)
+		}
+	}
+
+	return machineHealthChecks, nil
+}
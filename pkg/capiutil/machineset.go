@@ -0,0 +1,28 @@
+package capiutil
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FindMachineSetsForMachineDeployment returns list of `MachineSet` created by
+// the specified `MachineDeployment`, including the ones of previous rollouts
+// that were scaled down to zero.
+func FindMachineSetsForMachineDeployment(ctx context.Context, client ctrl.Client, machineDeployment *capi.MachineDeployment) ([]capi.MachineSet, error) {
+	var machineSetList capi.MachineSetList
+	err := client.List(ctx, &machineSetList,
+		ctrl.InNamespace(machineDeployment.Namespace),
+		ctrl.MatchingLabels{
+			capi.ClusterNameLabel:           machineDeployment.Spec.ClusterName,
+			capi.MachineDeploymentNameLabel: machineDeployment.Name,
+		},
+	)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return machineSetList.Items, nil
+}
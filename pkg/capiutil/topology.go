@@ -0,0 +1,147 @@
+package capiutil
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Topology is the managed topology of a cluster created from a ClusterClass,
+// resolved against its ClusterClass.
+type Topology struct {
+	ClusterClass *capi.ClusterClass
+	// Version is the Kubernetes version of the control plane and of all
+	// worker MachineDeployments.
+	Version      string
+	ControlPlane capi.ControlPlaneTopology
+	Workers      []TopologyMachineDeployment
+	// Variables are the values of the ClusterClass variables, set in the
+	// Cluster or defaulted by the ClusterClass.
+	Variables map[string]apiextensionsv1.JSON
+}
+
+// TopologyMachineDeployment is a worker MachineDeployment of the topology
+// along with the ClusterClass worker class it is created from.
+type TopologyMachineDeployment struct {
+	capi.MachineDeploymentTopology
+
+	// Class is nil when the ClusterClass does not define the worker class.
+	Class *capi.MachineDeploymentClass
+}
+
+// IsTopologyManaged returns true when the cluster is created from a
+// ClusterClass and its objects are generated by the topology controller.
+func IsTopologyManaged(cluster *capi.Cluster) bool {
+	return cluster.Spec.Topology != nil
+}
+
+// FindClusterClass returns the `ClusterClass` the cluster is created from.
+func FindClusterClass(ctx context.Context, client ctrl.Client, cluster *capi.Cluster) (*capi.ClusterClass, error) {
+	if !IsTopologyManaged(cluster) {
+		return nil, microerror.Maskf(notFoundError, "cluster %q is not created from a ClusterClass", cluster.Name)
+	}
+
+	clusterClass := &capi.ClusterClass{}
+	err := client.Get(ctx, ctrl.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Spec.Topology.Class}, clusterClass)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return clusterClass, nil
+}
+
+// ResolveTopology returns the topology of the cluster with its worker classes
+// and variables resolved against the ClusterClass.
+func ResolveTopology(ctx context.Context, client ctrl.Client, cluster *capi.Cluster) (*Topology, error) {
+	clusterClass, err := FindClusterClass(ctx, client, cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	topology := &Topology{
+		ClusterClass: clusterClass,
+		Version:      cluster.Spec.Topology.Version,
+		ControlPlane: cluster.Spec.Topology.ControlPlane,
+		Variables:    map[string]apiextensionsv1.JSON{},
+	}
+
+	for _, variable := range clusterClass.Spec.Variables {
+		if variable.Schema.OpenAPIV3Schema.Default != nil {
+			topology.Variables[variable.Name] = *variable.Schema.OpenAPIV3Schema.Default
+		}
+	}
+	for _, variable := range cluster.Spec.Topology.Variables {
+		topology.Variables[variable.Name] = variable.Value
+	}
+
+	if cluster.Spec.Topology.Workers != nil {
+		for _, machineDeployment := range cluster.Spec.Topology.Workers.MachineDeployments {
+			worker := TopologyMachineDeployment{
+				MachineDeploymentTopology: machineDeployment,
+			}
+
+			for i := range clusterClass.Spec.Workers.MachineDeployments {
+				if clusterClass.Spec.Workers.MachineDeployments[i].Class == machineDeployment.Class {
+					worker.Class = &clusterClass.Spec.Workers.MachineDeployments[i]
+				}
+			}
+
+			topology.Workers = append(topology.Workers, worker)
+		}
+	}
+
+	return topology, nil
+}
+
+// FindTopologyMachineDeployment returns the `MachineDeployment` generated for
+// the worker MachineDeployment of the topology with the specified name.
+func FindTopologyMachineDeployment(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, name string) (*capi.MachineDeployment, error) {
+	var machineDeploymentList capi.MachineDeploymentList
+	err := client.List(ctx, &machineDeploymentList,
+		ctrl.InNamespace(cluster.Namespace),
+		ctrl.MatchingLabels{
+			capi.ClusterNameLabel:                          cluster.Name,
+			capi.ClusterTopologyMachineDeploymentNameLabel: name,
+		},
+	)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if len(machineDeploymentList.Items) == 0 {
+		return nil, microerror.Maskf(notFoundError, "can't find MachineDeployment generated for topology %q of cluster %q", name, cluster.Name)
+	} else if len(machineDeploymentList.Items) > 1 {
+		return nil, microerror.Maskf(tooManyObjectsError, "found %d MachineDeployments generated for topology %q of cluster %q", len(machineDeploymentList.Items), name, cluster.Name)
+	}
+
+	return &machineDeploymentList.Items[0], nil
+}
+
+// FindControlPlane returns the control plane object referenced by
+// `Cluster.Spec.ControlPlaneRef`, whatever its kind.
+func FindControlPlane(ctx context.Context, client ctrl.Client, cluster *capi.Cluster) (*unstructured.Unstructured, error) {
+	ref := cluster.Spec.ControlPlaneRef
+	if ref == nil {
+		return nil, microerror.Maskf(notFoundError, "cluster %q does not reference a control plane", cluster.Name)
+	}
+
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = cluster.Namespace
+	}
+
+	controlPlane := &unstructured.Unstructured{}
+	controlPlane.SetAPIVersion(ref.APIVersion)
+	controlPlane.SetKind(ref.Kind)
+
+	err := client.Get(ctx, ctrl.ObjectKey{Namespace: namespace, Name: ref.Name}, controlPlane)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return controlPlane, nil
+}
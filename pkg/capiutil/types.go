@@ -16,6 +16,3 @@ type TestedObject interface {
 // ConditionCheck is a function interface for checking a condition of specified
 // type for the specified object.
 type ConditionCheck func(cluster conditions.Getter, conditionType capi.ConditionType) bool
-
-// ObjGetterFunc is a getter function for fetching a CR with specified name.
-type ObjGetterFunc func(name string) TestedObject
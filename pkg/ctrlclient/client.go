@@ -1,11 +1,8 @@
 package ctrlclient
 
 import (
-	"os"
-
 	"github.com/giantswarm/microerror"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -14,48 +11,56 @@ const (
 	TenantClusterKubeconfigContents = "TC_KUBECONFIG"
 )
 
-func GetCPKubeConfig() ([]byte, error) {
-	kubeConfig, exists := os.LookupEnv(ControlPlaneKubeconfigContents)
-	if !exists {
-		return nil, microerror.Maskf(missingEnvironmentVariable, "the %s env var is required", ControlPlaneKubeconfigContents)
+func GetCPRESTConfig() (*rest.Config, error) {
+	clients, err := sharedClients(ControlPlane)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
-	return []byte(kubeConfig), nil
+	return rest.CopyConfig(clients.RESTConfig), nil
 }
 
-func GetTCKubeConfig() ([]byte, error) {
-	kubeConfig, exists := os.LookupEnv(TenantClusterKubeconfigContents)
-	if !exists {
-		return nil, microerror.Maskf(missingEnvironmentVariable, "the %s env var is required", TenantClusterKubeconfigContents)
+func GetTCRESTConfig() (*rest.Config, error) {
+	clients, err := sharedClients(TenantCluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
-	return []byte(kubeConfig), nil
+	return rest.CopyConfig(clients.RESTConfig), nil
 }
 
+// CreateTCCtrlClient returns the Tenant Cluster client shared by everything
+// not running as part of a test. Tests should use ForTest instead.
 func CreateTCCtrlClient() (client.Client, error) {
-	kubeConfig, err := GetTCKubeConfig()
+	clients, err := sharedClients(TenantCluster)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+	return clients.CtrlClient, nil
+}
+
+// CreateCPCtrlClient returns the Control Plane client shared by everything
+// not running as part of a test. Tests should use ForTest instead.
+func CreateCPCtrlClient() (client.Client, error) {
+	clients, err := sharedClients(ControlPlane)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	return client.New(rest.CopyConfig(restConfig), client.Options{Scheme: Scheme})
+	return clients.CtrlClient, nil
 }
 
-func CreateCPCtrlClient() (client.Client, error) {
-	kubeConfig, err := GetCPKubeConfig()
+func sharedClients(cluster Cluster) (*Clients, error) {
+	f, err := DefaultFactory()
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+	clients, err := f.Clients(cluster, "")
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	return client.New(restConfig, client.Options{Scheme: Scheme})
+	return clients, nil
 }
@@ -4,6 +4,24 @@ import (
 	"github.com/giantswarm/microerror"
 )
 
-var missingEnvironmentVariable = &microerror.Error{
-	Kind: "missingEnvironmentVariable",
+var notConfiguredError = &microerror.Error{
+	Kind: "notConfiguredError",
+}
+
+// IsNotConfigured asserts notConfiguredError.
+func IsNotConfigured(err error) bool {
+	return microerror.Cause(err) == notConfiguredError
+}
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+var fixtureNotFoundError = &microerror.Error{
+	Kind: "fixtureNotFoundError",
+}
+
+// IsFixtureNotFound asserts fixtureNotFoundError.
+func IsFixtureNotFound(err error) bool {
+	return microerror.Cause(err) == fixtureNotFoundError
 }
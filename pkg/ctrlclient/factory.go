@@ -0,0 +1,397 @@
+package ctrlclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	ClientQPS   = "KUBE_CLIENT_QPS"
+	ClientBurst = "KUBE_CLIENT_BURST"
+
+	userAgent = "giantswarm-sonobuoy-plugin"
+
+	// discoveryFixtures is the name the API discovery requests shared by all
+	// the tests are recorded under.
+	discoveryFixtures = "_discovery"
+)
+
+type Cluster string
+
+const (
+	ControlPlane  Cluster = "CP"
+	TenantCluster Cluster = "TC"
+)
+
+// Clients are the clients of a single cluster, sharing the same REST config.
+type Clients struct {
+	RESTConfig *rest.Config
+	CtrlClient client.Client
+	Clientset  kubernetes.Interface
+}
+
+type FactoryConfig struct {
+	Resolvers map[Cluster]Resolver
+	// QPS and Burst configure the client side rate limiting of every client.
+	// The client-go defaults are used when zero.
+	QPS   float32
+	Burst int
+	// Fixtures records or replays the requests of the test clients when set.
+	Fixtures *Fixtures
+}
+
+// Factory creates the clients used by the tests. The REST config, transport
+// and REST mapper of every cluster are created only once and shared by the
+// clients of all the tests, so that kubeconfigs are resolved, TLS connections
+// established and API discovery done once per run. The clients of every test
+// are cached too, so tests and their helpers can ask for clients as often as
+// they need to.
+type Factory struct {
+	resolvers map[Cluster]Resolver
+	qps       float32
+	burst     int
+	fixtures  *Fixtures
+
+	mutex    sync.Mutex
+	clusters map[Cluster]*clusterClients
+	clients  map[clientsKey]*Clients
+	requests map[clientsKey]*int64
+	tests    map[string]bool
+}
+
+type clientsKey struct {
+	cluster Cluster
+	test    string
+}
+
+// clusterClients is what the clients of every test of a cluster share.
+type clusterClients struct {
+	restConfig *rest.Config
+	transport  http.RoundTripper
+	mapper     meta.RESTMapper
+}
+
+func NewFactory(config FactoryConfig) (*Factory, error) {
+	if len(config.Resolvers) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Resolvers must not be empty", config)
+	}
+
+	f := &Factory{
+		resolvers: config.Resolvers,
+		qps:       config.QPS,
+		burst:     config.Burst,
+		fixtures:  config.Fixtures,
+
+		clusters: map[Cluster]*clusterClients{},
+		clients:  map[clientsKey]*Clients{},
+		requests: map[clientsKey]*int64{},
+		tests:    map[string]bool{},
+	}
+
+	return f, nil
+}
+
+// Clients returns the clients of cluster for the given test. The requests
+// sent by the clients are identified by the test name in their user agent
+// and counted per test.
+func (f *Factory) Clients(cluster Cluster, test string) (*Clients, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	key := clientsKey{cluster: cluster, test: test}
+
+	if clients, ok := f.clients[key]; ok {
+		return clients, nil
+	}
+
+	shared, err := f.cluster(cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	requests := new(int64)
+	f.requests[key] = requests
+
+	// Only the round trippers counting, recording or replaying the requests
+	// of the test are created per test. They wrap the shared transport.
+	wrap := func(rt http.RoundTripper) http.RoundTripper {
+		if f.fixtures != nil && test != "" {
+			rt = f.fixtures.Wrap(cluster, test)(rt)
+		}
+
+		return &countingRoundTripper{next: rt, requests: requests}
+	}
+
+	testUserAgent := userAgent
+	if test != "" {
+		testUserAgent = fmt.Sprintf("%s (%s)", userAgent, test)
+	}
+
+	// Watching clients let the helpers of pkg/wait react to changes instead
+	// of polling.
+	ctrlClient, err := client.NewWithWatch(transportConfig(shared.restConfig, testUserAgent, wrap(shared.transport)), client.Options{Scheme: Scheme, Mapper: shared.mapper})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(transportConfig(shared.restConfig, testUserAgent, wrap(shared.transport)))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// The REST config keeps the credentials, as streaming requests like exec
+	// and port-forward need a transport of their own.
+	restConfig := rest.CopyConfig(shared.restConfig)
+	restConfig.UserAgent = testUserAgent
+	restConfig.Wrap(wrap)
+
+	clients := &Clients{
+		RESTConfig: restConfig,
+		CtrlClient: ctrlClient,
+		Clientset:  clientset,
+	}
+	f.clients[key] = clients
+
+	return clients, nil
+}
+
+// Requests returns the number of API requests sent to cluster by the clients
+// of the given test.
+func (f *Factory) Requests(cluster Cluster, test string) int64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	requests, ok := f.requests[clientsKey{cluster: cluster, test: test}]
+	if !ok {
+		return 0
+	}
+
+	return atomic.LoadInt64(requests)
+}
+
+// ForTest returns the clients of t. The number of API requests sent by them
+// is logged when t finishes.
+func (f *Factory) ForTest(t testing.TB) *TestClients {
+	f.mutex.Lock()
+	registered := f.tests[t.Name()]
+	f.tests[t.Name()] = true
+	f.mutex.Unlock()
+
+	if !registered {
+		t.Cleanup(func() {
+			if f.fixtures != nil {
+				for _, test := range []string{t.Name(), discoveryFixtures} {
+					err := f.fixtures.Save(test)
+					if err != nil {
+						t.Errorf("error saving API fixtures: %s", microerror.JSON(err))
+					}
+				}
+			}
+
+			cp := f.Requests(ControlPlane, t.Name())
+			tc := f.Requests(TenantCluster, t.Name())
+			if cp+tc > 0 {
+				t.Logf("API requests: %s=%d %s=%d", ControlPlane, cp, TenantCluster, tc)
+			}
+		})
+	}
+
+	return &TestClients{
+		factory: f,
+		test:    t.Name(),
+	}
+}
+
+func (f *Factory) cluster(cluster Cluster) (*clusterClients, error) {
+	if shared, ok := f.clusters[cluster]; ok {
+		return shared, nil
+	}
+
+	var restConfig *rest.Config
+	if f.fixtures != nil && f.fixtures.Mode() == ReplayMode {
+		restConfig = f.fixtures.RESTConfig(cluster)
+	} else {
+		resolver, ok := f.resolvers[cluster]
+		if !ok {
+			return nil, microerror.Maskf(invalidConfigError, "no resolver for cluster %q", cluster)
+		}
+
+		resolved, err := resolver.Resolve(context.Background())
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		restConfig = rest.CopyConfig(resolved)
+	}
+
+	restConfig.UserAgent = userAgent
+	if f.qps != 0 {
+		restConfig.QPS = f.qps
+	}
+	if f.burst != 0 {
+		restConfig.Burst = f.burst
+	}
+
+	transport, err := rest.TransportFor(restConfig)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Discovery requests are not part of any test, so they are recorded
+	// and replayed on their own.
+	discoveryTransport := transport
+	if f.fixtures != nil {
+		discoveryTransport = f.fixtures.Wrap(cluster, discoveryFixtures)(transport)
+	}
+
+	mapper, err := apiutil.NewDynamicRESTMapper(transportConfig(restConfig, userAgent, discoveryTransport), apiutil.WithLazyDiscovery)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	shared := &clusterClients{
+		restConfig: restConfig,
+		transport:  transport,
+		mapper:     mapper,
+	}
+	f.clusters[cluster] = shared
+
+	return shared, nil
+}
+
+// transportConfig returns a REST config sending requests through transport,
+// which already carries the TLS settings and credentials of restConfig, so
+// that clients created from it share the connections of transport.
+func transportConfig(restConfig *rest.Config, userAgent string, transport http.RoundTripper) *rest.Config {
+	return &rest.Config{
+		Host:      restConfig.Host,
+		APIPath:   restConfig.APIPath,
+		UserAgent: userAgent,
+		QPS:       restConfig.QPS,
+		Burst:     restConfig.Burst,
+		Timeout:   restConfig.Timeout,
+		Transport: transport,
+	}
+}
+
+// TestClients gives access to the clients of a single test.
+type TestClients struct {
+	factory *Factory
+	test    string
+}
+
+func (tc *TestClients) CP() (*Clients, error) {
+	return tc.factory.Clients(ControlPlane, tc.test)
+}
+
+func (tc *TestClients) TC() (*Clients, error) {
+	return tc.factory.Clients(TenantCluster, tc.test)
+}
+
+func (tc *TestClients) CPCtrlClient() (client.Client, error) {
+	clients, err := tc.CP()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return clients.CtrlClient, nil
+}
+
+func (tc *TestClients) TCCtrlClient() (client.Client, error) {
+	clients, err := tc.TC()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return clients.CtrlClient, nil
+}
+
+var (
+	defaultFactory     *Factory
+	defaultFactoryErr  error
+	defaultFactoryOnce sync.Once
+)
+
+// DefaultFactory returns the process wide factory using CPResolver and
+// TCResolver, rate limited according to the KUBE_CLIENT_QPS and
+// KUBE_CLIENT_BURST env vars and recording or replaying API fixtures
+// according to the KUBE_FIXTURES_MODE and KUBE_FIXTURES_DIR env vars.
+func DefaultFactory() (*Factory, error) {
+	defaultFactoryOnce.Do(func() {
+		defaultFactory, defaultFactoryErr = newDefaultFactory()
+	})
+
+	return defaultFactory, defaultFactoryErr
+}
+
+// ForTest returns the clients of t created by the default factory.
+func ForTest(t testing.TB) *TestClients {
+	t.Helper()
+
+	f, err := DefaultFactory()
+	if err != nil {
+		t.Fatalf("error creating client factory: %s", microerror.JSON(err))
+	}
+
+	return f.ForTest(t)
+}
+
+func newDefaultFactory() (*Factory, error) {
+	config := FactoryConfig{
+		Resolvers: map[Cluster]Resolver{
+			ControlPlane:  CPResolver,
+			TenantCluster: TCResolver,
+		},
+	}
+
+	if v := os.Getenv(ClientQPS); v != "" {
+		qps, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "the %s env var must be a number, got %q", ClientQPS, v)
+		}
+		config.QPS = float32(qps)
+	}
+
+	if v := os.Getenv(ClientBurst); v != "" {
+		burst, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "the %s env var must be an integer, got %q", ClientBurst, v)
+		}
+		config.Burst = burst
+	}
+
+	fixtures, err := FixturesFromEnv()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	config.Fixtures = fixtures
+
+	f, err := NewFactory(config)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f, nil
+}
+
+type countingRoundTripper struct {
+	next     http.RoundTripper
+	requests *int64
+}
+
+func (rt *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(rt.requests, 1)
+	return rt.next.RoundTrip(req)
+}
@@ -0,0 +1,422 @@
+package ctrlclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/giantswarm/microerror"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	KubeFixturesMode = "KUBE_FIXTURES_MODE"
+	KubeFixturesDir  = "KUBE_FIXTURES_DIR"
+
+	defaultFixturesDir = "fixtures"
+)
+
+type FixturesMode string
+
+const (
+	// RecordMode sends the requests to the clusters and saves all the
+	// interactions of every test into golden files.
+	RecordMode FixturesMode = "record"
+	// ReplayMode serves the responses from the golden files without
+	// contacting any cluster.
+	ReplayMode FixturesMode = "replay"
+)
+
+// Interaction is a single request sent to a cluster and its response.
+type Interaction struct {
+	Method       string `json:"method"`
+	URL          string `json:"url"`
+	StatusCode   int    `json:"statusCode"`
+	ContentType  string `json:"contentType,omitempty"`
+	ResponseBody string `json:"responseBody"`
+}
+
+// Fixtures records the API interactions of every test into
+// <dir>/<test name>/<cluster>.json, or replays them from there.
+//
+// Requests are matched by method and URL. Matching requests are answered
+// with the recorded responses in order, and the last response is repeated
+// once they are exhausted, so that polling loops terminating after a
+// different number of iterations still replay. Tests creating objects with
+// random names, e.g. node pools, send different requests on every run and
+// cannot be replayed, and neither can streaming requests like exec and
+// port-forward.
+//
+// The data of Secrets is redacted before the fixtures are written, as they
+// hold credentials like kubeconfigs and app secret values. Fixtures are
+// still only readable by their owner.
+type Fixtures struct {
+	mode FixturesMode
+	dir  string
+
+	mutex        sync.Mutex
+	interactions map[clientsKey][]*Interaction
+	replays      map[clientsKey]*replay
+}
+
+func NewFixtures(mode FixturesMode, dir string) (*Fixtures, error) {
+	if mode != RecordMode && mode != ReplayMode {
+		return nil, microerror.Maskf(invalidConfigError, "fixtures mode must be %q or %q, got %q", RecordMode, ReplayMode, mode)
+	}
+	if dir == "" {
+		return nil, microerror.Maskf(invalidConfigError, "fixtures dir must not be empty")
+	}
+
+	f := &Fixtures{
+		mode: mode,
+		dir:  dir,
+
+		interactions: map[clientsKey][]*Interaction{},
+		replays:      map[clientsKey]*replay{},
+	}
+
+	return f, nil
+}
+
+// FixturesFromEnv returns the fixtures configured with the KUBE_FIXTURES_MODE
+// and KUBE_FIXTURES_DIR env vars, or nil when KUBE_FIXTURES_MODE is not set.
+func FixturesFromEnv() (*Fixtures, error) {
+	mode := os.Getenv(KubeFixturesMode)
+	if mode == "" {
+		return nil, nil
+	}
+
+	dir := os.Getenv(KubeFixturesDir)
+	if dir == "" {
+		dir = defaultFixturesDir
+	}
+
+	f, err := NewFixtures(FixturesMode(mode), dir)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f, nil
+}
+
+func (f *Fixtures) Mode() FixturesMode {
+	return f.mode
+}
+
+// RESTConfig returns the REST config used in replay mode in place of the
+// resolved one.
+func (f *Fixtures) RESTConfig(cluster Cluster) *rest.Config {
+	return &rest.Config{
+		Host: fmt.Sprintf("https://%s.replay.invalid", cluster),
+	}
+}
+
+// Wrap returns the transport wrapper recording or replaying the requests
+// sent to cluster by the clients of test.
+func (f *Fixtures) Wrap(cluster Cluster, test string) func(http.RoundTripper) http.RoundTripper {
+	key := clientsKey{cluster: cluster, test: test}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		if f.mode == ReplayMode {
+			return &replayRoundTripper{fixtures: f, key: key}
+		}
+
+		return &recordRoundTripper{fixtures: f, key: key, next: next}
+	}
+}
+
+// Save writes the interactions recorded for test. It is a no-op in replay
+// mode.
+func (f *Fixtures) Save(test string) error {
+	if f.mode != RecordMode {
+		return nil
+	}
+
+	for _, cluster := range []Cluster{ControlPlane, TenantCluster} {
+		key := clientsKey{cluster: cluster, test: test}
+
+		f.mutex.Lock()
+		var interactions []*Interaction
+		for _, interaction := range f.interactions[key] {
+			redacted := *interaction
+			redacted.ResponseBody = redactSecrets(interaction.URL, interaction.ResponseBody)
+			interactions = append(interactions, &redacted)
+		}
+		f.mutex.Unlock()
+
+		if len(interactions) == 0 {
+			continue
+		}
+
+		data, err := json.MarshalIndent(interactions, "", "  ")
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		path := f.path(key)
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = os.WriteFile(path, data, 0600)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// redactSecrets returns body with the data of every Secret in it replaced by
+// empty values. body holds a single object or list, or the events of a
+// watch. The parts of bodies of Secret requests that can't be decoded, e.g.
+// protobuf ones or watch events cut off when the client stopped reading, are
+// dropped.
+func redactSecrets(url string, body string) string {
+	secrets := strings.Contains(url, "/secrets")
+	if !secrets && !strings.Contains(body, `"Secret`) {
+		return body
+	}
+
+	var redacted bytes.Buffer
+
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	encoder := json.NewEncoder(&redacted)
+	for {
+		var value interface{}
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			if secrets {
+				return redacted.String()
+			}
+			return body
+		}
+
+		err = encoder.Encode(redactSecretValue(value))
+		if err != nil {
+			return ""
+		}
+	}
+
+	return redacted.String()
+}
+
+func redactSecretValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		switch v["kind"] {
+		case "Secret":
+			redactSecret(v)
+		case "SecretList":
+			items, _ := v["items"].([]interface{})
+			for _, item := range items {
+				if secret, ok := item.(map[string]interface{}); ok {
+					redactSecret(secret)
+				}
+			}
+		}
+
+		for k, nested := range v {
+			v[k] = redactSecretValue(nested)
+		}
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = redactSecretValue(nested)
+		}
+	}
+
+	return value
+}
+
+func redactSecret(secret map[string]interface{}) {
+	if data, ok := secret["data"].(map[string]interface{}); ok {
+		for k := range data {
+			data[k] = ""
+		}
+	}
+	delete(secret, "stringData")
+
+	// The last applied configuration annotation holds the data too.
+	if metadata, ok := secret["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "managedFields")
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+		}
+	}
+}
+
+func (f *Fixtures) path(key clientsKey) string {
+	return filepath.Join(f.dir, filepath.FromSlash(key.test), string(key.cluster)+".json")
+}
+
+func (f *Fixtures) record(key clientsKey, interaction *Interaction) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.interactions[key] = append(f.interactions[key], interaction)
+}
+
+func (f *Fixtures) replay(key clientsKey) (*replay, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if r, ok := f.replays[key]; ok {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(f.path(key))
+	if err != nil {
+		return nil, microerror.Maskf(fixtureNotFoundError, "cannot read fixtures of test %q: %s", key.test, err)
+	}
+
+	var interactions []*Interaction
+	err = json.Unmarshal(data, &interactions)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	r := &replay{
+		responses: map[string][]*Interaction{},
+		served:    map[string]int{},
+	}
+	for _, interaction := range interactions {
+		requestKey := interaction.Method + " " + interaction.URL
+		r.responses[requestKey] = append(r.responses[requestKey], interaction)
+	}
+	f.replays[key] = r
+
+	return r, nil
+}
+
+type replay struct {
+	mutex     sync.Mutex
+	responses map[string][]*Interaction
+	served    map[string]int
+}
+
+func (r *replay) next(method string, url string) (*Interaction, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	requestKey := method + " " + url
+
+	responses := r.responses[requestKey]
+	if len(responses) == 0 {
+		return nil, false
+	}
+
+	i := r.served[requestKey]
+	if i >= len(responses) {
+		i = len(responses) - 1
+	}
+	r.served[requestKey]++
+
+	return responses[i], true
+}
+
+type recordRoundTripper struct {
+	fixtures *Fixtures
+	key      clientsKey
+	next     http.RoundTripper
+}
+
+func (rt *recordRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := rt.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if httpstream.IsUpgradeRequest(req) {
+		return res, nil
+	}
+
+	interaction := &Interaction{
+		Method:      req.Method,
+		URL:         req.URL.RequestURI(),
+		StatusCode:  res.StatusCode,
+		ContentType: res.Header.Get("Content-Type"),
+	}
+	rt.fixtures.record(rt.key, interaction)
+
+	// The body is recorded while it is read, so that long running requests
+	// like watches are recorded up to the point the client stops reading.
+	res.Body = &recordingBody{
+		ReadCloser: res.Body,
+		onClose: func(body []byte) {
+			rt.fixtures.mutex.Lock()
+			interaction.ResponseBody = string(body)
+			rt.fixtures.mutex.Unlock()
+		},
+	}
+
+	return res, nil
+}
+
+type recordingBody struct {
+	io.ReadCloser
+	buf     bytes.Buffer
+	onClose func([]byte)
+	once    sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.once.Do(func() { b.onClose(b.buf.Bytes()) })
+	return b.ReadCloser.Close()
+}
+
+type replayRoundTripper struct {
+	fixtures *Fixtures
+	key      clientsKey
+}
+
+func (rt *replayRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if httpstream.IsUpgradeRequest(req) {
+		return nil, microerror.Maskf(fixtureNotFoundError, "streaming request %s %s cannot be replayed", req.Method, req.URL.RequestURI())
+	}
+
+	r, err := rt.fixtures.replay(rt.key)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	interaction, ok := r.next(req.Method, req.URL.RequestURI())
+	if !ok {
+		return nil, microerror.Maskf(fixtureNotFoundError, "no recorded response for %s %s in test %q", req.Method, req.URL.RequestURI(), rt.key.test)
+	}
+
+	header := http.Header{}
+	if interaction.ContentType != "" {
+		header.Set("Content-Type", interaction.ContentType)
+	}
+
+	res := &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.StatusCode, http.StatusText(interaction.StatusCode)),
+		StatusCode:    interaction.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewBufferString(interaction.ResponseBody)),
+		ContentLength: int64(len(interaction.ResponseBody)),
+		Request:       req,
+	}
+
+	return res, nil
+}
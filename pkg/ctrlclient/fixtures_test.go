@@ -0,0 +1,133 @@
+package ctrlclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_Fixtures(t *testing.T) {
+	dir := t.TempDir()
+
+	responses := map[string][]string{
+		"/api/v1/namespaces/default/configmaps/test": {
+			`{"kind":"ConfigMap","apiVersion":"v1","metadata":{"name":"test","resourceVersion":"1"}}`,
+			`{"kind":"ConfigMap","apiVersion":"v1","metadata":{"name":"test","resourceVersion":"2"}}`,
+		},
+		"/api/v1/namespaces/default/secrets/test": {
+			`{"kind":"Secret","apiVersion":"v1","metadata":{"name":"test"},"data":{"kubeconfig":"c2VjcmV0"}}`,
+		},
+		"/api/v1/namespaces/default/secrets": {
+			`{"kind":"SecretList","apiVersion":"v1","items":[{"metadata":{"name":"test"},"data":{"kubeconfig":"c2VjcmV0"}}]}`,
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body := responses[r.URL.Path][0]
+		if len(responses[r.URL.Path]) > 1 {
+			responses[r.URL.Path] = responses[r.URL.Path][1:]
+		}
+		_, _ = io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	get := func(rt http.RoundTripper, host string, path string) (string, error) {
+		req, err := http.NewRequest(http.MethodGet, host+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := rt.RoundTrip(req)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(body), nil
+	}
+
+	// Record.
+	{
+		fixtures, err := NewFixtures(RecordMode, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rt := fixtures.Wrap(ControlPlane, "Test_Something")(http.DefaultTransport)
+		for _, path := range []string{
+			"/api/v1/namespaces/default/configmaps/test",
+			"/api/v1/namespaces/default/configmaps/test",
+			"/api/v1/namespaces/default/secrets/test",
+			"/api/v1/namespaces/default/secrets",
+		} {
+			_, err = get(rt, server.URL, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err = fixtures.Save("Test_Something")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := os.Stat(fixtures.path(clientsKey{cluster: ControlPlane, test: "Test_Something"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Fatalf("expected fixtures to be written with mode 0600, got %v", info.Mode().Perm())
+		}
+
+		data, err := os.ReadFile(fixtures.path(clientsKey{cluster: ControlPlane, test: "Test_Something"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "c2VjcmV0") {
+			t.Fatalf("expected Secret data to be redacted, got %s", data)
+		}
+	}
+
+	// Replay.
+	{
+		fixtures, err := NewFixtures(ReplayMode, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rt := fixtures.Wrap(ControlPlane, "Test_Something")(nil)
+		host := "https://cp.replay.invalid"
+
+		for _, resourceVersion := range []string{"1", "2", "2"} {
+			body, err := get(rt, host, "/api/v1/namespaces/default/configmaps/test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body, `"resourceVersion":"`+resourceVersion+`"`) {
+				t.Fatalf("expected resource version %s to be replayed, got %s", resourceVersion, body)
+			}
+		}
+
+		body, err := get(rt, host, "/api/v1/namespaces/default/secrets/test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(body, `"kubeconfig":""`) {
+			t.Fatalf("expected the redacted Secret to be replayed, got %s", body)
+		}
+
+		_, err = get(rt, host, "/api/v1/namespaces/default/configmaps/other")
+		if !IsFixtureNotFound(err) {
+			t.Fatalf("expected fixtureNotFoundError for a request that was not recorded, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package ctrlclient
+
+import (
+	"context"
+	"os"
+
+	"github.com/giantswarm/microerror"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/cluster-api/util/kubeconfig"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
+)
+
+const (
+	ControlPlaneKubeconfigPath  = "CP_KUBECONFIG_PATH"
+	TenantClusterKubeconfigPath = "TC_KUBECONFIG_PATH"
+
+	ClusterID = "CLUSTER_ID"
+)
+
+// Resolver returns the REST config of a cluster. It returns a
+// notConfiguredError when the source it reads from is not configured, so
+// that resolvers can be chained. Env vars set to an empty value count as not
+// configured, as the plugin manifest declares all of them.
+type Resolver interface {
+	Resolve(ctx context.Context) (*rest.Config, error)
+}
+
+type ResolverFunc func(ctx context.Context) (*rest.Config, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context) (*rest.Config, error) {
+	return f(ctx)
+}
+
+// CPResolver resolves the Control Plane REST config from, in order, the
+// kubeconfig contents in CP_KUBECONFIG, the kubeconfig file at
+// CP_KUBECONFIG_PATH and the in-cluster service account.
+var CPResolver Resolver = Chain(
+	FromEnvContents(ControlPlaneKubeconfigContents),
+	FromEnvPath(ControlPlaneKubeconfigPath),
+	InCluster(),
+)
+
+// TCResolver resolves the Tenant Cluster REST config from, in order, the
+// kubeconfig contents in TC_KUBECONFIG, the kubeconfig file at
+// TC_KUBECONFIG_PATH and the <cluster>-kubeconfig Secret of the CLUSTER_ID
+// cluster in the Control Plane.
+var TCResolver Resolver = Chain(
+	FromEnvContents(TenantClusterKubeconfigContents),
+	FromEnvPath(TenantClusterKubeconfigPath),
+	FromClusterSecret(CPResolver, ClusterID),
+)
+
+// Chain returns a Resolver trying every resolver in order and returning the
+// first configured one.
+func Chain(resolvers ...Resolver) Resolver {
+	return ResolverFunc(func(ctx context.Context) (*rest.Config, error) {
+		for _, r := range resolvers {
+			restConfig, err := r.Resolve(ctx)
+			if IsNotConfigured(err) {
+				continue
+			} else if err != nil {
+				return nil, microerror.Mask(err)
+			}
+
+			return restConfig, nil
+		}
+
+		return nil, microerror.Maskf(notConfiguredError, "none of the kubeconfig sources is configured")
+	})
+}
+
+// FromEnvContents reads the kubeconfig contents from the env var.
+func FromEnvContents(envVar string) Resolver {
+	return ResolverFunc(func(ctx context.Context) (*rest.Config, error) {
+		kubeConfig := os.Getenv(envVar)
+		if kubeConfig == "" {
+			return nil, microerror.Maskf(notConfiguredError, "the %s env var is not set", envVar)
+		}
+
+		restConfig, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeConfig))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return restConfig, nil
+	})
+}
+
+// FromEnvPath reads the kubeconfig from the file whose path is in the env
+// var.
+func FromEnvPath(envVar string) Resolver {
+	return ResolverFunc(func(ctx context.Context) (*rest.Config, error) {
+		path := os.Getenv(envVar)
+		if path == "" {
+			return nil, microerror.Maskf(notConfiguredError, "the %s env var is not set", envVar)
+		}
+
+		kubeConfig, err := os.ReadFile(path)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return restConfig, nil
+	})
+}
+
+// InCluster uses the service account of the pod the tests are running in.
+func InCluster() Resolver {
+	return ResolverFunc(func(ctx context.Context) (*rest.Config, error) {
+		restConfig, err := rest.InClusterConfig()
+		if err == rest.ErrNotInCluster {
+			return nil, microerror.Maskf(notConfiguredError, "not running in a cluster")
+		} else if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return restConfig, nil
+	})
+}
+
+// FromClusterSecret reads the kubeconfig of the cluster whose ID is in the
+// env var from its <cluster>-kubeconfig Secret, using cpResolver to access
+// the Control Plane.
+func FromClusterSecret(cpResolver Resolver, clusterIDEnvVar string) Resolver {
+	return ResolverFunc(func(ctx context.Context) (*rest.Config, error) {
+		clusterID := os.Getenv(clusterIDEnvVar)
+		if clusterID == "" {
+			return nil, microerror.Maskf(notConfiguredError, "the %s env var is not set", clusterIDEnvVar)
+		}
+
+		cpRESTConfig, err := cpResolver.Resolve(ctx)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		cpCtrlClient, err := client.New(cpRESTConfig, client.Options{Scheme: Scheme})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		cluster, err := capiutil.FindCluster(ctx, cpCtrlClient, clusterID)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		kubeConfig, err := kubeconfig.FromSecret(ctx, cpCtrlClient, client.ObjectKeyFromObject(cluster))
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return restConfig, nil
+	})
+}
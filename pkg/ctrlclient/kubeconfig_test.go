@@ -0,0 +1,55 @@
+package ctrlclient
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://test.example.com
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+users:
+- name: test
+  user:
+    token: test
+`
+
+func Test_Chain_emptyEnvVar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig.yaml")
+	err := os.WriteFile(path, []byte(testKubeconfig), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TEST_KUBECONFIG", "")
+	t.Setenv("TEST_KUBECONFIG_PATH", path)
+
+	_, err = FromEnvContents("TEST_KUBECONFIG").Resolve(context.Background())
+	if !IsNotConfigured(err) {
+		t.Fatalf("expected an empty env var to be not configured, got %v", err)
+	}
+
+	resolver := Chain(
+		FromEnvContents("TEST_KUBECONFIG"),
+		FromEnvPath("TEST_KUBECONFIG_PATH"),
+	)
+
+	restConfig, err := resolver.Resolve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restConfig.Host != "https://test.example.com" {
+		t.Fatalf("expected the kubeconfig of TEST_KUBECONFIG_PATH, got host %q", restConfig.Host)
+	}
+}
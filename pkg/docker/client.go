@@ -0,0 +1,152 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// HostEnvVarName names the Docker daemon socket, like for the docker CLI.
+	HostEnvVarName = "DOCKER_HOST"
+	defaultHost    = "unix:///var/run/docker.sock"
+
+	// apiVersion is the oldest version of the Docker Engine API with the
+	// fields used here, so older daemons are supported too.
+	apiVersion = "v1.41"
+)
+
+type Config struct {
+	// Host is the address of the Docker daemon, either unix:///path/to/socket
+	// or tcp://host:port. Defaults to $DOCKER_HOST, or the default socket.
+	Host string
+}
+
+// Client is a minimal client of the Docker Engine API.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func New(config Config) (*Client, error) {
+	if config.Host == "" {
+		config.Host = os.Getenv(HostEnvVarName)
+	}
+	if config.Host == "" {
+		config.Host = defaultHost
+	}
+
+	u, err := url.Parse(config.Host)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Host %q is not a valid URL: %s", config, config.Host, err)
+	}
+
+	c := &Client{}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		c.baseURL = "http://docker"
+		c.httpClient = &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		}
+	case "tcp", "http":
+		c.baseURL = "http://" + u.Host
+		c.httpClient = http.DefaultClient
+	default:
+		return nil, microerror.Maskf(invalidConfigError, "%T.Host %q must be a unix or tcp address", config, config.Host)
+	}
+
+	return c, nil
+}
+
+// Container is a container as listed by the Docker daemon.
+type Container struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Labels map[string]string `json:"Labels"`
+	// State is e.g. created, running or exited.
+	State string `json:"State"`
+}
+
+// Name returns the name of the container without the leading slash.
+func (c Container) Name() string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// IsRunning returns true when the container is running.
+func (c Container) IsRunning() bool {
+	return c.State == "running"
+}
+
+// ListContainers returns all containers, stopped ones included, having all
+// the given labels.
+func (c *Client) ListContainers(ctx context.Context, labels map[string]string) ([]Container, error) {
+	var labelFilters []string
+	for k, v := range labels {
+		labelFilters = append(labelFilters, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	filters, err := json.Marshal(map[string][]string{"label": labelFilters})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	params := url.Values{}
+	params.Set("all", "true")
+	params.Set("filters", string(filters))
+
+	var containers []Container
+	err = c.get(ctx, "/containers/json", params, &containers)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return containers, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, params url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s%s?%s", c.baseURL, apiVersion, path, params.Encode()), nil)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return microerror.Maskf(unexpectedResponseError, "GET %s returned %d: %s", path, res.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		return microerror.Maskf(unexpectedResponseError, "GET %s returned invalid JSON: %s", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_ListContainers(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1.41/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("all") != "true" {
+			t.Errorf("expected stopped containers to be listed, got all=%q", r.URL.Query().Get("all"))
+		}
+
+		var filters map[string][]string
+		err := json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+		if err != nil {
+			t.Error(err)
+		}
+		if !reflect.DeepEqual(filters["label"], []string{"io.x-k8s.kind.cluster=abc12"}) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"message":"unexpected filters %v"}`, filters)
+			return
+		}
+
+		fmt.Fprint(w, `[{"Id":"1","Names":["/abc12-pool0-a"],"Labels":{"io.x-k8s.kind.cluster":"abc12"},"State":"running"},{"Id":"2","Names":["/abc12-pool0-b"],"Labels":{"io.x-k8s.kind.cluster":"abc12"},"State":"exited"}]`)
+	})
+
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(mux)
+	server.Listener = l
+	server.Start()
+	t.Cleanup(server.Close)
+
+	c, err := New(Config{Host: "unix://" + socket})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containers, err := c.ListContainers(context.Background(), map[string]string{"io.x-k8s.kind.cluster": "abc12"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var running []string
+	for _, container := range containers {
+		if container.IsRunning() {
+			running = append(running, container.Name())
+		}
+	}
+	if !reflect.DeepEqual(running, []string{"abc12-pool0-a"}) {
+		t.Fatalf("running containers == %v, want [abc12-pool0-a]", running)
+	}
+
+	_, err = c.ListContainers(context.Background(), map[string]string{"io.x-k8s.kind.cluster": "def34"})
+	if !IsUnexpectedResponse(err) {
+		t.Fatalf("expected unexpectedResponseError, got %v", err)
+	}
+
+	_, err = New(Config{Host: "ssh://docker"})
+	if err == nil {
+		t.Fatal("expected an error for an unsupported host")
+	}
+}
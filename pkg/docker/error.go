@@ -0,0 +1,18 @@
+package docker
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+var unexpectedResponseError = &microerror.Error{
+	Kind: "unexpectedResponseError",
+}
+
+// IsUnexpectedResponse asserts unexpectedResponseError.
+func IsUnexpectedResponse(err error) bool {
+	return microerror.Cause(err) == unexpectedResponseError
+}
@@ -0,0 +1,18 @@
+package podrunner
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+var unexpectedOutcomeError = &microerror.Error{
+	Kind: "unexpectedOutcomeError",
+}
+
+// IsUnexpectedOutcome asserts unexpectedOutcomeError.
+func IsUnexpectedOutcome(err error) bool {
+	return microerror.Cause(err) == unexpectedOutcomeError
+}
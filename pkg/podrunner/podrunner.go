@@ -3,63 +3,103 @@ package podrunner
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/remotecommand"
-)
+	utilexec "k8s.io/utils/exec"
 
-func ExecInPod(ctx context.Context, logger micrologger.Logger, podName string, namespace string, containerName string, command []string, kc []byte) (string, string, error) {
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
+)
 
-	logger.Debugf(ctx, "Running %v in container %q in pod %q", command, containerName, podName)
+type ExecOptions struct {
+	PodName       string
+	Namespace     string
+	ContainerName string
+	Command       []string
+	// Stdin is streamed to the command when set.
+	Stdin io.Reader
+}
 
-	tty := true
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
 
-	restCfg, err := clientcmd.RESTConfigFromKubeConfig(kc)
-	if err != nil {
-		return "", "", microerror.Mask(err)
-	}
-	coreClient, err := kubernetes.NewForConfig(restCfg)
-	if err != nil {
-		return "", "", microerror.Mask(err)
-	}
+// Exec runs a command in a container without a TTY, so that stdout and stderr
+// are kept apart. A non-zero exit code of the command is not an error, it is
+// returned in the result. The command is aborted when ctx is done. Exec falls
+// back to the WebSocket protocol when the connection cannot be upgraded to
+// SPDY, e.g. because a proxy in between does not support it.
+func Exec(ctx context.Context, logger micrologger.Logger, clients *ctrlclient.Clients, options ExecOptions) (*ExecResult, error) {
+	logger.Debugf(ctx, "Running %v in container %q in pod %q", options.Command, options.ContainerName, options.PodName)
 
-	req := coreClient.CoreV1().RESTClient().Post().
+	req := clients.Clientset.CoreV1().RESTClient().Post().
 		Resource("pods").
-		Name(podName).
-		Namespace(namespace).
+		Name(options.PodName).
+		Namespace(options.Namespace).
 		SubResource("exec").
-		Param("container", containerName)
+		Param("container", options.ContainerName)
 	req.VersionedParams(&corev1.PodExecOptions{
-		Container: containerName,
-		Command:   command,
-		Stdin:     false,
+		Container: options.ContainerName,
+		Command:   options.Command,
+		Stdin:     options.Stdin != nil,
 		Stdout:    true,
 		Stderr:    true,
-		TTY:       tty,
+		TTY:       false,
 	}, scheme.ParameterCodec)
 
 	var stdout, stderr bytes.Buffer
-	err = execute("POST", req.URL(), restCfg, nil, &stdout, &stderr, tty)
-	return stdout.String(), stderr.String(), err
+	streamOptions := remotecommand.StreamOptions{
+		Stdin:  options.Stdin,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+
+	err := executeSPDY(ctx, http.MethodPost, req.URL(), clients.RESTConfig, streamOptions)
+	if isUpgradeFailed(err) {
+		logger.Debugf(ctx, "Cannot upgrade connection to SPDY, falling back to WebSocket: %s", err)
+
+		stdout.Reset()
+		stderr.Reset()
+		err = executeWebSocket(ctx, req.URL(), clients.RESTConfig, streamOptions)
+	}
+
+	result := &ExecResult{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitStatus()
+	} else if err != nil {
+		return result, microerror.Mask(err)
+	}
+
+	return result, nil
 }
 
-func execute(method string, url *url.URL, config *rest.Config, stdin io.Reader, stdout, stderr io.Writer, tty bool) error {
+func executeSPDY(ctx context.Context, method string, url *url.URL, config *rest.Config, options remotecommand.StreamOptions) error {
 	exec, err := remotecommand.NewSPDYExecutor(config, method, url)
 	if err != nil {
 		return err
 	}
-	return exec.Stream(remotecommand.StreamOptions{
-		Stdin:  stdin,
-		Stdout: stdout,
-		Stderr: stderr,
-		Tty:    tty,
-	})
+	return exec.StreamWithContext(ctx, options)
+}
+
+// isUpgradeFailed checks whether the server, or something in between, did
+// not switch the connection to SPDY without explaining why with an API
+// status.
+func isUpgradeFailed(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "unable to upgrade connection")
 }
@@ -0,0 +1,280 @@
+package podrunner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/giantswarm/backoff"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
+	"github.com/kyverno/kyverno/api/kyverno/v2alpha1"
+	"github.com/kyverno/kyverno/api/kyverno/v2beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
+)
+
+const (
+	probeContainerName = "probe"
+	probeScriptKey     = "script.sh"
+	probeScriptPath    = "/script.sh"
+
+	policyExceptionNamespace = "giantswarm"
+)
+
+type Outcome int
+
+const (
+	// Success expects the probe to exit with code 0.
+	Success Outcome = iota
+	// Failure expects the probe to exit with a non-zero code.
+	Failure
+	// Any accepts any exit code. The caller is expected to check the result.
+	Any
+)
+
+type ProbeConfig struct {
+	// Clients are the clients of the cluster the probe runs in.
+	Clients *ctrlclient.Clients
+	Logger  micrologger.Logger
+
+	Name      string
+	Namespace string
+	Labels    map[string]string
+
+	Image           string
+	ImagePullPolicy corev1.PullPolicy
+	// Command and Args are the command run by the probe. They are ignored
+	// when Script is set.
+	Command []string
+	Args    []string
+	// Script is a shell script run with /bin/sh, mounted into the probe from
+	// a ConfigMap.
+	Script string
+
+	// PolicyException creates a Kyverno PolicyException allowing the probe to
+	// run without the restricted security context enforced by the Pod
+	// Security Standards policies.
+	PolicyException bool
+
+	Expected Outcome
+	// Timeout is the maximum time to wait for the probe to terminate.
+	// Defaults to backoff.ShortMaxWait.
+	Timeout time.Duration
+}
+
+type ProbeResult struct {
+	ExitCode int32
+	Logs     string
+}
+
+// RunProbe runs a single container pod to completion and returns its exit
+// code and logs. It returns an unexpectedOutcomeError when the exit code does
+// not match config.Expected. All the objects created for the probe are
+// deleted before RunProbe returns.
+func RunProbe(ctx context.Context, config ProbeConfig) (*ProbeResult, error) {
+	if config.Clients == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Clients must not be empty", config)
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Name == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Name must not be empty", config)
+	}
+	if config.Namespace == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Namespace must not be empty", config)
+	}
+	if config.Image == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Image must not be empty", config)
+	}
+	if len(config.Command) == 0 && config.Script == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Command or %T.Script must not be empty", config, config)
+	}
+	if config.Timeout == 0 {
+		config.Timeout = backoff.ShortMaxWait
+	}
+
+	ctrlClient := config.Clients.CtrlClient
+
+	objects := probeObjects(config)
+
+	defer func() {
+		// Cleanup must happen even when ctx is already cancelled.
+		for i := len(objects) - 1; i >= 0; i-- {
+			obj := objects[i]
+			err := ctrlClient.Delete(context.Background(), obj)
+			if err != nil && !apierrors.IsNotFound(err) {
+				config.Logger.Errorf(ctx, err, "Failed to delete %T %q", obj, obj.GetName())
+			}
+		}
+	}()
+
+	for _, obj := range objects {
+		// Delete the object in case it's there to allow for running the probe
+		// more than once.
+		_ = ctrlClient.Delete(ctx, obj)
+
+		err := ctrlClient.Create(ctx, obj)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	config.Logger.Debugf(ctx, "Waiting for probe pod %q to terminate", config.Name)
+
+	var terminated *corev1.ContainerStateTerminated
+	{
+		waiter, err := wait.New(wait.Config{
+			Logger:  config.Logger,
+			Client:  ctrlClient,
+			Timeout: config.Timeout,
+		})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		probeTerminated := func(obj ctrl.Object) (bool, string) {
+			pod := obj.(*corev1.Pod)
+			for _, cs := range pod.Status.ContainerStatuses {
+				if cs.Name == probeContainerName && cs.State.Terminated != nil {
+					terminated = cs.State.Terminated
+					return true, ""
+				}
+			}
+
+			return false, fmt.Sprintf("pod phase is %#q", pod.Status.Phase)
+		}
+
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: config.Name, Namespace: config.Namespace}}
+		err = waiter.UntilPredicate(ctx, pod, "have a terminated probe container", probeTerminated)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	logs, err := config.Clients.Clientset.CoreV1().Pods(config.Namespace).GetLogs(config.Name, &corev1.PodLogOptions{Container: probeContainerName}).DoRaw(ctx)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	result := &ProbeResult{
+		ExitCode: terminated.ExitCode,
+		Logs:     string(logs),
+	}
+
+	switch {
+	case config.Expected == Success && result.ExitCode != 0:
+		return result, microerror.Maskf(unexpectedOutcomeError, "expected probe %q to succeed, got exit code %d, logs:\n%s", config.Name, result.ExitCode, result.Logs)
+	case config.Expected == Failure && result.ExitCode == 0:
+		return result, microerror.Maskf(unexpectedOutcomeError, "expected probe %q to fail, got exit code 0, logs:\n%s", config.Name, result.Logs)
+	}
+
+	return result, nil
+}
+
+func probeObjects(config ProbeConfig) []ctrl.Object {
+	var objects []ctrl.Object
+
+	container := corev1.Container{
+		Name:            probeContainerName,
+		Image:           config.Image,
+		ImagePullPolicy: config.ImagePullPolicy,
+		Command:         config.Command,
+		Args:            config.Args,
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      config.Name,
+			Namespace: config.Namespace,
+			Labels:    config.Labels,
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+
+	if config.Script != "" {
+		objects = append(objects, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      config.Name,
+				Namespace: config.Namespace,
+			},
+			Data: map[string]string{probeScriptKey: config.Script},
+		})
+
+		container.Command = []string{"/bin/sh"}
+		container.Args = []string{probeScriptPath}
+		container.VolumeMounts = []corev1.VolumeMount{
+			{
+				Name:      "script",
+				MountPath: probeScriptPath,
+				SubPath:   probeScriptKey,
+			},
+		}
+		pod.Spec.Volumes = []corev1.Volume{
+			{
+				Name: "script",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: config.Name,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	if config.PolicyException {
+		objects = append(objects, &v2alpha1.PolicyException{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%s", config.Namespace, config.Name),
+				Namespace: policyExceptionNamespace,
+			},
+			Spec: v2alpha1.PolicyExceptionSpec{
+				Match: v2beta1.MatchResources{
+					Any: kyvernov1.ResourceFilters{
+						{
+							ResourceDescription: kyvernov1.ResourceDescription{
+								Kinds:      []string{"Pod"},
+								Names:      []string{config.Name},
+								Namespaces: []string{config.Namespace},
+							},
+						},
+					},
+				},
+				Exceptions: []v2alpha1.Exception{
+					{
+						PolicyName: "disallow-capabilities-strict",
+						RuleNames:  []string{"require-drop-all"},
+					},
+					{
+						PolicyName: "disallow-privilege-escalation",
+						RuleNames:  []string{"privilege-escalation"},
+					},
+					{
+						PolicyName: "require-run-as-nonroot",
+						RuleNames:  []string{"run-as-non-root"},
+					},
+					{
+						PolicyName: "restrict-seccomp-strict",
+						RuleNames:  []string{"check-seccomp-strict"},
+					},
+				},
+			},
+		})
+	}
+
+	pod.Spec.Containers = []corev1.Container{container}
+
+	// The pod is created last, after the objects it depends on.
+	return append(objects, pod)
+}
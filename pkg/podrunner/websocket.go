@@ -0,0 +1,214 @@
+package podrunner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/websocket"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/utils/exec"
+)
+
+const (
+	// v4.channel.k8s.io prefixes every binary message with the number of the
+	// stream it belongs to.
+	webSocketProtocol = "v4.channel.k8s.io"
+
+	stdinChannel  = 0
+	stdoutChannel = 1
+	stderrChannel = 2
+	errorChannel  = 3
+
+	webSocketDialTimeout = 30 * time.Second
+)
+
+// executeWebSocket runs the exec request at url over the WebSocket protocol
+// supported by the API server. The protocol cannot signal the end of stdin,
+// so commands reading stdin until EOF only terminate when ctx is done.
+func executeWebSocket(ctx context.Context, url *url.URL, config *rest.Config, options remotecommand.StreamOptions) error {
+	wsConfig, err := webSocketConfig(url, config)
+	if err != nil {
+		return err
+	}
+
+	conn, err := websocket.DialConfig(wsConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	if options.Stdin != nil {
+		go func() {
+			buf := make([]byte, 32*1024)
+			for {
+				n, err := options.Stdin.Read(buf)
+				if n > 0 {
+					err := websocket.Message.Send(conn, append([]byte{stdinChannel}, buf[:n]...))
+					if err != nil {
+						return
+					}
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	var status *metav1.Status
+	for {
+		var message []byte
+		err = websocket.Message.Receive(conn, &message)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		if len(message) == 0 {
+			continue
+		}
+
+		switch message[0] {
+		case stdoutChannel:
+			if options.Stdout != nil {
+				_, _ = options.Stdout.Write(message[1:])
+			}
+		case stderrChannel:
+			if options.Stderr != nil {
+				_, _ = options.Stderr.Write(message[1:])
+			}
+		case errorChannel:
+			if len(message) > 1 {
+				status = &metav1.Status{}
+				err = json.Unmarshal(message[1:], status)
+				if err != nil {
+					return fmt.Errorf("error decoding exec status %q: %w", message[1:], err)
+				}
+			}
+		}
+	}
+
+	return statusToError(status)
+}
+
+func webSocketConfig(execURL *url.URL, config *rest.Config) (*websocket.Config, error) {
+	wsURL := *execURL
+	origin := url.URL{Scheme: wsURL.Scheme, Host: wsURL.Host}
+	switch wsURL.Scheme {
+	case "https":
+		wsURL.Scheme = "wss"
+	case "http":
+		wsURL.Scheme = "ws"
+	}
+
+	wsConfig, err := websocket.NewConfig(wsURL.String(), origin.String())
+	if err != nil {
+		return nil, err
+	}
+	wsConfig.Protocol = []string{webSocketProtocol}
+	wsConfig.Dialer = &net.Dialer{Timeout: webSocketDialTimeout}
+
+	wsConfig.TlsConfig, err = rest.TLSConfigFor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	wsConfig.Header, err = authHeaders(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return wsConfig, nil
+}
+
+// authHeaders returns the headers client-go would add to a request sent
+// with config, e.g. the bearer token or the impersonation headers.
+func authHeaders(config *rest.Config) (http.Header, error) {
+	capture := &headerCapture{}
+	rt, err := rest.HTTPWrappersForConfig(config, capture)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, config.Host, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Marking the request as an upgrade request keeps it from being recorded
+	// as an API fixture.
+	req.Header.Set(httpstream.HeaderConnection, httpstream.HeaderUpgrade)
+	req.Header.Set(httpstream.HeaderUpgrade, "websocket")
+
+	_, err = rt.RoundTrip(req)
+	if err != nil && !errors.Is(err, errHeadersCaptured) {
+		return nil, err
+	}
+
+	header := capture.header.Clone()
+	header.Del(httpstream.HeaderConnection)
+	header.Del(httpstream.HeaderUpgrade)
+
+	return header, nil
+}
+
+var errHeadersCaptured = errors.New("headers captured")
+
+type headerCapture struct {
+	header http.Header
+}
+
+func (c *headerCapture) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.header = req.Header
+	return nil, errHeadersCaptured
+}
+
+func statusToError(status *metav1.Status) error {
+	if status == nil || status.Status == metav1.StatusSuccess {
+		return nil
+	}
+
+	if status.Reason == "NonZeroExitCode" && status.Details != nil {
+		for _, cause := range status.Details.Causes {
+			if cause.Type != "ExitCode" {
+				continue
+			}
+
+			code, err := strconv.Atoi(cause.Message)
+			if err != nil {
+				return fmt.Errorf("error parsing exit code %q: %w", cause.Message, err)
+			}
+
+			return utilexec.CodeExitError{
+				Err:  fmt.Errorf("command terminated with non-zero exit code: %s", status.Message),
+				Code: code,
+			}
+		}
+	}
+
+	return fmt.Errorf("error executing remote command: %s", status.Message)
+}
@@ -0,0 +1,261 @@
+package promclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	defaultBatchConcurrency = 5
+)
+
+type Config struct {
+	// BaseURL is the URL the Prometheus HTTP API paths are appended to,
+	// including the route prefix if any, e.g. http://localhost:9090/abc12.
+	BaseURL    string
+	HTTPClient *http.Client
+	// BatchConcurrency is the maximum number of queries QueryBatch sends at
+	// the same time. Defaults to 5.
+	BatchConcurrency int
+}
+
+// Client is a client of the Prometheus HTTP API.
+type Client struct {
+	baseURL          string
+	httpClient       *http.Client
+	batchConcurrency int
+}
+
+func New(config Config) (*Client, error) {
+	if config.BaseURL == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.BaseURL must not be empty", config)
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	if config.BatchConcurrency == 0 {
+		config.BatchConcurrency = defaultBatchConcurrency
+	}
+
+	c := &Client{
+		baseURL:          strings.TrimSuffix(config.BaseURL, "/"),
+		httpClient:       config.HTTPClient,
+		batchConcurrency: config.BatchConcurrency,
+	}
+
+	return c, nil
+}
+
+// NewServiceProxy returns a client reaching Prometheus through the service
+// proxy of the API server described by restConfig.
+func NewServiceProxy(restConfig *rest.Config, namespace string, service string, port int, routePrefix string) (*Client, error) {
+	httpClient, err := rest.HTTPClientFor(restConfig)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	u, err := serverURL(restConfig)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	u.Path = fmt.Sprintf("%s/api/v1/namespaces/%s/services/%s:%d/proxy%s", strings.TrimSuffix(u.Path, "/"), namespace, service, port, routePrefix)
+
+	c, err := New(Config{
+		BaseURL:    u.String(),
+		HTTPClient: httpClient,
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return c, nil
+}
+
+// Query evaluates an instant query at ts, or at the current time when ts is
+// zero.
+func (c *Client) Query(ctx context.Context, query string, ts time.Time) (*QueryResult, error) {
+	params := url.Values{"query": []string{query}}
+	if !ts.IsZero() {
+		params.Set("time", formatTime(ts))
+	}
+
+	var result QueryResult
+	warnings, err := c.do(ctx, http.MethodPost, "/api/v1/query", params, &result)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	result.Warnings = warnings
+
+	return &result, nil
+}
+
+// QueryRange evaluates a range query.
+func (c *Client) QueryRange(ctx context.Context, query string, start time.Time, end time.Time, step time.Duration) (*QueryResult, error) {
+	params := url.Values{
+		"query": []string{query},
+		"start": []string{formatTime(start)},
+		"end":   []string{formatTime(end)},
+		"step":  []string{strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+	}
+
+	var result QueryResult
+	warnings, err := c.do(ctx, http.MethodPost, "/api/v1/query_range", params, &result)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	result.Warnings = warnings
+
+	return &result, nil
+}
+
+// QueryBatch evaluates the instant queries concurrently and returns their
+// results in the same order. It returns the first error encountered.
+func (c *Client) QueryBatch(ctx context.Context, queries []string, ts time.Time) ([]*QueryResult, error) {
+	results := make([]*QueryResult, len(queries))
+	errs := make([]error, len(queries))
+
+	sem := make(chan struct{}, c.batchConcurrency)
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, query string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i], errs[i] = c.Query(ctx, query, ts)
+		}(i, query)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return results, nil
+}
+
+func (c *Client) Targets(ctx context.Context) (*TargetsResult, error) {
+	var result TargetsResult
+	_, err := c.do(ctx, http.MethodGet, "/api/v1/targets", nil, &result)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) Rules(ctx context.Context) ([]RuleGroup, error) {
+	var result struct {
+		Groups []RuleGroup `json:"groups"`
+	}
+	_, err := c.do(ctx, http.MethodGet, "/api/v1/rules", nil, &result)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return result.Groups, nil
+}
+
+func (c *Client) Alerts(ctx context.Context) ([]Alert, error) {
+	var result struct {
+		Alerts []Alert `json:"alerts"`
+	}
+	_, err := c.do(ctx, http.MethodGet, "/api/v1/alerts", nil, &result)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return result.Alerts, nil
+}
+
+// do sends the request and decodes the data of a successful response into
+// data. It returns the warnings of the response.
+func (c *Client) do(ctx context.Context, method string, path string, params url.Values, data interface{}) ([]string, error) {
+	u := c.baseURL + path
+
+	var body io.Reader
+	if method == http.MethodGet && len(params) > 0 {
+		u += "?" + params.Encode()
+	} else if method == http.MethodPost {
+		body = strings.NewReader(params.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var r response
+	err = json.Unmarshal(b, &r)
+	if err != nil {
+		return nil, microerror.Maskf(unexpectedResponseError, "%s %s returned status %d and a body that is not an API response: %q", method, path, res.StatusCode, truncate(string(b)))
+	}
+
+	if r.Status != "success" {
+		return nil, microerror.Maskf(queryFailedError, "%s %s failed with %s: %s", method, path, r.ErrorType, r.Error)
+	}
+
+	err = json.Unmarshal(r.Data, data)
+	if err != nil {
+		return nil, microerror.Maskf(unexpectedResponseError, "cannot decode data of %s %s: %s", method, path, err)
+	}
+
+	return r.Warnings, nil
+}
+
+// serverURL returns the URL of the API server, which may be configured as a
+// plain host:port pair.
+func serverURL(restConfig *rest.Config) (*url.URL, error) {
+	host := restConfig.Host
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return u, nil
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/float64(time.Second), 'f', -1, 64)
+}
+
+func truncate(s string) string {
+	const max = 256
+	if len(s) <= max {
+		return s
+	}
+
+	return s[:max] + "..."
+}
@@ -0,0 +1,175 @@
+package promclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newFakePrometheus(t *testing.T) *Client {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/abc12/api/v1/query", func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch r.Form.Get("query") {
+		case "bad(":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+		case "scalar(1)":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"scalar","result":[1681718763.5,"1"]}}`)
+		default:
+			fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"query":%q},"value":[1681718763.145,"0"]}]}}`, r.Form.Get("query"))
+		}
+	})
+	mux.HandleFunc("/abc12/api/v1/query_range", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"kubelet"},"values":[[1681718760,"1"],[1681718790,"2"]]}]}}`)
+	})
+	mux.HandleFunc("/abc12/api/v1/targets", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"success","data":{"activeTargets":[{"labels":{"job":"kubelet"},"scrapePool":"kubelet","health":"up"},{"labels":{"job":"etcd"},"scrapePool":"etcd","health":"down","lastError":"connection refused"}],"droppedTargets":[]}}`)
+	})
+	mux.HandleFunc("/abc12/api/v1/rules", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"success","data":{"groups":[{"name":"kube","file":"kube.yaml","rules":[{"name":"KubeDown","query":"up == 0","type":"alerting","health":"ok","state":"firing","alerts":[{"labels":{"alertname":"KubeDown"},"state":"firing","value":"1"}]}]}]}}`)
+	})
+	mux.HandleFunc("/abc12/api/v1/alerts", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"success","data":{"alerts":[{"labels":{"alertname":"KubeDown"},"state":"firing","activeAt":"2023-04-17T08:06:03Z","value":"1"}]}}`)
+	})
+	mux.HandleFunc("/abc12/api/v1/status/config", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html>not found</html>`)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	c, err := New(Config{
+		BaseURL:          server.URL + "/abc12",
+		HTTPClient:       server.Client(),
+		BatchConcurrency: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func Test_Client_Query(t *testing.T) {
+	ctx := context.Background()
+	c := newFakePrometheus(t)
+
+	result, err := c.Query(ctx, "absent(up) or vector(0)", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ResultType != ResultTypeVector || len(result.Vector) != 1 {
+		t.Fatalf("expected vector with one sample, got %#v", result)
+	}
+	if result.Vector[0].Value.Value != 0 {
+		t.Fatalf("expected value 0, got %v", result.Vector[0].Value.Value)
+	}
+	if ts := result.Vector[0].Value.Timestamp; ts.Unix() != 1681718763 {
+		t.Fatalf("expected timestamp 1681718763, got %v", ts)
+	}
+
+	result, err = c.Query(ctx, "scalar(1)", time.Unix(1681718763, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Scalar == nil || result.Scalar.Value != 1 {
+		t.Fatalf("expected scalar 1, got %#v", result)
+	}
+
+	_, err = c.Query(ctx, "bad(", time.Time{})
+	if !IsQueryFailed(err) {
+		t.Fatalf("expected queryFailedError, got %#v", err)
+	}
+}
+
+func Test_Client_QueryRange(t *testing.T) {
+	c := newFakePrometheus(t)
+
+	end := time.Now()
+	result, err := c.QueryRange(context.Background(), "up", end.Add(-time.Minute), end, 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ResultType != ResultTypeMatrix || len(result.Matrix) != 1 {
+		t.Fatalf("expected matrix with one series, got %#v", result)
+	}
+	if n := len(result.Matrix[0].Values); n != 2 {
+		t.Fatalf("expected 2 values, got %d", n)
+	}
+}
+
+func Test_Client_QueryBatch(t *testing.T) {
+	c := newFakePrometheus(t)
+
+	queries := []string{"a", "b", "c", "d", "e"}
+	results, err := c.QueryBatch(context.Background(), queries, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, query := range queries {
+		if got := results[i].Vector[0].Metric["query"]; got != query {
+			t.Fatalf("expected result %d to belong to query %q, got %q", i, query, got)
+		}
+	}
+
+	_, err = c.QueryBatch(context.Background(), []string{"a", "bad(", "c"}, time.Time{})
+	if !IsQueryFailed(err) {
+		t.Fatalf("expected queryFailedError, got %#v", err)
+	}
+}
+
+func Test_Client_Targets(t *testing.T) {
+	c := newFakePrometheus(t)
+
+	targets, err := c.Targets(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(targets.ActiveTargets); n != 2 {
+		t.Fatalf("expected 2 active targets, got %d", n)
+	}
+	if target := targets.ActiveTargets[1]; target.Health != "down" || target.LastError != "connection refused" {
+		t.Fatalf("unexpected target %#v", target)
+	}
+}
+
+func Test_Client_RulesAndAlerts(t *testing.T) {
+	ctx := context.Background()
+	c := newFakePrometheus(t)
+
+	groups, err := c.Rules(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0].Rules) != 1 || len(groups[0].Rules[0].Alerts) != 1 {
+		t.Fatalf("unexpected rule groups %#v", groups)
+	}
+
+	alerts, err := c.Alerts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].State != "firing" || alerts[0].ActiveAt == nil {
+		t.Fatalf("unexpected alerts %#v", alerts)
+	}
+}
+
+func Test_Client_UnexpectedResponse(t *testing.T) {
+	c := newFakePrometheus(t)
+
+	var data interface{}
+	_, err := c.do(context.Background(), http.MethodGet, "/api/v1/status/config", nil, &data)
+	if !IsUnexpectedResponse(err) {
+		t.Fatalf("expected unexpectedResponseError, got %#v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package promclient
+
+import (
+	"github.com/giantswarm/microerror"
+)
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+var queryFailedError = &microerror.Error{
+	Kind: "queryFailedError",
+}
+
+// IsQueryFailed asserts queryFailedError.
+func IsQueryFailed(err error) bool {
+	return microerror.Cause(err) == queryFailedError
+}
+
+var unexpectedResponseError = &microerror.Error{
+	Kind: "unexpectedResponseError",
+}
+
+// IsUnexpectedResponse asserts unexpectedResponseError.
+func IsUnexpectedResponse(err error) bool {
+	return microerror.Cause(err) == unexpectedResponseError
+}
@@ -0,0 +1,69 @@
+package promclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// NewPortForward returns a client reaching Prometheus through a port-forward
+// to port of the given pod. The returned function stops the port-forward and
+// must be called once the client is not needed anymore.
+func NewPortForward(restConfig *rest.Config, namespace string, podName string, port int, routePrefix string) (*Client, func(), error) {
+	roundTripper, upgrader, err := spdy.RoundTripperFor(restConfig)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	u, err := serverURL(restConfig)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+	u.Path = fmt.Sprintf("%s/api/v1/namespaces/%s/pods/%s/portforward", strings.TrimSuffix(u.Path, "/"), namespace, podName)
+
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: roundTripper}, http.MethodPost, u)
+
+	stopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	errCh := make(chan error, 1)
+
+	// Port 0 makes the port-forward listen on a random local port.
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", port)}, stopCh, readyCh, io.Discard, io.Discard)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	go func() {
+		errCh <- forwarder.ForwardPorts()
+	}()
+
+	select {
+	case <-readyCh:
+	case err = <-errCh:
+		return nil, nil, microerror.Mask(err)
+	}
+
+	stop := func() { close(stopCh) }
+
+	ports, err := forwarder.GetPorts()
+	if err != nil {
+		stop()
+		return nil, nil, microerror.Mask(err)
+	}
+
+	c, err := New(Config{
+		BaseURL: fmt.Sprintf("http://127.0.0.1:%d%s", ports[0].Local, routePrefix),
+	})
+	if err != nil {
+		stop()
+		return nil, nil, microerror.Mask(err)
+	}
+
+	return c, stop, nil
+}
@@ -0,0 +1,156 @@
+package promclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	ResultTypeVector = "vector"
+	ResultTypeMatrix = "matrix"
+	ResultTypeScalar = "scalar"
+	ResultTypeString = "string"
+)
+
+// response is the envelope of every Prometheus HTTP API response.
+type response struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+	Warnings  []string        `json:"warnings"`
+}
+
+// SamplePair is a single value of a time series.
+type SamplePair struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// UnmarshalJSON decodes the [<unix time>, "<value>"] representation used by
+// the Prometheus HTTP API.
+func (p *SamplePair) UnmarshalJSON(b []byte) error {
+	var raw []interface{}
+	err := json.Unmarshal(b, &raw)
+	if err != nil {
+		return err
+	}
+
+	if len(raw) != 2 {
+		return fmt.Errorf("expected sample pair of 2 elements, got %d", len(raw))
+	}
+
+	ts, ok := raw[0].(float64)
+	if !ok {
+		return fmt.Errorf("expected sample timestamp to be a number, got %v", raw[0])
+	}
+
+	s, ok := raw[1].(string)
+	if !ok {
+		return fmt.Errorf("expected sample value to be a string, got %v", raw[1])
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+
+	sec := int64(ts)
+	p.Timestamp = time.Unix(sec, int64((ts-float64(sec))*float64(time.Second))).UTC()
+	p.Value = value
+
+	return nil
+}
+
+// Sample is an instant vector element.
+type Sample struct {
+	Metric map[string]string `json:"metric"`
+	Value  SamplePair        `json:"value"`
+}
+
+// Series is a range vector element.
+type Series struct {
+	Metric map[string]string `json:"metric"`
+	Values []SamplePair      `json:"values"`
+}
+
+// QueryResult is the result of an instant or range query. Only the field
+// matching ResultType is set.
+type QueryResult struct {
+	ResultType string
+	Vector     []Sample
+	Matrix     []Series
+	Scalar     *SamplePair
+	Warnings   []string
+}
+
+func (r *QueryResult) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	}
+	err := json.Unmarshal(b, &raw)
+	if err != nil {
+		return err
+	}
+
+	r.ResultType = raw.ResultType
+
+	switch raw.ResultType {
+	case ResultTypeVector:
+		return json.Unmarshal(raw.Result, &r.Vector)
+	case ResultTypeMatrix:
+		return json.Unmarshal(raw.Result, &r.Matrix)
+	case ResultTypeScalar:
+		r.Scalar = &SamplePair{}
+		return json.Unmarshal(raw.Result, r.Scalar)
+	case ResultTypeString:
+		// The value of string results is not a number, so it is ignored.
+		return nil
+	}
+
+	return fmt.Errorf("unknown result type %q", raw.ResultType)
+}
+
+type TargetsResult struct {
+	ActiveTargets  []Target `json:"activeTargets"`
+	DroppedTargets []Target `json:"droppedTargets"`
+}
+
+type Target struct {
+	DiscoveredLabels map[string]string `json:"discoveredLabels"`
+	Labels           map[string]string `json:"labels"`
+	ScrapePool       string            `json:"scrapePool"`
+	ScrapeURL        string            `json:"scrapeUrl"`
+	LastError        string            `json:"lastError"`
+	LastScrape       time.Time         `json:"lastScrape"`
+	Health           string            `json:"health"`
+}
+
+type RuleGroup struct {
+	Name  string `json:"name"`
+	File  string `json:"file"`
+	Rules []Rule `json:"rules"`
+}
+
+// Rule is an alerting or a recording rule. State and Alerts are only set for
+// alerting rules.
+type Rule struct {
+	Name      string  `json:"name"`
+	Query     string  `json:"query"`
+	Type      string  `json:"type"`
+	Health    string  `json:"health"`
+	LastError string  `json:"lastError"`
+	State     string  `json:"state"`
+	Alerts    []Alert `json:"alerts"`
+}
+
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	State       string            `json:"state"`
+	ActiveAt    *time.Time        `json:"activeAt"`
+	Value       string            `json:"value"`
+}
@@ -12,10 +12,8 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/ghodss/yaml"
-	"github.com/giantswarm/apiextensions/v3/pkg/annotation"
 	"github.com/giantswarm/apiextensions/v3/pkg/apis/infrastructure/v1alpha3"
 	"github.com/giantswarm/apiextensions/v3/pkg/label"
-	"github.com/giantswarm/backoff"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	corev1 "k8s.io/api/core/v1"
@@ -29,6 +27,24 @@ import (
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/randomid"
 )
 
+const (
+	defaultInstanceType = "m5.xlarge"
+)
+
+func init() {
+	Register(Registration{
+		Name:    "aws",
+		Factory: NewAWSProviderSupport,
+		Capabilities: []Capability{
+			NodePools,
+			AvailabilityZones,
+			CgroupsV1,
+			Spot,
+			Autoscaler,
+		},
+	})
+}
+
 type AWSProviderSupport struct {
 	logger micrologger.Logger
 
@@ -59,41 +75,41 @@ func NewAWSProviderSupport(ctx context.Context, logger micrologger.Logger, clien
 	return p, nil
 }
 
-func (p *AWSProviderSupport) CreateNodePoolAndWaitReady(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, azs []string, cgroupsv1 bool) (*ctrl.ObjectKey, error) {
-	awsMP, err := p.createAwsMachineDeployment(ctx, client, cluster, azs)
+// CreateNodePoolAndWaitReady creates an aws-operator node pool. aws-operator
+// does not support node labels, taints and custom AMIs, and the only disks
+// are the docker and kubelet volumes. Node pools start with MinReplicas
+// nodes.
+func (p *AWSProviderSupport) CreateNodePoolAndWaitReady(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, spec NodePoolSpec) (*ctrl.ObjectKey, error) {
+	err := spec.Validate()
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	mp, err := p.createMachineDeployment(ctx, client, cluster, awsMP, azs, cgroupsv1)
+	err = spec.unsupported("aws-operator", "Taints", "NodeLabels", "OSImage")
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	p.logger.Debugf(ctx, "Created machine deployment %s", mp.Name)
+	if replicas, minReplicas, _ := spec.replicas(); spec.Replicas != nil && replicas != minReplicas {
+		return nil, microerror.Maskf(unsupportedNodePoolSpecError, "aws-operator node pools start with MinReplicas (%d) nodes, not %d", minReplicas, replicas)
+	}
 
-	// Wait for Node Pool to come up.
-	{
-		o := func() error {
-			err := client.Get(ctx, ctrl.ObjectKey{Name: mp.Name, Namespace: mp.Namespace}, mp)
-			if err != nil {
-				// Wrap masked error with backoff.Permanent() to stop retries on unrecoverable error.
-				return backoff.Permanent(microerror.Mask(err))
-			}
+	awsMP, err := p.createAwsMachineDeployment(ctx, client, cluster, spec)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
 
-			// Return error for retry until node pool nodes are Ready.
-			if mp.Status.Replicas == mp.Status.ReadyReplicas && mp.Status.ReadyReplicas > 0 {
-				return nil
-			}
+	mp, err := p.createMachineDeployment(ctx, client, cluster, awsMP, spec)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
 
-			return errors.New("node pool is not ready yet")
-		}
-		b := backoff.NewConstant(backoff.LongMaxWait, backoff.LongMaxInterval)
-		n := backoff.NewNotifier(p.logger, ctx)
-		err = backoff.RetryNotify(o, b, n)
-		if err != nil {
-			return nil, microerror.Mask(fmt.Errorf("failed to get MachinePool %q for Cluster %q: %s", mp.Name, cluster.Name, microerror.JSON(err)))
-		}
+	p.logger.Debugf(ctx, "Created machine deployment %s", mp.Name)
+
+	// Wait for Node Pool to come up.
+	err = waitForNodePoolReady(ctx, p.logger, client, mp, replicasReady)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
 	return &ctrl.ObjectKey{Name: mp.Name, Namespace: mp.Namespace}, nil
@@ -207,7 +223,7 @@ func (p *AWSProviderSupport) GetNodePoolAZsInProvider(ctx context.Context, clust
 	return zones, nil
 }
 
-func (p *AWSProviderSupport) createMachineDeployment(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, awsMachineDeployment *v1alpha3.AWSMachineDeployment, azs []string, cgroupsv1 bool) (*capi.MachineDeployment, error) {
+func (p *AWSProviderSupport) createMachineDeployment(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, awsMachineDeployment *v1alpha3.AWSMachineDeployment, spec NodePoolSpec) (*capi.MachineDeployment, error) {
 	var infrastructureCRRef *corev1.ObjectReference
 	{
 		s := runtime.NewScheme()
@@ -222,15 +238,6 @@ func (p *AWSProviderSupport) createMachineDeployment(ctx context.Context, client
 		}
 	}
 
-	annotations := map[string]string{
-		annotation.MachinePoolName: "cgroups v1",
-		annotation.NodePoolMinSize: "3",
-		annotation.NodePoolMaxSize: "3",
-	}
-	if cgroupsv1 {
-		annotations["node.giantswarm.io/cgroupv1"] = ""
-	}
-
 	machineDeployment := &capi.MachineDeployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      awsMachineDeployment.Name,
@@ -244,7 +251,7 @@ func (p *AWSProviderSupport) createMachineDeployment(ctx context.Context, client
 				label.ReleaseVersion:     cluster.Labels[label.ReleaseVersion],
 				capiutil.E2ENodepool:     "true",
 			},
-			Annotations: annotations,
+			Annotations: spec.operatorAnnotations(),
 		},
 		Spec: capi.MachineDeploymentSpec{
 			ClusterName: cluster.Name,
@@ -266,7 +273,7 @@ func (p *AWSProviderSupport) createMachineDeployment(ctx context.Context, client
 	return machineDeployment, nil
 }
 
-func (p *AWSProviderSupport) createAwsMachineDeployment(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, azs []string) (*v1alpha3.AWSMachineDeployment, error) {
+func (p *AWSProviderSupport) createAwsMachineDeployment(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, spec NodePoolSpec) (*v1alpha3.AWSMachineDeployment, error) {
 	nodepoolName := randomid.New()
 
 	awscluster := v1alpha3.AWSCluster{}
@@ -275,6 +282,33 @@ func (p *AWSProviderSupport) createAwsMachineDeployment(ctx context.Context, cli
 		return nil, microerror.Mask(err)
 	}
 
+	instanceType := defaultInstanceType
+	if spec.InstanceType != "" {
+		instanceType = spec.InstanceType
+	}
+
+	onDemandPercentage := 100
+	if spec.Spot {
+		onDemandPercentage = 0
+	}
+
+	machine := v1alpha3.AWSMachineDeploymentSpecNodePoolMachine{
+		DockerVolumeSizeGB:  100,
+		KubeletVolumeSizeGB: 100,
+	}
+	for _, d := range spec.Disks {
+		switch d.Name {
+		case "docker":
+			machine.DockerVolumeSizeGB = int(d.SizeGB)
+		case "kubelet":
+			machine.KubeletVolumeSizeGB = int(d.SizeGB)
+		default:
+			return nil, microerror.Maskf(unsupportedNodePoolSpecError, "aws-operator node pools only have docker and kubelet disks, not %q", d.Name)
+		}
+	}
+
+	_, minReplicas, maxReplicas := spec.replicas()
+
 	awsMachineDeployment := &v1alpha3.AWSMachineDeployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      nodepoolName,
@@ -292,23 +326,20 @@ func (p *AWSProviderSupport) createAwsMachineDeployment(ctx context.Context, cli
 		Spec: v1alpha3.AWSMachineDeploymentSpec{
 			NodePool: v1alpha3.AWSMachineDeploymentSpecNodePool{
 				Description: nodepoolName,
-				Machine: v1alpha3.AWSMachineDeploymentSpecNodePoolMachine{
-					DockerVolumeSizeGB:  100,
-					KubeletVolumeSizeGB: 100,
-				},
+				Machine:     machine,
 				Scaling: v1alpha3.AWSMachineDeploymentSpecNodePoolScaling{
-					Max: len(azs),
-					Min: len(azs),
+					Max: int(maxReplicas),
+					Min: int(minReplicas),
 				},
 			},
 			Provider: v1alpha3.AWSMachineDeploymentSpecProvider{
-				AvailabilityZones: azs,
+				AvailabilityZones: spec.AvailabilityZones,
 				InstanceDistribution: v1alpha3.AWSMachineDeploymentSpecInstanceDistribution{
 					OnDemandBaseCapacity:                0,
-					OnDemandPercentageAboveBaseCapacity: to.IntPtr(100),
+					OnDemandPercentageAboveBaseCapacity: to.IntPtr(onDemandPercentage),
 				},
 				Worker: v1alpha3.AWSMachineDeploymentSpecProviderWorker{
-					InstanceType:          "m5.xlarge",
+					InstanceType:          instanceType,
 					UseAlikeInstanceTypes: false,
 				},
 			},
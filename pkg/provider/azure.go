@@ -2,14 +2,11 @@ package provider
 
 import (
 	"context"
-	"errors"
 	"fmt"
 
 	"github.com/Azure/go-autorest/autorest/to"
-	"github.com/giantswarm/apiextensions/v3/pkg/annotation"
 	corev1alpha1 "github.com/giantswarm/apiextensions/v3/pkg/apis/core/v1alpha1"
 	"github.com/giantswarm/apiextensions/v3/pkg/label"
-	"github.com/giantswarm/backoff"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	corev1 "k8s.io/api/core/v1"
@@ -20,7 +17,6 @@ import (
 	expcapz "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 	expcapi "sigs.k8s.io/cluster-api/exp/api/v1beta1"
-	capiconditions "sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/azure"
@@ -31,11 +27,45 @@ import (
 
 const (
 	defaultVMSize = "Standard_D4s_v3"
+
+	// azureFirstDataDiskLun is the LUN of the first data disk, the following
+	// ones get the next LUNs.
+	azureFirstDataDiskLun = 21
 )
 
+// defaultDisks are the data disks of Spark bootstrapped node pools.
+var defaultDisks = []Disk{
+	{Name: "docker", SizeGB: 100},
+	{Name: "kubelet", SizeGB: 100},
+}
+
+func init() {
+	Register(Registration{
+		Name:    "azure",
+		Factory: NewAzureProviderSupport,
+		Capabilities: []Capability{
+			NodePools,
+			AvailabilityZones,
+			CgroupsV1,
+			Spot,
+			Autoscaler,
+			DeletionVerification,
+		},
+	})
+}
+
+// AzureProviderSupport supports clusters managed by Cluster API Provider
+// Azure. The way node pools are created is selected from the node pools the
+// cluster already has: legacy clusters get MachinePools bootstrapped by
+// azure-operator through a Spark, CAPZ native clusters get copies of their
+// KubeadmConfig bootstrapped MachinePools or MachineDeployments.
 type AzureProviderSupport struct {
 	logger      micrologger.Logger
 	azureClient *azure.Client
+	client      ctrl.Client
+
+	mode          azureNodePoolMode
+	resourceGroup string
 }
 
 func NewAzureProviderSupport(ctx context.Context, logger micrologger.Logger, client ctrl.Client, cluster *capi.Cluster) (Support, error) {
@@ -49,16 +79,59 @@ func NewAzureProviderSupport(ctx context.Context, logger micrologger.Logger, cli
 		return nil, microerror.Mask(err)
 	}
 
+	mode, err := detectAzureNodePoolMode(ctx, client, cluster.Name)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	logger.Debugf(ctx, "Using %s node pools for cluster %s", mode, cluster.Name)
+
+	resourceGroup := cluster.Name
+	if mode != sparkNodePools {
+		azureCluster, err := getAzureCluster(ctx, client, cluster)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		if azureCluster.Spec.ResourceGroup != "" {
+			resourceGroup = azureCluster.Spec.ResourceGroup
+		}
+	}
+
 	p := &AzureProviderSupport{
 		azureClient: azureClient,
+		client:      client,
 		logger:      logger,
+
+		mode:          mode,
+		resourceGroup: resourceGroup,
 	}
 
 	return p, nil
 }
 
-func (p *AzureProviderSupport) CreateNodePoolAndWaitReady(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, azs []string, cgroupsv1 bool) (*ctrl.ObjectKey, error) {
-	azureMP, err := p.createAzureMachinePool(ctx, client, cluster, azs)
+// CreateNodePoolAndWaitReady creates a node pool the way selected by
+// detectAzureNodePoolMode. azure-operator does not support node labels and
+// taints on Spark bootstrapped node pools.
+func (p *AzureProviderSupport) CreateNodePoolAndWaitReady(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, spec NodePoolSpec) (*ctrl.ObjectKey, error) {
+	err := spec.Validate()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	switch p.mode {
+	case kubeadmMachinePools:
+		return p.createKubeadmMachinePool(ctx, client, cluster, spec)
+	case kubeadmMachineDeployments:
+		return p.createKubeadmMachineDeployment(ctx, client, cluster, spec)
+	}
+
+	err = spec.unsupported("Spark", "Taints", "NodeLabels")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	azureMP, err := p.createAzureMachinePool(ctx, client, cluster, spec)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
@@ -68,39 +141,25 @@ func (p *AzureProviderSupport) CreateNodePoolAndWaitReady(ctx context.Context, c
 		return nil, microerror.Mask(err)
 	}
 
-	mp, err := p.createMachinePool(ctx, client, cluster, azureMP, bootstrap, azs, cgroupsv1)
+	mp, err := p.createMachinePool(ctx, client, cluster, azureMP, bootstrap, spec)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
 	// Wait for Node Pool to come up.
-	{
-		o := func() error {
-			err := client.Get(ctx, ctrl.ObjectKey{Name: mp.Name, Namespace: mp.Namespace}, mp)
-			if err != nil {
-				// Wrap masked error with backoff.Permanent() to stop retries on unrecoverable error.
-				return backoff.Permanent(microerror.Mask(err))
-			}
-
-			// Return error for retry until node pool nodes are Ready.
-			if !capiconditions.IsTrue(mp, capi.ReadyCondition) {
-				return errors.New("node pool is not ready yet")
-			}
-
-			return nil
-		}
-		b := backoff.NewConstant(backoff.LongMaxWait, backoff.LongMaxInterval)
-		n := backoff.NewNotifier(p.logger, ctx)
-		err = backoff.RetryNotify(o, b, n)
-		if err != nil {
-			return nil, microerror.Mask(fmt.Errorf("failed to get MachinePool %q for Cluster %q: %s", mp.Name, cluster.Name, microerror.JSON(err)))
-		}
+	err = waitForNodePoolReady(ctx, p.logger, client, mp, readyCondition)
+	if err != nil {
+		return nil, microerror.Mask(err)
 	}
 
 	return &ctrl.ObjectKey{Name: mp.Name, Namespace: mp.Namespace}, nil
 }
 
 func (p *AzureProviderSupport) DeleteNodePool(ctx context.Context, client ctrl.Client, objKey ctrl.ObjectKey) error {
+	if p.mode == kubeadmMachineDeployments {
+		return p.deleteKubeadmMachineDeployment(ctx, client, objKey)
+	}
+
 	mp := &expcapi.MachinePool{}
 
 	err := client.Get(ctx, objKey, mp)
@@ -112,10 +171,27 @@ func (p *AzureProviderSupport) DeleteNodePool(ctx context.Context, client ctrl.C
 }
 
 func (p *AzureProviderSupport) GetNodeSelectorLabel() string {
-	return "giantswarm.io/machine-pool"
+	if p.mode == kubeadmMachineDeployments {
+		return machineDeploymentNodeSelectorLabel
+	}
+
+	return label.MachinePool
 }
 
 func (p *AzureProviderSupport) GetTestingMachinePoolForCluster(ctx context.Context, client ctrl.Client, clusterID string) (string, error) {
+	if p.mode == kubeadmMachineDeployments {
+		machineDeployments, err := capiutil.FindNonTestingMachineDeploymentsForCluster(ctx, client, clusterID)
+		if err != nil {
+			return "", fmt.Errorf("error finding MachineDeployments for cluster %q: %s", clusterID, microerror.JSON(err))
+		}
+
+		if len(machineDeployments) == 0 {
+			return "", fmt.Errorf("expected one machine deployment to exist, none found")
+		}
+
+		return machineDeployments[0].Name, nil
+	}
+
 	var machinePoolName string
 	{
 		machinePools, err := capiutil.FindNonTestingMachinePoolsForCluster(ctx, client, clusterID)
@@ -138,6 +214,22 @@ func (p *AzureProviderSupport) GetProviderAZs() []string {
 }
 
 func (p *AzureProviderSupport) GetNodePoolAZsInCR(ctx context.Context, client ctrl.Client, objKey ctrl.ObjectKey) ([]string, error) {
+	if p.mode == kubeadmMachineDeployments {
+		md := &capi.MachineDeployment{}
+
+		err := client.Get(ctx, objKey, md)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		zones, err := machineFailureDomains(ctx, client, md)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return zones, nil
+	}
+
 	mp := &expcapi.MachinePool{}
 
 	err := client.Get(ctx, objKey, mp)
@@ -148,10 +240,25 @@ func (p *AzureProviderSupport) GetNodePoolAZsInCR(ctx context.Context, client ct
 	return mp.Spec.FailureDomains, nil
 }
 
+// GetNodePoolAZsInProvider returns the availability zones of the VMSS of a
+// MachinePool or of the VMs of a MachineDeployment. azure-operator names the
+// VMSS nodepool-<name> in a resource group named after the cluster, CAPZ
+// names it after the AzureMachinePool in the resource group of the
+// AzureCluster.
 func (p *AzureProviderSupport) GetNodePoolAZsInProvider(ctx context.Context, clusterID, nodepoolID string) ([]string, error) {
-	var zones []string
+	if p.mode == kubeadmMachineDeployments {
+		return p.getKubeadmMachineDeploymentAZsInProvider(ctx, clusterID, nodepoolID)
+	}
+
+	resourceGroup := clusterID
 	nodepoolVMSSName := fmt.Sprintf("nodepool-%s", nodepoolID)
-	vmss, err := p.azureClient.VMSS.Get(ctx, clusterID, nodepoolVMSSName)
+	if p.mode == kubeadmMachinePools {
+		resourceGroup = p.resourceGroup
+		nodepoolVMSSName = nodepoolID
+	}
+
+	var zones []string
+	vmss, err := p.azureClient.VMSS.Get(ctx, resourceGroup, nodepoolVMSSName)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
@@ -163,7 +270,7 @@ func (p *AzureProviderSupport) GetNodePoolAZsInProvider(ctx context.Context, clu
 	return zones, nil
 }
 
-func (p *AzureProviderSupport) createMachinePool(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, azureMachinePool *expcapz.AzureMachinePool, spark *corev1alpha1.Spark, azs []string, cgroupsv1 bool) (*expcapi.MachinePool, error) {
+func (p *AzureProviderSupport) createMachinePool(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, azureMachinePool *expcapz.AzureMachinePool, spark *corev1alpha1.Spark, spec NodePoolSpec) (*expcapi.MachinePool, error) {
 	var infrastructureCRRef *corev1.ObjectReference
 	{
 		s := runtime.NewScheme()
@@ -192,15 +299,7 @@ func (p *AzureProviderSupport) createMachinePool(ctx context.Context, client ctr
 		}
 	}
 
-	annotations := map[string]string{
-		annotation.MachinePoolName: "cgroups v1",
-		annotation.NodePoolMinSize: "3",
-		annotation.NodePoolMaxSize: "3",
-	}
-
-	if cgroupsv1 {
-		annotations["node.giantswarm.io/cgroupv1"] = ""
-	}
+	replicas, _, _ := spec.replicas()
 
 	machinePool := &expcapi.MachinePool{
 		ObjectMeta: metav1.ObjectMeta{
@@ -215,12 +314,12 @@ func (p *AzureProviderSupport) createMachinePool(ctx context.Context, client ctr
 				label.ReleaseVersion:       cluster.Labels[label.ReleaseVersion],
 				capiutil.E2ENodepool:       "true",
 			},
-			Annotations: annotations,
+			Annotations: spec.operatorAnnotations(),
 		},
 		Spec: expcapi.MachinePoolSpec{
 			ClusterName:    cluster.Name,
-			Replicas:       to.Int32Ptr(int32(len(azs))),
-			FailureDomains: azs,
+			Replicas:       to.Int32Ptr(replicas),
+			FailureDomains: spec.AvailabilityZones,
 			Template: capi.MachineTemplateSpec{
 				Spec: capi.MachineSpec{
 					Bootstrap: capi.Bootstrap{
@@ -241,15 +340,39 @@ func (p *AzureProviderSupport) createMachinePool(ctx context.Context, client ctr
 	return machinePool, nil
 }
 
-func (p *AzureProviderSupport) createAzureMachinePool(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, azs []string) (*expcapz.AzureMachinePool, error) {
-	azureCluster := &capz.AzureCluster{}
-	{
-		n := cluster.Spec.InfrastructureRef.Name
-		ns := cluster.Spec.InfrastructureRef.Namespace
-		err := client.Get(ctx, ctrl.ObjectKey{Name: n, Namespace: ns}, azureCluster)
-		if err != nil {
-			return nil, microerror.Mask(err)
-		}
+func (p *AzureProviderSupport) createAzureMachinePool(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, spec NodePoolSpec) (*expcapz.AzureMachinePool, error) {
+	azureCluster, err := getAzureCluster(ctx, client, cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	vmSize := defaultVMSize
+	if spec.InstanceType != "" {
+		vmSize = spec.InstanceType
+	}
+
+	disks := defaultDisks
+	if len(spec.Disks) > 0 {
+		disks = spec.Disks
+	}
+
+	var dataDisks []capz.DataDisk
+	for i, d := range disks {
+		dataDisks = append(dataDisks, capz.DataDisk{
+			NameSuffix: d.Name,
+			DiskSizeGB: d.SizeGB,
+			Lun:        to.Int32Ptr(int32(azureFirstDataDiskLun + i)),
+		})
+	}
+
+	var image *capz.Image
+	if spec.OSImage != "" {
+		image = &capz.Image{ID: to.StringPtr(spec.OSImage)}
+	}
+
+	var spotVMOptions *capz.SpotVMOptions
+	if spec.Spot {
+		spotVMOptions = &capz.SpotVMOptions{}
 	}
 
 	nodepoolName := randomid.New()
@@ -271,29 +394,20 @@ func (p *AzureProviderSupport) createAzureMachinePool(ctx context.Context, clien
 		Spec: expcapz.AzureMachinePoolSpec{
 			Location: azureCluster.Spec.Location,
 			Template: expcapz.AzureMachinePoolMachineTemplate{
-				DataDisks: []capz.DataDisk{
-					{
-						NameSuffix: "docker",
-						DiskSizeGB: int32(100),
-						Lun:        to.Int32Ptr(21),
-					},
-					{
-						NameSuffix: "kubelet",
-						DiskSizeGB: int32(100),
-						Lun:        to.Int32Ptr(22),
-					},
-				},
+				DataDisks: dataDisks,
+				Image:     image,
 				OSDisk: capz.OSDisk{
 					ManagedDisk: &capz.ManagedDiskParameters{
 						StorageAccountType: "Standard_LRS",
 					},
 				},
-				VMSize: defaultVMSize,
+				SpotVMOptions: spotVMOptions,
+				VMSize:        vmSize,
 			},
 		},
 	}
 
-	err := client.Create(ctx, azureMachinePool)
+	err = client.Create(ctx, azureMachinePool)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
@@ -327,3 +441,16 @@ func (p *AzureProviderSupport) createSpark(ctx context.Context, client ctrl.Clie
 
 	return spark, nil
 }
+
+func getAzureCluster(ctx context.Context, client ctrl.Client, cluster *capi.Cluster) (*capz.AzureCluster, error) {
+	azureCluster := &capz.AzureCluster{}
+
+	n := cluster.Spec.InfrastructureRef.Name
+	ns := cluster.Spec.InfrastructureRef.Namespace
+	err := client.Get(ctx, ctrl.ObjectKey{Name: n, Namespace: ns}, azureCluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return azureCluster, nil
+}
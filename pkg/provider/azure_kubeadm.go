@@ -0,0 +1,391 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	expcapi "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/randomid"
+)
+
+// azureNodePoolMode is the way node pools of an Azure cluster are created.
+type azureNodePoolMode string
+
+const (
+	// sparkNodePools are MachinePools bootstrapped by azure-operator through
+	// a Spark.
+	sparkNodePools azureNodePoolMode = "Spark"
+	// kubeadmMachinePools are MachinePools backed by AzureMachinePools and
+	// bootstrapped with KubeadmConfigs.
+	kubeadmMachinePools azureNodePoolMode = "KubeadmConfig MachinePool"
+	// kubeadmMachineDeployments are MachineDeployments backed by
+	// AzureMachineTemplates and bootstrapped with KubeadmConfigTemplates.
+	kubeadmMachineDeployments azureNodePoolMode = "KubeadmConfigTemplate MachineDeployment"
+)
+
+const (
+	azureMachinePoolKind     = "AzureMachinePool"
+	azureMachineTemplateKind = "AzureMachineTemplate"
+	kubeadmConfigKind        = "KubeadmConfig"
+	sparkKind                = "Spark"
+
+	machineDeploymentNodeSelectorLabel = "giantswarm.io/machine-deployment"
+)
+
+// detectAzureNodePoolMode returns the mode matching the node pools of the
+// cluster not created by the tests. MachinePools are preferred over
+// MachineDeployments, and clusters without any node pool to copy use Sparks
+// as before.
+func detectAzureNodePoolMode(ctx context.Context, client ctrl.Client, clusterID string) (azureNodePoolMode, error) {
+	machinePools, err := capiutil.FindNonTestingMachinePoolsForCluster(ctx, client, clusterID)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	for _, mp := range machinePools {
+		ref := mp.Spec.Template.Spec.Bootstrap.ConfigRef
+		if ref != nil && ref.Kind == kubeadmConfigKind && mp.Spec.Template.Spec.InfrastructureRef.Kind == azureMachinePoolKind {
+			return kubeadmMachinePools, nil
+		}
+	}
+
+	for _, mp := range machinePools {
+		ref := mp.Spec.Template.Spec.Bootstrap.ConfigRef
+		if ref != nil && ref.Kind == sparkKind {
+			return sparkNodePools, nil
+		}
+	}
+
+	machineDeployments, err := capiutil.FindNonTestingMachineDeploymentsForCluster(ctx, client, clusterID)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	for _, md := range machineDeployments {
+		if md.Spec.Template.Spec.InfrastructureRef.Kind == azureMachineTemplateKind && md.Spec.Template.Spec.Bootstrap.ConfigRef != nil {
+			return kubeadmMachineDeployments, nil
+		}
+	}
+
+	return sparkNodePools, nil
+}
+
+// createKubeadmMachinePool copies the first kubeadm MachinePool of the
+// cluster not created by the tests, with its AzureMachinePool and
+// KubeadmConfig, spread over the availability zones of spec. Cgroups v1 is
+// only set up by azure-operator.
+func (p *AzureProviderSupport) createKubeadmMachinePool(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, spec NodePoolSpec) (*ctrl.ObjectKey, error) {
+	err := spec.unsupported("CAPZ MachinePool", "CgroupsV1")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	template, err := p.findKubeadmMachinePool(ctx, client, cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	name := fmt.Sprintf("%s-%s", cluster.Name, randomid.New())
+
+	var infrastructureRef *ctrl.ObjectKey
+	{
+		ref := template.Spec.Template.Spec.InfrastructureRef
+
+		original, err := getReferenced(ctx, client, template.Namespace, ref.APIVersion, ref.Kind, ref.Name)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		infrastructure := copyObject(original, name)
+		unstructured.RemoveNestedField(infrastructure.Object, "spec", "providerID")
+		unstructured.RemoveNestedField(infrastructure.Object, "spec", "providerIDList")
+
+		err = setAzureMachineSpec(infrastructure, spec, "spec", "template")
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		err = client.Create(ctx, infrastructure)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		infrastructureRef = &ctrl.ObjectKey{Namespace: infrastructure.GetNamespace(), Name: infrastructure.GetName()}
+	}
+
+	bootstrapRef, err := copyKubeadmConfig(ctx, client, template.Namespace, template.Spec.Template.Spec.Bootstrap.ConfigRef, name, label.MachinePool, spec, kubeadmConfigNodeRegistrationFields...)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	mp, err := copyMachinePool(ctx, client, cluster, template, name, infrastructureRef, bootstrapRef, spec)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	p.logger.Debugf(ctx, "Created machine pool %s", mp.Name)
+
+	// Wait for Node Pool to come up.
+	err = waitForNodePoolReady(ctx, p.logger, client, mp, replicasReady)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &ctrl.ObjectKey{Name: mp.Name, Namespace: mp.Namespace}, nil
+}
+
+// createKubeadmMachineDeployment copies the first MachineDeployment of the
+// cluster not created by the tests, with its AzureMachineTemplate and
+// KubeadmConfigTemplate. The availability zone of spec, if any, is the
+// failure domain of the MachineDeployment, which can't have more than one.
+func (p *AzureProviderSupport) createKubeadmMachineDeployment(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, spec NodePoolSpec) (*ctrl.ObjectKey, error) {
+	err := spec.unsupported("CAPZ MachineDeployment", "AvailabilityZones", "CgroupsV1")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	template, err := p.findKubeadmMachineDeployment(ctx, client, cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	name := fmt.Sprintf("%s-%s", cluster.Name, randomid.New())
+
+	var infrastructureRef *ctrl.ObjectKey
+	{
+		ref := template.Spec.Template.Spec.InfrastructureRef
+
+		original, err := getReferenced(ctx, client, template.Namespace, ref.APIVersion, ref.Kind, ref.Name)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		infrastructure := copyObject(original, name)
+		unstructured.RemoveNestedField(infrastructure.Object, "spec", "template", "spec", "providerID")
+
+		err = setAzureMachineSpec(infrastructure, spec, "spec", "template", "spec")
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		err = client.Create(ctx, infrastructure)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		infrastructureRef = &ctrl.ObjectKey{Namespace: infrastructure.GetNamespace(), Name: infrastructure.GetName()}
+	}
+
+	bootstrapRef, err := copyKubeadmConfig(ctx, client, template.Namespace, template.Spec.Template.Spec.Bootstrap.ConfigRef, name, machineDeploymentNodeSelectorLabel, spec, kubeadmConfigTemplateNodeRegistrationFields...)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	md, err := p.createMachineDeployment(ctx, client, cluster, template, name, infrastructureRef, bootstrapRef, spec)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	p.logger.Debugf(ctx, "Created machine deployment %s", md.Name)
+
+	// Wait for Node Pool to come up.
+	err = waitForNodePoolReady(ctx, p.logger, client, md, replicasReady)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &ctrl.ObjectKey{Name: md.Name, Namespace: md.Namespace}, nil
+}
+
+// deleteKubeadmMachineDeployment deletes the MachineDeployment and the
+// templates copied for it, which are not owned by the MachineDeployment.
+func (p *AzureProviderSupport) deleteKubeadmMachineDeployment(ctx context.Context, client ctrl.Client, objKey ctrl.ObjectKey) error {
+	md := &capi.MachineDeployment{}
+
+	err := client.Get(ctx, objKey, md)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = client.Delete(ctx, md)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	refs := []*corev1.ObjectReference{&md.Spec.Template.Spec.InfrastructureRef}
+	if md.Spec.Template.Spec.Bootstrap.ConfigRef != nil {
+		refs = append(refs, md.Spec.Template.Spec.Bootstrap.ConfigRef)
+	}
+
+	for _, ref := range refs {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(ref.APIVersion)
+		obj.SetKind(ref.Kind)
+		obj.SetNamespace(md.Namespace)
+		obj.SetName(ref.Name)
+
+		err = client.Delete(ctx, obj)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// getKubeadmMachineDeploymentAZsInProvider returns the availability zones of
+// the VMs of the Machines of the MachineDeployment. CAPZ names the VMs after
+// the AzureMachines.
+func (p *AzureProviderSupport) getKubeadmMachineDeploymentAZsInProvider(ctx context.Context, clusterID, name string) ([]string, error) {
+	md, err := capiutil.FindMachineDeployment(ctx, p.client, clusterID, name)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	machines, err := capiutil.FindMachinesForMachineDeployment(ctx, p.client, md)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var zones []string
+	for _, machine := range machines {
+		vm, err := p.azureClient.VM.Get(ctx, p.resourceGroup, machine.Spec.InfrastructureRef.Name)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		if vm.Zones != nil {
+			zones = append(zones, *vm.Zones...)
+		}
+	}
+
+	return uniqueSorted(zones), nil
+}
+
+func (p *AzureProviderSupport) findKubeadmMachinePool(ctx context.Context, client ctrl.Client, cluster *capi.Cluster) (*expcapi.MachinePool, error) {
+	machinePools, err := capiutil.FindNonTestingMachinePoolsForCluster(ctx, client, cluster.Name)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for i := range machinePools {
+		ref := machinePools[i].Spec.Template.Spec.Bootstrap.ConfigRef
+		if ref != nil && ref.Kind == kubeadmConfigKind && machinePools[i].Spec.Template.Spec.InfrastructureRef.Kind == azureMachinePoolKind {
+			return &machinePools[i], nil
+		}
+	}
+
+	return nil, microerror.Maskf(executionFailedError, "cluster %q has no MachinePool backed by an %s and bootstrapped with a %s to copy", cluster.Name, azureMachinePoolKind, kubeadmConfigKind)
+}
+
+func (p *AzureProviderSupport) findKubeadmMachineDeployment(ctx context.Context, client ctrl.Client, cluster *capi.Cluster) (*capi.MachineDeployment, error) {
+	machineDeployments, err := capiutil.FindNonTestingMachineDeploymentsForCluster(ctx, client, cluster.Name)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for i := range machineDeployments {
+		if machineDeployments[i].Spec.Template.Spec.InfrastructureRef.Kind == azureMachineTemplateKind && machineDeployments[i].Spec.Template.Spec.Bootstrap.ConfigRef != nil {
+			return &machineDeployments[i], nil
+		}
+	}
+
+	return nil, microerror.Maskf(executionFailedError, "cluster %q has no MachineDeployment backed by an %s to copy", cluster.Name, azureMachineTemplateKind)
+}
+
+func (p *AzureProviderSupport) createMachineDeployment(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, template *capi.MachineDeployment, name string, infrastructureRef *ctrl.ObjectKey, bootstrapRef *ctrl.ObjectKey, nodePoolSpec NodePoolSpec) (*capi.MachineDeployment, error) {
+	replicas, _, _ := nodePoolSpec.replicas()
+	azs := nodePoolSpec.AvailabilityZones
+
+	labels := map[string]string{}
+	for k, v := range template.Labels {
+		labels[k] = v
+	}
+	labels[capi.ClusterNameLabel] = cluster.Name
+	labels[capiutil.E2ENodepool] = "true"
+
+	selector := map[string]string{
+		capi.ClusterNameLabel:           cluster.Name,
+		capi.MachineDeploymentNameLabel: name,
+	}
+
+	spec := template.Spec.DeepCopy()
+	spec.Replicas = to.Int32Ptr(replicas)
+	spec.Selector = metav1.LabelSelector{MatchLabels: selector}
+	spec.Template.Labels = map[string]string{}
+	for k, v := range template.Spec.Template.Labels {
+		spec.Template.Labels[k] = v
+	}
+	for k, v := range selector {
+		spec.Template.Labels[k] = v
+	}
+	spec.Template.Spec.InfrastructureRef.Name = infrastructureRef.Name
+	spec.Template.Spec.Bootstrap.ConfigRef.Name = bootstrapRef.Name
+	spec.Template.Spec.Bootstrap.DataSecretName = nil
+	spec.Template.Spec.ProviderID = nil
+	spec.Template.Spec.FailureDomain = nil
+	if len(azs) > 0 {
+		spec.Template.Spec.FailureDomain = to.StringPtr(azs[0])
+	}
+
+	machineDeployment := &capi.MachineDeployment{}
+	machineDeployment.Name = name
+	machineDeployment.Namespace = template.Namespace
+	machineDeployment.Labels = labels
+	machineDeployment.Annotations = nodePoolSpec.annotations()
+	machineDeployment.Spec = *spec
+
+	err := client.Create(ctx, machineDeployment)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return machineDeployment, nil
+}
+
+// setAzureMachineSpec sets the instance type, image, data disks and spot
+// instances of spec on the AzureMachinePool or AzureMachineTemplate obj.
+// fields is the path of the AzureMachine spec in obj.
+func setAzureMachineSpec(obj *unstructured.Unstructured, spec NodePoolSpec, fields ...string) error {
+	values := map[string]interface{}{}
+	if spec.InstanceType != "" {
+		values["vmSize"] = spec.InstanceType
+	}
+	if spec.OSImage != "" {
+		values["image"] = map[string]interface{}{"id": spec.OSImage}
+	}
+	if len(spec.Disks) > 0 {
+		var disks []interface{}
+		for i, d := range spec.Disks {
+			disks = append(disks, map[string]interface{}{
+				"nameSuffix": d.Name,
+				"diskSizeGB": int64(d.SizeGB),
+				"lun":        int64(azureFirstDataDiskLun + i),
+			})
+		}
+		values["dataDisks"] = disks
+	}
+	if spec.Spot {
+		values["spotVMOptions"] = map[string]interface{}{}
+	}
+
+	for k, v := range values {
+		err := unstructured.SetNestedField(obj.Object, v, append(append([]string{}, fields...), k)...)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
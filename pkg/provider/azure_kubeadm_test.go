@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_detectAzureNodePoolMode(t *testing.T) {
+	testCases := []struct {
+		name     string
+		objects  []ctrl.Object
+		expected azureNodePoolMode
+	}{
+		{
+			name:     "case 0: no node pools",
+			expected: sparkNodePools,
+		},
+		{
+			name: "case 1: Spark bootstrapped MachinePool",
+			objects: []ctrl.Object{
+				newTestMachinePool("pool0", "Spark", "AzureMachinePool", false),
+			},
+			expected: sparkNodePools,
+		},
+		{
+			name: "case 2: KubeadmConfig bootstrapped MachinePool",
+			objects: []ctrl.Object{
+				newTestMachinePool("pool0", "KubeadmConfig", "AzureMachinePool", false),
+			},
+			expected: kubeadmMachinePools,
+		},
+		{
+			name: "case 3: MachineDeployment backed by an AzureMachineTemplate",
+			objects: []ctrl.Object{
+				newTestMachineDeployment("md0", "KubeadmConfigTemplate", "AzureMachineTemplate"),
+			},
+			expected: kubeadmMachineDeployments,
+		},
+		{
+			name: "case 4: node pools created by the tests are ignored",
+			objects: []ctrl.Object{
+				newTestMachinePool("e2e", "KubeadmConfig", "AzureMachinePool", true),
+				newTestMachineDeployment("md0", "KubeadmConfigTemplate", "AzureMachineTemplate"),
+			},
+			expected: kubeadmMachineDeployments,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newFakeClient(t, tc.objects...)
+
+			mode, err := detectAzureNodePoolMode(context.Background(), client, "abc12")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mode != tc.expected {
+				t.Fatalf("detectAzureNodePoolMode() == %q, want %q", mode, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,383 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	expcapi "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	awscredentials "github.com/giantswarm/sonobuoy-plugin/v5/pkg/aws/credentials"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/randomid"
+)
+
+const (
+	capaMachinePoolKind        = "AWSMachinePool"
+	capaManagedMachinePoolKind = "AWSManagedMachinePool"
+
+	capaNodeSelectorLabel = "giantswarm.io/machine-pool"
+	eksNodegroupNameTag   = "eks:nodegroup-name"
+
+	capaMaxAZs = 3
+)
+
+// eksTaintEffects maps taint effects to the ones of AWSManagedMachinePools.
+var eksTaintEffects = map[corev1.TaintEffect]string{
+	corev1.TaintEffectNoSchedule:       "no-schedule",
+	corev1.TaintEffectNoExecute:        "no-execute",
+	corev1.TaintEffectPreferNoSchedule: "prefer-no-schedule",
+}
+
+func init() {
+	Register(Registration{
+		Name:    "capa",
+		Factory: NewCAPAProviderSupport,
+		Capabilities: []Capability{
+			NodePools,
+			AvailabilityZones,
+			Spot,
+			Autoscaler,
+		},
+	})
+}
+
+// CAPAProviderSupport supports clusters managed by Cluster API Provider AWS,
+// with MachinePools backed by AWSMachinePools or, on EKS, by
+// AWSManagedMachinePools. The CAPA objects are handled as unstructured
+// objects. Node pools created by the tests are copies of the first node pool
+// of the cluster not created by the tests, so they get the same instance
+// type, subnets and bootstrap configuration.
+type CAPAProviderSupport struct {
+	logger micrologger.Logger
+
+	asgClient *autoscaling.AutoScaling
+	ec2Client *ec2.EC2
+	azs       []string
+}
+
+func NewCAPAProviderSupport(ctx context.Context, logger micrologger.Logger, client ctrl.Client, cluster *capi.Cluster) (Support, error) {
+	s, err := awscredentials.ForCluster(ctx, client, cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	p := &CAPAProviderSupport{
+		logger:    logger,
+		asgClient: autoscaling.New(s),
+		ec2Client: ec2.New(s),
+		// CAPA reports the zones of the subnets of the cluster as its
+		// failure domains, node pools can't run in other zones.
+		azs: clusterFailureDomains(cluster, capaMaxAZs),
+	}
+
+	return p, nil
+}
+
+func (p *CAPAProviderSupport) CreateNodePoolAndWaitReady(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, spec NodePoolSpec) (*ctrl.ObjectKey, error) {
+	err := spec.Validate()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = spec.unsupported("CAPA", "Disks", "CgroupsV1")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	template, err := p.findTemplateMachinePool(ctx, client, cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if template.Spec.Template.Spec.InfrastructureRef.Kind == capaManagedMachinePoolKind {
+		err = spec.unsupported("EKS", "OSImage")
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	name := fmt.Sprintf("%s-%s", cluster.Name, randomid.New())
+
+	infrastructureRef, err := p.copyInfrastructure(ctx, client, template, name, spec)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	bootstrapRef, err := p.copyBootstrapConfig(ctx, client, template, name, spec)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	mp, err := copyMachinePool(ctx, client, cluster, template, name, infrastructureRef, bootstrapRef, spec)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	p.logger.Debugf(ctx, "Created machine pool %s", mp.Name)
+
+	// Wait for Node Pool to come up.
+	err = waitForNodePoolReady(ctx, p.logger, client, mp, replicasReady)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &ctrl.ObjectKey{Name: mp.Name, Namespace: mp.Namespace}, nil
+}
+
+// DeleteNodePool deletes the MachinePool. The MachinePool controller deletes
+// the infrastructure and bootstrap objects it references.
+func (p *CAPAProviderSupport) DeleteNodePool(ctx context.Context, client ctrl.Client, objKey ctrl.ObjectKey) error {
+	return deleteMachinePool(ctx, client, objKey)
+}
+
+func (p *CAPAProviderSupport) GetNodeSelectorLabel() string {
+	return capaNodeSelectorLabel
+}
+
+func (p *CAPAProviderSupport) GetTestingMachinePoolForCluster(ctx context.Context, client ctrl.Client, clusterID string) (string, error) {
+	return findTestingMachinePool(ctx, client, clusterID)
+}
+
+func (p *CAPAProviderSupport) GetProviderAZs() []string {
+	return p.azs
+}
+
+func (p *CAPAProviderSupport) GetNodePoolAZsInCR(ctx context.Context, client ctrl.Client, objKey ctrl.ObjectKey) ([]string, error) {
+	mp := &expcapi.MachinePool{}
+
+	err := client.Get(ctx, objKey, mp)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	infrastructure, err := getReferenced(ctx, client, mp.Namespace, mp.Spec.Template.Spec.InfrastructureRef.APIVersion, mp.Spec.Template.Spec.InfrastructureRef.Kind, mp.Spec.Template.Spec.InfrastructureRef.Name)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	zones, _, _ := unstructured.NestedStringSlice(infrastructure.Object, "spec", "availabilityZones")
+
+	return zones, nil
+}
+
+// GetNodePoolAZsInProvider returns the availability zones of the running EC2
+// instances of the auto scaling group of the node pool. The auto scaling
+// group of an AWSMachinePool is named after it, the one of an
+// AWSManagedMachinePool is tagged with its EKS node group name.
+func (p *CAPAProviderSupport) GetNodePoolAZsInProvider(ctx context.Context, clusterID, nodepoolName string) ([]string, error) {
+	var groups []*autoscaling.Group
+	{
+		out, err := p.asgClient.DescribeAutoScalingGroupsWithContext(ctx, &autoscaling.DescribeAutoScalingGroupsInput{
+			AutoScalingGroupNames: []*string{aws.String(nodepoolName)},
+		})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		groups = out.AutoScalingGroups
+
+		if len(groups) == 0 {
+			out, err = p.asgClient.DescribeAutoScalingGroupsWithContext(ctx, &autoscaling.DescribeAutoScalingGroupsInput{
+				Filters: []*autoscaling.Filter{
+					{
+						Name:   aws.String(fmt.Sprintf("tag:%s", eksNodegroupNameTag)),
+						Values: []*string{aws.String(nodepoolName)},
+					},
+				},
+			})
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			groups = out.AutoScalingGroups
+		}
+	}
+
+	if len(groups) == 0 {
+		return nil, microerror.Maskf(executionFailedError, "can't find auto scaling group of node pool %q of cluster %q", nodepoolName, clusterID)
+	}
+
+	var instanceIDs []*string
+	for _, group := range groups {
+		for _, instance := range group.Instances {
+			if instance != nil && instance.InstanceId != nil {
+				instanceIDs = append(instanceIDs, instance.InstanceId)
+			}
+		}
+	}
+
+	if len(instanceIDs) == 0 {
+		return nil, nil
+	}
+
+	var zones []string
+	err := p.ec2Client.DescribeInstancesPagesWithContext(ctx,
+		&ec2.DescribeInstancesInput{
+			InstanceIds: instanceIDs,
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("instance-state-name"),
+					Values: []*string{aws.String(ec2.InstanceStateNameRunning)},
+				},
+			},
+		},
+		func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+			for _, res := range page.Reservations {
+				for _, instance := range res.Instances {
+					if instance != nil && instance.Placement != nil && instance.Placement.AvailabilityZone != nil {
+						zones = append(zones, *instance.Placement.AvailabilityZone)
+					}
+				}
+			}
+			return true
+		},
+	)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return uniqueSorted(zones), nil
+}
+
+// findTemplateMachinePool returns the first MachinePool of the cluster not
+// created by the tests and backed by a CAPA machine pool.
+func (p *CAPAProviderSupport) findTemplateMachinePool(ctx context.Context, client ctrl.Client, cluster *capi.Cluster) (*expcapi.MachinePool, error) {
+	machinePools, err := capiutil.FindNonTestingMachinePoolsForCluster(ctx, client, cluster.Name)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for i := range machinePools {
+		kind := machinePools[i].Spec.Template.Spec.InfrastructureRef.Kind
+		if kind == capaMachinePoolKind || kind == capaManagedMachinePoolKind {
+			return &machinePools[i], nil
+		}
+	}
+
+	return nil, microerror.Maskf(executionFailedError, "cluster %q has no MachinePool backed by an %s or %s to copy", cluster.Name, capaMachinePoolKind, capaManagedMachinePoolKind)
+}
+
+// copyInfrastructure creates a copy of the AWSMachinePool or
+// AWSManagedMachinePool of template, spread over the availability zones of
+// spec. Managed machine pools have no bootstrap config, so the node labels
+// and taints are set on the AWSManagedMachinePool.
+func (p *CAPAProviderSupport) copyInfrastructure(ctx context.Context, client ctrl.Client, template *expcapi.MachinePool, name string, spec NodePoolSpec) (*ctrl.ObjectKey, error) {
+	ref := template.Spec.Template.Spec.InfrastructureRef
+
+	original, err := getReferenced(ctx, client, template.Namespace, ref.APIVersion, ref.Kind, ref.Name)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	infrastructure := copyObject(original, name)
+
+	// The subnets of the template pin its zones, they are only replaced when
+	// other zones are asked for.
+	if len(spec.AvailabilityZones) > 0 {
+		err = unstructured.SetNestedStringSlice(infrastructure.Object, spec.AvailabilityZones, "spec", "availabilityZones")
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		unstructured.RemoveNestedField(infrastructure.Object, "spec", "subnets")
+		unstructured.RemoveNestedField(infrastructure.Object, "spec", "subnetIDs")
+	}
+
+	_, minReplicas, maxReplicas := spec.replicas()
+	fields := map[string]interface{}{}
+	switch ref.Kind {
+	case capaMachinePoolKind:
+		fields["minSize"] = int64(minReplicas)
+		fields["maxSize"] = int64(maxReplicas)
+		if spec.InstanceType != "" {
+			fields["awsLaunchTemplate.instanceType"] = spec.InstanceType
+		}
+		if spec.OSImage != "" {
+			fields["awsLaunchTemplate.ami.id"] = spec.OSImage
+		}
+		if spec.Spot {
+			fields["mixedInstancesPolicy.instancesDistribution.onDemandBaseCapacity"] = int64(0)
+			fields["mixedInstancesPolicy.instancesDistribution.onDemandPercentageAboveBaseCapacity"] = int64(0)
+		}
+		unstructured.RemoveNestedField(infrastructure.Object, "spec", "providerID")
+	case capaManagedMachinePoolKind:
+		fields["scaling.minSize"] = int64(minReplicas)
+		fields["scaling.maxSize"] = int64(maxReplicas)
+		if spec.InstanceType != "" {
+			fields["instanceType"] = spec.InstanceType
+		}
+		if spec.Spot {
+			fields["capacityType"] = "spot"
+		}
+
+		labels, _, _ := unstructured.NestedStringMap(infrastructure.Object, "spec", "labels")
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		for k, v := range spec.NodeLabels {
+			labels[k] = v
+		}
+		labels[capaNodeSelectorLabel] = name
+		err = unstructured.SetNestedStringMap(infrastructure.Object, labels, "spec", "labels")
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		if len(spec.Taints) > 0 {
+			taints, _, _ := unstructured.NestedSlice(infrastructure.Object, "spec", "taints")
+			for _, t := range spec.Taints {
+				taints = append(taints, map[string]interface{}{
+					"key":    t.Key,
+					"value":  t.Value,
+					"effect": eksTaintEffects[t.Effect],
+				})
+			}
+			err = unstructured.SetNestedSlice(infrastructure.Object, taints, "spec", "taints")
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		// The node group name defaults to <namespace>_<name>, naming it after
+		// the MachinePool finds its auto scaling group by the node pool name.
+		err = unstructured.SetNestedField(infrastructure.Object, name, "spec", "eksNodegroupName")
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+	unstructured.RemoveNestedField(infrastructure.Object, "spec", "providerIDList")
+
+	for field, value := range fields {
+		err = unstructured.SetNestedField(infrastructure.Object, value, append([]string{"spec"}, strings.Split(field, ".")...)...)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	err = client.Create(ctx, infrastructure)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &ctrl.ObjectKey{Namespace: infrastructure.GetNamespace(), Name: infrastructure.GetName()}, nil
+}
+
+// copyBootstrapConfig creates a copy of the bootstrap config of template with
+// the node pool label set to name, so the nodes can be selected, and the node
+// labels and taints of spec. Managed machine pools have no bootstrap config,
+// nil is returned for them.
+func (p *CAPAProviderSupport) copyBootstrapConfig(ctx context.Context, client ctrl.Client, template *expcapi.MachinePool, name string, spec NodePoolSpec) (*ctrl.ObjectKey, error) {
+	ref := template.Spec.Template.Spec.Bootstrap.ConfigRef
+	if ref == nil {
+		return nil, nil
+	}
+
+	return copyKubeadmConfig(ctx, client, template.Namespace, ref, name, capaNodeSelectorLabel, spec, kubeadmConfigNodeRegistrationFields...)
+}
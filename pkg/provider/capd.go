@@ -0,0 +1,307 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	expcapi "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/docker"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/randomid"
+)
+
+const (
+	capdMachinePoolKind = "DockerMachinePool"
+
+	// kindClusterLabel is set by CAPD on the containers of the nodes of a
+	// cluster, with the cluster name as value.
+	kindClusterLabel = "io.x-k8s.kind.cluster"
+
+	capdMaxAZs = 3
+)
+
+func init() {
+	Register(Registration{
+		Name:    "capd",
+		Factory: NewCAPDProviderSupport,
+		Capabilities: []Capability{
+			NodePools,
+			AvailabilityZones,
+		},
+	})
+}
+
+// CAPDProviderSupport supports clusters managed by Cluster API Provider
+// Docker, e.g. in a kind management cluster, so the tests run without any
+// cloud account. Node pools created by the tests are copies of the first
+// MachinePool of the cluster backed by a DockerMachinePool. All the node
+// containers run on the same Docker host, so availability zones are the
+// failure domains of the cluster, and verifying them in the provider means
+// verifying every zone of the node pool has a node running as a container.
+type CAPDProviderSupport struct {
+	logger micrologger.Logger
+
+	client       ctrl.Client
+	dockerClient *docker.Client
+	azs          []string
+}
+
+func NewCAPDProviderSupport(ctx context.Context, logger micrologger.Logger, client ctrl.Client, cluster *capi.Cluster) (Support, error) {
+	dockerClient, err := docker.New(docker.Config{})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	p := &CAPDProviderSupport{
+		logger: logger,
+
+		client:       client,
+		dockerClient: dockerClient,
+		azs:          clusterFailureDomains(cluster, capdMaxAZs),
+	}
+
+	return p, nil
+}
+
+// CreateNodePoolAndWaitReady creates a node pool of containers, so instance
+// types, spot instances, disks and cgroups v1 can't be set. The OS image is
+// the node image of the containers.
+func (p *CAPDProviderSupport) CreateNodePoolAndWaitReady(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, spec NodePoolSpec) (*ctrl.ObjectKey, error) {
+	err := spec.Validate()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = spec.unsupported("CAPD", "InstanceType", "Spot", "Disks", "CgroupsV1")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	template, err := p.findTemplateMachinePool(ctx, client, cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	name := fmt.Sprintf("%s-%s", cluster.Name, randomid.New())
+
+	var infrastructureRef *ctrl.ObjectKey
+	{
+		ref := template.Spec.Template.Spec.InfrastructureRef
+
+		original, err := getReferenced(ctx, client, template.Namespace, ref.APIVersion, ref.Kind, ref.Name)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		infrastructure := copyObject(original, name)
+		unstructured.RemoveNestedField(infrastructure.Object, "spec", "providerID")
+		unstructured.RemoveNestedField(infrastructure.Object, "spec", "providerIDList")
+		if spec.OSImage != "" {
+			err = unstructured.SetNestedField(infrastructure.Object, spec.OSImage, "spec", "template", "customImage")
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		err = client.Create(ctx, infrastructure)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		infrastructureRef = &ctrl.ObjectKey{Namespace: infrastructure.GetNamespace(), Name: infrastructure.GetName()}
+	}
+
+	var bootstrapRef *ctrl.ObjectKey
+	if ref := template.Spec.Template.Spec.Bootstrap.ConfigRef; ref != nil {
+		bootstrapRef, err = copyKubeadmConfig(ctx, client, template.Namespace, ref, name, label.MachinePool, spec, kubeadmConfigNodeRegistrationFields...)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	mp, err := copyMachinePool(ctx, client, cluster, template, name, infrastructureRef, bootstrapRef, spec)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	p.logger.Debugf(ctx, "Created machine pool %s", mp.Name)
+
+	// Wait for Node Pool to come up.
+	err = waitForNodePoolReady(ctx, p.logger, client, mp, replicasReady)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &ctrl.ObjectKey{Name: mp.Name, Namespace: mp.Namespace}, nil
+}
+
+// DeleteNodePool deletes the MachinePool. The MachinePool controller deletes
+// the infrastructure and bootstrap objects it references.
+func (p *CAPDProviderSupport) DeleteNodePool(ctx context.Context, client ctrl.Client, objKey ctrl.ObjectKey) error {
+	return deleteMachinePool(ctx, client, objKey)
+}
+
+func (p *CAPDProviderSupport) GetNodeSelectorLabel() string {
+	return label.MachinePool
+}
+
+func (p *CAPDProviderSupport) GetTestingMachinePoolForCluster(ctx context.Context, client ctrl.Client, clusterID string) (string, error) {
+	return findTestingMachinePool(ctx, client, clusterID)
+}
+
+func (p *CAPDProviderSupport) GetProviderAZs() []string {
+	return p.azs
+}
+
+func (p *CAPDProviderSupport) GetNodePoolAZsInCR(ctx context.Context, client ctrl.Client, objKey ctrl.ObjectKey) ([]string, error) {
+	mp := &expcapi.MachinePool{}
+
+	err := client.Get(ctx, objKey, mp)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return uniqueSorted(mp.Spec.FailureDomains), nil
+}
+
+// GetNodePoolAZsInProvider returns the zones of the node pool, checking every
+// zone is a failure domain of the cluster and has a node running as a
+// container.
+func (p *CAPDProviderSupport) GetNodePoolAZsInProvider(ctx context.Context, clusterID, nodepoolName string) ([]string, error) {
+	cluster, err := capiutil.FindCluster(ctx, p.client, clusterID)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	nodesByZone, err := p.findNodePoolNodes(ctx, cluster, nodepoolName)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	containers, err := p.dockerClient.ListContainers(ctx, map[string]string{kindClusterLabel: clusterID})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	running := map[string]bool{}
+	for _, container := range containers {
+		running[container.Name()] = container.IsRunning()
+	}
+
+	var zones []string
+	for zone, nodes := range nodesByZone {
+		if _, ok := cluster.Status.FailureDomains[zone]; !ok {
+			return nil, microerror.Maskf(executionFailedError, "zone %q of node pool %q is not a failure domain of cluster %q", zone, nodepoolName, clusterID)
+		}
+
+		for _, node := range nodes {
+			if !running[node] {
+				return nil, microerror.Maskf(executionFailedError, "node %q in zone %q of node pool %q of cluster %q is not running as a container", node, zone, nodepoolName, clusterID)
+			}
+		}
+
+		if len(nodes) > 0 {
+			zones = append(zones, zone)
+		}
+	}
+
+	return uniqueSorted(zones), nil
+}
+
+// findNodePoolNodes returns the node names of the MachinePool or
+// MachineDeployment named name by zone. The nodes of a MachineDeployment, e.g.
+// one created through a ClusterClass, are in the failure domains of their
+// Machines. The nodes of a MachinePool are the instances of its
+// DockerMachinePool, which CAPD does not pin to failure domains, so they are
+// spread over the failure domains of the MachinePool in turn.
+func (p *CAPDProviderSupport) findNodePoolNodes(ctx context.Context, cluster *capi.Cluster, name string) (map[string][]string, error) {
+	machinePools, err := capiutil.FindAllMachinePoolsForCluster(ctx, p.client, cluster.Name)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for _, mp := range machinePools {
+		if mp.Name != name {
+			continue
+		}
+
+		ref := mp.Spec.Template.Spec.InfrastructureRef
+
+		infrastructure, err := getReferenced(ctx, p.client, mp.Namespace, ref.APIVersion, ref.Kind, ref.Name)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		var nodes []string
+		instances, _, _ := unstructured.NestedSlice(infrastructure.Object, "status", "instances")
+		for _, instance := range instances {
+			m, ok := instance.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if instanceName, _, _ := unstructured.NestedString(m, "instanceName"); instanceName != "" {
+				nodes = append(nodes, instanceName)
+			}
+		}
+		sort.Strings(nodes)
+
+		zones := uniqueSorted(mp.Spec.FailureDomains)
+		if len(zones) == 0 {
+			return nil, nil
+		}
+
+		nodesByZone := map[string][]string{}
+		for i, node := range nodes {
+			zone := zones[i%len(zones)]
+			nodesByZone[zone] = append(nodesByZone[zone], node)
+		}
+
+		return nodesByZone, nil
+	}
+
+	md, err := capiutil.FindMachineDeployment(ctx, p.client, cluster.Name, name)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	machines, err := capiutil.FindMachinesForMachineDeployment(ctx, p.client, md)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	nodesByZone := map[string][]string{}
+	for _, machine := range machines {
+		if machine.Status.NodeRef == nil || machine.Spec.FailureDomain == nil {
+			continue
+		}
+		zone := *machine.Spec.FailureDomain
+		nodesByZone[zone] = append(nodesByZone[zone], machine.Status.NodeRef.Name)
+	}
+
+	return nodesByZone, nil
+}
+
+// findTemplateMachinePool returns the first MachinePool of the cluster not
+// created by the tests and backed by a DockerMachinePool.
+func (p *CAPDProviderSupport) findTemplateMachinePool(ctx context.Context, client ctrl.Client, cluster *capi.Cluster) (*expcapi.MachinePool, error) {
+	machinePools, err := capiutil.FindNonTestingMachinePoolsForCluster(ctx, client, cluster.Name)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	for i := range machinePools {
+		if machinePools[i].Spec.Template.Spec.InfrastructureRef.Kind == capdMachinePoolKind {
+			return &machinePools[i], nil
+		}
+	}
+
+	return nil, microerror.Maskf(executionFailedError, "cluster %q has no MachinePool backed by a %s to copy", cluster.Name, capdMachinePoolKind)
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/giantswarm/micrologger"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
 )
 
 const (
@@ -21,23 +23,24 @@ func GetProvider() string {
 	return strings.TrimSpace(provider)
 }
 
+// GetProviderSupport returns the Support of the provider in $PROVIDER, created
+// by the factory it registered with Register. Node pools of clusters created
+// from a ClusterClass are managed through the cluster topology, see
+// TopologyProviderSupport.
 func GetProviderSupport(ctx context.Context, logger micrologger.Logger, client ctrl.Client, cluster *capi.Cluster) (Support, error) {
-	switch GetProvider() {
-	case "azure":
-		p, err := NewAzureProviderSupport(ctx, logger, client, cluster)
-		if err != nil {
-			return nil, microerror.Mask(err)
-		}
-
-		return p, nil
-	case "aws":
-		p, err := NewAWSProviderSupport(ctx, logger, client, cluster)
-		if err != nil {
-			return nil, microerror.Mask(err)
-		}
-
-		return p, nil
+	r, ok := Lookup(GetProvider())
+	if !ok {
+		return nil, microerror.Maskf(unsupportedProviderError, "unsupported provider value in $%s: %q, registered providers are %v", ProviderEnvVarName, GetProvider(), Registered())
+	}
+
+	p, err := r.Factory(ctx, logger, client, cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if capiutil.IsTopologyManaged(cluster) {
+		return NewTopologyProviderSupport(logger, p), nil
 	}
 
-	return nil, microerror.Maskf(executionFailedError, "unsupported provider value in $%s: %q", ProviderEnvVarName, GetProvider())
+	return p, nil
 }
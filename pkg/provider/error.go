@@ -10,3 +10,30 @@ import "github.com/giantswarm/microerror"
 var executionFailedError = &microerror.Error{
 	Kind: "executionFailedError",
 }
+
+var unsupportedProviderError = &microerror.Error{
+	Kind: "unsupportedProviderError",
+}
+
+// IsUnsupportedProvider asserts unsupportedProviderError.
+func IsUnsupportedProvider(err error) bool {
+	return microerror.Cause(err) == unsupportedProviderError
+}
+
+var invalidNodePoolSpecError = &microerror.Error{
+	Kind: "invalidNodePoolSpecError",
+}
+
+// IsInvalidNodePoolSpec asserts invalidNodePoolSpecError.
+func IsInvalidNodePoolSpec(err error) bool {
+	return microerror.Cause(err) == invalidNodePoolSpecError
+}
+
+var unsupportedNodePoolSpecError = &microerror.Error{
+	Kind: "unsupportedNodePoolSpecError",
+}
+
+// IsUnsupportedNodePoolSpec asserts unsupportedNodePoolSpecError.
+func IsUnsupportedNodePoolSpec(err error) bool {
+	return microerror.Cause(err) == unsupportedNodePoolSpecError
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	expcapi "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
+)
+
+// newFakeClient returns a fake client knowing the Cluster API types, holding
+// objects.
+func newFakeClient(t *testing.T, objects ...ctrl.Object) ctrl.Client {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{capi.AddToScheme, expcapi.AddToScheme} {
+		err := addToScheme(scheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+// newTestMachinePool returns a MachinePool of cluster abc12, created by the
+// tests when e2e is true.
+func newTestMachinePool(name string, bootstrapKind string, infrastructureKind string, e2e bool) *expcapi.MachinePool {
+	labels := map[string]string{capi.ClusterNameLabel: "abc12"}
+	if e2e {
+		labels[capiutil.E2ENodepool] = "true"
+	}
+
+	return &expcapi.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "org-test", Labels: labels},
+		Spec: expcapi.MachinePoolSpec{
+			ClusterName: "abc12",
+			Template:    newTestMachineTemplate(name, bootstrapKind, infrastructureKind),
+		},
+	}
+}
+
+// newTestMachineDeployment returns a MachineDeployment of cluster abc12.
+func newTestMachineDeployment(name string, bootstrapKind string, infrastructureKind string) *capi.MachineDeployment {
+	return &capi.MachineDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "org-test", Labels: map[string]string{capi.ClusterNameLabel: "abc12"}},
+		Spec: capi.MachineDeploymentSpec{
+			ClusterName: "abc12",
+			Template:    newTestMachineTemplate(name, bootstrapKind, infrastructureKind),
+		},
+	}
+}
+
+func newTestMachineTemplate(name string, bootstrapKind string, infrastructureKind string) capi.MachineTemplateSpec {
+	return capi.MachineTemplateSpec{
+		Spec: capi.MachineSpec{
+			Bootstrap:         capi.Bootstrap{ConfigRef: &corev1.ObjectReference{Kind: bootstrapKind, Name: name}},
+			InfrastructureRef: corev1.ObjectReference{Kind: infrastructureKind, Name: name},
+		},
+	}
+}
@@ -0,0 +1,298 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/giantswarm/backoff"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	expcapi "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	capiconditions "sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
+)
+
+// Paths of the join node registration in KubeadmConfigs and
+// KubeadmConfigTemplates.
+var (
+	kubeadmConfigNodeRegistrationFields         = []string{"spec", "joinConfiguration", "nodeRegistration"}
+	kubeadmConfigTemplateNodeRegistrationFields = []string{"spec", "template", "spec", "joinConfiguration", "nodeRegistration"}
+)
+
+func getReferenced(ctx context.Context, client ctrl.Client, namespace string, apiVersion string, kind string, name string) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+
+	err := client.Get(ctx, ctrl.ObjectKey{Namespace: namespace, Name: name}, obj)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return obj, nil
+}
+
+// copyObject returns a copy of original named name, without its status and
+// server set metadata, labeled as created by the tests.
+func copyObject(original *unstructured.Unstructured, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": original.GetAPIVersion(),
+		"kind":       original.GetKind(),
+		"spec":       original.DeepCopy().Object["spec"],
+	}}
+	if obj.Object["spec"] == nil {
+		obj.Object["spec"] = map[string]interface{}{}
+	}
+
+	labels := map[string]string{}
+	for k, v := range original.GetLabels() {
+		labels[k] = v
+	}
+	labels[capiutil.E2ENodepool] = "true"
+
+	obj.SetName(name)
+	obj.SetNamespace(original.GetNamespace())
+	obj.SetLabels(labels)
+
+	return obj
+}
+
+// withNodeLabel sets key=value in the comma separated kubelet node labels.
+func withNodeLabel(nodeLabels string, key string, value string) string {
+	var labels []string
+	for _, l := range strings.Split(nodeLabels, ",") {
+		if l != "" && !strings.HasPrefix(l, key+"=") {
+			labels = append(labels, l)
+		}
+	}
+
+	return strings.Join(append(labels, key+"="+value), ",")
+}
+
+func uniqueSorted(values []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+
+	return unique
+}
+
+// copyKubeadmConfig creates a copy named name of the KubeadmConfig or
+// KubeadmConfigTemplate referenced by ref. The kubelet node label key is set
+// to name so the nodes of the node pool can be selected, next to the node
+// labels and taints of spec. fields is the path of the join node
+// registration.
+func copyKubeadmConfig(ctx context.Context, client ctrl.Client, namespace string, ref *corev1.ObjectReference, name string, key string, spec NodePoolSpec, fields ...string) (*ctrl.ObjectKey, error) {
+	original, err := getReferenced(ctx, client, namespace, ref.APIVersion, ref.Kind, ref.Name)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	config := copyObject(original, name)
+
+	nodeLabelsFields := append(append([]string{}, fields...), "kubeletExtraArgs", "node-labels")
+	nodeLabels, _, _ := unstructured.NestedString(config.Object, nodeLabelsFields...)
+	err = unstructured.SetNestedField(config.Object, spec.nodeLabels(nodeLabels, key, name), nodeLabelsFields...)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if len(spec.Taints) > 0 {
+		taintsFields := append(append([]string{}, fields...), "taints")
+		taints, _, _ := unstructured.NestedSlice(config.Object, taintsFields...)
+		for _, t := range spec.Taints {
+			taint := map[string]interface{}{
+				"key":    t.Key,
+				"effect": string(t.Effect),
+			}
+			if t.Value != "" {
+				taint["value"] = t.Value
+			}
+			taints = append(taints, taint)
+		}
+
+		err = unstructured.SetNestedSlice(config.Object, taints, taintsFields...)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	err = client.Create(ctx, config)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &ctrl.ObjectKey{Namespace: config.GetNamespace(), Name: config.GetName()}, nil
+}
+
+// copyMachinePool creates a MachinePool named name configured like template,
+// referencing the copied infrastructure and bootstrap objects, spread over
+// the availability zones of spec.
+func copyMachinePool(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, template *expcapi.MachinePool, name string, infrastructureRef *ctrl.ObjectKey, bootstrapRef *ctrl.ObjectKey, nodePoolSpec NodePoolSpec) (*expcapi.MachinePool, error) {
+	replicas, _, _ := nodePoolSpec.replicas()
+
+	labels := map[string]string{}
+	for k, v := range template.Labels {
+		labels[k] = v
+	}
+	labels[capi.ClusterNameLabel] = cluster.Name
+	labels[capiutil.E2ENodepool] = "true"
+
+	spec := template.Spec.DeepCopy()
+	spec.Replicas = to.Int32Ptr(replicas)
+	spec.FailureDomains = nodePoolSpec.AvailabilityZones
+	spec.ProviderIDList = nil
+	spec.Template.Spec.InfrastructureRef.Name = infrastructureRef.Name
+	if bootstrapRef != nil {
+		spec.Template.Spec.Bootstrap.ConfigRef.Name = bootstrapRef.Name
+		spec.Template.Spec.Bootstrap.DataSecretName = nil
+	}
+	spec.Template.Spec.ProviderID = nil
+
+	machinePool := &expcapi.MachinePool{}
+	machinePool.Name = name
+	machinePool.Namespace = template.Namespace
+	machinePool.Labels = labels
+	machinePool.Annotations = nodePoolSpec.annotations()
+	machinePool.Spec = *spec
+
+	err := client.Create(ctx, machinePool)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return machinePool, nil
+}
+
+// deleteMachinePool deletes the MachinePool at objKey.
+func deleteMachinePool(ctx context.Context, client ctrl.Client, objKey ctrl.ObjectKey) error {
+	mp := &expcapi.MachinePool{}
+
+	err := client.Get(ctx, objKey, mp)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = client.Delete(ctx, mp)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// findTestingMachinePool returns the name of a MachinePool of the cluster
+// that was not created by the tests, to be used as template.
+func findTestingMachinePool(ctx context.Context, client ctrl.Client, clusterID string) (string, error) {
+	machinePools, err := capiutil.FindNonTestingMachinePoolsForCluster(ctx, client, clusterID)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	if len(machinePools) == 0 {
+		return "", microerror.Maskf(executionFailedError, "expected one MachinePool to exist for cluster %q, none found", clusterID)
+	}
+
+	return machinePools[0].Name, nil
+}
+
+// clusterFailureDomains returns the first max failure domains of the cluster,
+// sorted by name.
+func clusterFailureDomains(cluster *capi.Cluster, max int) []string {
+	var failureDomains []string
+	for name := range cluster.Status.FailureDomains {
+		failureDomains = append(failureDomains, name)
+	}
+	failureDomains = uniqueSorted(failureDomains)
+
+	if len(failureDomains) > max {
+		failureDomains = failureDomains[:max]
+	}
+
+	return failureDomains
+}
+
+// machineFailureDomains returns the failure domains of the Machines of the
+// MachineDeployment.
+func machineFailureDomains(ctx context.Context, client ctrl.Client, md *capi.MachineDeployment) ([]string, error) {
+	machines, err := capiutil.FindMachinesForMachineDeployment(ctx, client, md)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var zones []string
+	for _, machine := range machines {
+		if machine.Spec.FailureDomain != nil {
+			zones = append(zones, *machine.Spec.FailureDomain)
+		}
+	}
+
+	return uniqueSorted(zones), nil
+}
+
+// waitForNodePoolReady waits until ready holds for nodePool, a MachinePool or
+// MachineDeployment, giving up after backoff.LongMaxWait.
+func waitForNodePoolReady(ctx context.Context, logger micrologger.Logger, client ctrl.Client, nodePool ctrl.Object, ready wait.Predicate) error {
+	waiter, err := wait.New(wait.Config{
+		Logger:  logger,
+		Client:  client,
+		Timeout: backoff.LongMaxWait,
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = waiter.UntilPredicate(ctx, nodePool, "have all nodes ready", ready)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// replicasReady reports whether all replicas of a MachinePool or
+// MachineDeployment are ready.
+func replicasReady(obj ctrl.Object) (bool, string) {
+	var replicas, readyReplicas int32
+	switch nodePool := obj.(type) {
+	case *expcapi.MachinePool:
+		replicas, readyReplicas = nodePool.Status.Replicas, nodePool.Status.ReadyReplicas
+	case *capi.MachineDeployment:
+		replicas, readyReplicas = nodePool.Status.Replicas, nodePool.Status.ReadyReplicas
+	default:
+		return false, fmt.Sprintf("%T is not a node pool", obj)
+	}
+
+	return replicas == readyReplicas && readyReplicas > 0, fmt.Sprintf("%d/%d replicas ready", readyReplicas, replicas)
+}
+
+// readyCondition reports whether the Ready condition of a MachinePool or
+// MachineDeployment is true.
+func readyCondition(obj ctrl.Object) (bool, string) {
+	getter, ok := obj.(capiconditions.Getter)
+	if !ok {
+		return false, fmt.Sprintf("%T has no conditions", obj)
+	}
+
+	condition := capiconditions.Get(getter, capi.ReadyCondition)
+	if condition == nil {
+		return false, "condition Ready not set"
+	}
+
+	return condition.Status == corev1.ConditionTrue, fmt.Sprintf("condition Ready is %s", condition.Status)
+}
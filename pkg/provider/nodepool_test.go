@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"testing"
+)
+
+func Test_withNodeLabel(t *testing.T) {
+	testCases := []struct {
+		name       string
+		nodeLabels string
+		expected   string
+	}{
+		{
+			name:       "case 0: no labels",
+			nodeLabels: "",
+			expected:   "giantswarm.io/machine-pool=abc12-e2e",
+		},
+		{
+			name:       "case 1: label of the copied node pool replaced",
+			nodeLabels: "role=worker,giantswarm.io/machine-pool=abc12-pool0,giantswarm.io/machine-pool-name=pool0",
+			expected:   "role=worker,giantswarm.io/machine-pool-name=pool0,giantswarm.io/machine-pool=abc12-e2e",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := withNodeLabel(tc.nodeLabels, capaNodeSelectorLabel, "abc12-e2e")
+			if actual != tc.expected {
+				t.Fatalf("withNodeLabel() == %q, want %q", actual, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/giantswarm/apiextensions/v3/pkg/annotation"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	cgroupsV1Annotation = "node.giantswarm.io/cgroupv1"
+)
+
+// NodePoolSpec describes a node pool created by
+// Support.CreateNodePoolAndWaitReady. Zero values keep the defaults of the
+// provider, or the settings of the node pool the provider copies. Providers
+// return an error matched by IsUnsupportedNodePoolSpec for settings they
+// can't honour, rather than silently ignoring them.
+type NodePoolSpec struct {
+	// AvailabilityZones the nodes are spread over.
+	AvailabilityZones []string
+	// InstanceType is the VM size on Azure or the EC2 instance type on AWS.
+	InstanceType string
+	// Replicas is the number of nodes the node pool is created with, one per
+	// availability zone by default. MinReplicas and MaxReplicas bound the
+	// cluster autoscaler and default to Replicas.
+	Replicas    *int32
+	MinReplicas *int32
+	MaxReplicas *int32
+	// Spot requests spot instances instead of on-demand ones.
+	Spot bool
+	// Taints are registered on the nodes by the kubelet.
+	Taints []corev1.Taint
+	// NodeLabels are set on the nodes by the kubelet.
+	NodeLabels map[string]string
+	// Disks replace the data disks of the nodes.
+	Disks []Disk
+	// OSImage is the ID of the image of the nodes, e.g. the resource ID of an
+	// Azure image or an AMI ID.
+	OSImage string
+	// Annotations are added to the MachinePool or MachineDeployment.
+	Annotations map[string]string
+	// CgroupsV1 makes the nodes use cgroups v1.
+	CgroupsV1 bool
+}
+
+// Disk is a data disk of the nodes of a node pool.
+type Disk struct {
+	// Name identifies the disk, e.g. docker or kubelet. It is the name suffix
+	// of the disk on Azure.
+	Name   string
+	SizeGB int32
+}
+
+// Validate returns an error matched by IsInvalidNodePoolSpec when the spec is
+// inconsistent.
+func (s NodePoolSpec) Validate() error {
+	replicas, minReplicas, maxReplicas := s.replicas()
+
+	if replicas < 0 || minReplicas < 0 || maxReplicas < 0 {
+		return microerror.Maskf(invalidNodePoolSpecError, "replicas must not be negative")
+	}
+	if minReplicas > replicas || replicas > maxReplicas {
+		return microerror.Maskf(invalidNodePoolSpecError, "expected MinReplicas (%d) <= Replicas (%d) <= MaxReplicas (%d)", minReplicas, replicas, maxReplicas)
+	}
+
+	names := map[string]bool{}
+	for _, d := range s.Disks {
+		if d.Name == "" || d.SizeGB <= 0 {
+			return microerror.Maskf(invalidNodePoolSpecError, "disks must have a name and a positive size, got %+v", d)
+		}
+		if names[d.Name] {
+			return microerror.Maskf(invalidNodePoolSpecError, "disk %q is listed twice", d.Name)
+		}
+		names[d.Name] = true
+	}
+
+	for _, t := range s.Taints {
+		if t.Key == "" || t.Effect == "" {
+			return microerror.Maskf(invalidNodePoolSpecError, "taints must have a key and an effect, got %+v", t)
+		}
+	}
+
+	return nil
+}
+
+// replicas returns the desired, minimum and maximum number of nodes.
+func (s NodePoolSpec) replicas() (int32, int32, int32) {
+	replicas := int32(len(s.AvailabilityZones))
+	if s.Replicas != nil {
+		replicas = *s.Replicas
+	}
+
+	minReplicas := replicas
+	if s.MinReplicas != nil {
+		minReplicas = *s.MinReplicas
+	}
+
+	maxReplicas := replicas
+	if s.MaxReplicas != nil {
+		maxReplicas = *s.MaxReplicas
+	}
+
+	return replicas, minReplicas, maxReplicas
+}
+
+// annotations returns the annotations of the MachinePool or
+// MachineDeployment: the cluster autoscaler bounds, cgroups v1 and the custom
+// annotations, which take precedence.
+func (s NodePoolSpec) annotations() map[string]string {
+	_, minReplicas, maxReplicas := s.replicas()
+
+	annotations := map[string]string{
+		annotation.NodePoolMinSize: fmt.Sprintf("%d", minReplicas),
+		annotation.NodePoolMaxSize: fmt.Sprintf("%d", maxReplicas),
+	}
+	if s.CgroupsV1 {
+		annotations[cgroupsV1Annotation] = ""
+	}
+	for k, v := range s.Annotations {
+		annotations[k] = v
+	}
+
+	return annotations
+}
+
+// operatorAnnotations returns the annotations of node pools created for
+// aws-operator and azure-operator, which also carry the node pool name shown
+// to users.
+func (s NodePoolSpec) operatorAnnotations() map[string]string {
+	annotations := s.annotations()
+	if _, ok := annotations[annotation.MachinePoolName]; !ok {
+		annotations[annotation.MachinePoolName] = "e2e"
+		if s.CgroupsV1 {
+			annotations[annotation.MachinePoolName] = "cgroups v1"
+		}
+	}
+
+	return annotations
+}
+
+// nodeLabels returns the kubelet node labels with the ones of the spec and
+// key=value added.
+func (s NodePoolSpec) nodeLabels(nodeLabels string, key string, value string) string {
+	var keys []string
+	for k := range s.NodeLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		nodeLabels = withNodeLabel(nodeLabels, k, s.NodeLabels[k])
+	}
+
+	return withNodeLabel(nodeLabels, key, value)
+}
+
+// unsupported returns an error matched by IsUnsupportedNodePoolSpec when any
+// of the named settings is set. Names are the NodePoolSpec field names.
+// AvailabilityZones counts as set with more than one zone, which node pools
+// with a single failure domain, like MachineDeployments, can't honour.
+func (s NodePoolSpec) unsupported(provider string, fields ...string) error {
+	set := map[string]bool{
+		"AvailabilityZones": len(s.AvailabilityZones) > 1,
+		"InstanceType":      s.InstanceType != "",
+		"Replicas":          s.Replicas != nil,
+		"MinReplicas":       s.MinReplicas != nil,
+		"MaxReplicas":       s.MaxReplicas != nil,
+		"Spot":              s.Spot,
+		"Taints":            len(s.Taints) > 0,
+		"NodeLabels":        len(s.NodeLabels) > 0,
+		"Disks":             len(s.Disks) > 0,
+		"OSImage":           s.OSImage != "",
+		"CgroupsV1":         s.CgroupsV1,
+	}
+
+	for _, f := range fields {
+		if set[f] {
+			return microerror.Maskf(unsupportedNodePoolSpecError, "%s node pools do not support NodePoolSpec.%s", provider, f)
+		}
+	}
+
+	return nil
+}
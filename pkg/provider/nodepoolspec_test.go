@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_NodePoolSpec_Validate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		spec  NodePoolSpec
+		valid bool
+	}{
+		{
+			name:  "case 0: defaults",
+			spec:  NodePoolSpec{AvailabilityZones: []string{"1", "2", "3"}},
+			valid: true,
+		},
+		{
+			name: "case 1: autoscaling bounds",
+			spec: NodePoolSpec{
+				AvailabilityZones: []string{"1"},
+				MinReplicas:       to.Int32Ptr(0),
+				MaxReplicas:       to.Int32Ptr(5),
+			},
+			valid: true,
+		},
+		{
+			name: "case 2: replicas above maximum",
+			spec: NodePoolSpec{
+				Replicas:    to.Int32Ptr(4),
+				MaxReplicas: to.Int32Ptr(3),
+			},
+		},
+		{
+			name: "case 3: disk listed twice",
+			spec: NodePoolSpec{
+				Disks: []Disk{{Name: "docker", SizeGB: 50}, {Name: "docker", SizeGB: 100}},
+			},
+		},
+		{
+			name: "case 4: taint without effect",
+			spec: NodePoolSpec{
+				Taints: []corev1.Taint{{Key: "dedicated", Value: "e2e"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.spec.Validate()
+			if tc.valid && err != nil {
+				t.Fatalf("expected spec to be valid, got %v", err)
+			}
+			if !tc.valid && !IsInvalidNodePoolSpec(err) {
+				t.Fatalf("expected invalidNodePoolSpecError, got %v", err)
+			}
+		})
+	}
+}
+
+func Test_NodePoolSpec_annotations(t *testing.T) {
+	spec := NodePoolSpec{
+		AvailabilityZones: []string{"1", "2"},
+		MaxReplicas:       to.Int32Ptr(4),
+		CgroupsV1:         true,
+		Annotations: map[string]string{
+			"cluster.x-k8s.io/cluster-api-autoscaler-node-group-max-size": "4",
+		},
+	}
+
+	expected := map[string]string{
+		"cluster.k8s.io/cluster-api-autoscaler-node-group-min-size":   "2",
+		"cluster.k8s.io/cluster-api-autoscaler-node-group-max-size":   "4",
+		"cluster.x-k8s.io/cluster-api-autoscaler-node-group-max-size": "4",
+		"node.giantswarm.io/cgroupv1":                                 "",
+	}
+	if actual := spec.annotations(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("annotations() == %v, want %v", actual, expected)
+	}
+
+	if name := spec.operatorAnnotations()["machine-pool.giantswarm.io/name"]; name != "cgroups v1" {
+		t.Fatalf("expected operator node pool name %q, got %q", "cgroups v1", name)
+	}
+}
+
+func Test_NodePoolSpec_nodeLabels(t *testing.T) {
+	spec := NodePoolSpec{
+		NodeLabels: map[string]string{
+			"role":      "e2e",
+			"dedicated": "true",
+		},
+	}
+
+	actual := spec.nodeLabels("role=worker", "giantswarm.io/machine-pool", "abc12-e2e")
+	expected := "dedicated=true,role=e2e,giantswarm.io/machine-pool=abc12-e2e"
+	if actual != expected {
+		t.Fatalf("nodeLabels() == %q, want %q", actual, expected)
+	}
+}
+
+func Test_NodePoolSpec_unsupported(t *testing.T) {
+	spec := NodePoolSpec{
+		AvailabilityZones: []string{"1"},
+		Spot:              true,
+	}
+
+	if err := spec.unsupported("test", "AvailabilityZones", "Taints", "OSImage"); err != nil {
+		t.Fatalf("expected unset settings to be ignored, got %v", err)
+	}
+	if err := spec.unsupported("test", "Spot"); !IsUnsupportedNodePoolSpec(err) {
+		t.Fatalf("expected unsupportedNodePoolSpecError, got %v", err)
+	}
+
+	spec.AvailabilityZones = []string{"1", "2"}
+	if err := spec.unsupported("test", "AvailabilityZones"); !IsUnsupportedNodePoolSpec(err) {
+		t.Fatalf("expected unsupportedNodePoolSpecError for more than one availability zone, got %v", err)
+	}
+}
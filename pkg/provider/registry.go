@@ -0,0 +1,139 @@
+package provider
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/giantswarm/micrologger"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Capability is a feature of a provider tests depend on.
+type Capability string
+
+const (
+	// NodePools means the provider creates and deletes node pools with
+	// Support.CreateNodePoolAndWaitReady and Support.DeleteNodePool.
+	NodePools Capability = "NodePools"
+	// AvailabilityZones means the provider reports the availability zones of
+	// node pools with Support.GetNodePoolAZsInCR and
+	// Support.GetNodePoolAZsInProvider.
+	AvailabilityZones Capability = "AvailabilityZones"
+	// CgroupsV1 means node pools can be created with cgroups v1.
+	CgroupsV1 Capability = "CgroupsV1"
+	// Spot means node pools can be created with spot instances, see
+	// NodePoolSpec.Spot.
+	Spot Capability = "Spot"
+	// Autoscaler means the cluster autoscaler scales the node pools of the
+	// cluster.
+	Autoscaler Capability = "Autoscaler"
+	// DeletionVerification means the removal of the cloud resources of a
+	// deleted cluster is verified by the deletion tests.
+	DeletionVerification Capability = "DeletionVerification"
+)
+
+// FactoryFunc creates the Support of a provider for the given cluster.
+type FactoryFunc func(ctx context.Context, logger micrologger.Logger, client ctrl.Client, cluster *capi.Cluster) (Support, error)
+
+// Registration describes a provider selected with $PROVIDER.
+type Registration struct {
+	// Name is the value of $PROVIDER selecting the provider.
+	Name         string
+	Factory      FactoryFunc
+	Capabilities []Capability
+}
+
+// Supports returns true when the provider declares all the capabilities.
+func (r Registration) Supports(capabilities ...Capability) bool {
+	for _, c := range capabilities {
+		found := false
+		for _, declared := range r.Capabilities {
+			if declared == c {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[string]Registration{}
+)
+
+// Register makes a provider available under its name. It is meant to be
+// called from the init function of the package implementing the provider, so
+// providers living outside of this package are selected with $PROVIDER once
+// their package is imported. Register panics when the registration is
+// incomplete or the name is already registered.
+func Register(r Registration) {
+	if r.Name == "" {
+		panic("provider.Register: Name must not be empty")
+	}
+	if r.Factory == nil {
+		panic("provider.Register: Factory of provider " + r.Name + " must not be empty")
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, ok := registry[r.Name]; ok {
+		panic("provider.Register: provider " + r.Name + " is already registered")
+	}
+	registry[r.Name] = r
+}
+
+// Lookup returns the registration of the provider with the given name.
+func Lookup(name string) (Registration, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	r, ok := registry[name]
+	return r, ok
+}
+
+// Registered returns the names of the registered providers, sorted.
+func Registered() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Supports returns true when the provider in $PROVIDER is registered and
+// declares all the capabilities.
+func Supports(capabilities ...Capability) bool {
+	r, ok := Lookup(GetProvider())
+	return ok && r.Supports(capabilities...)
+}
+
+// SkipUnlessSupported skips the test when the provider in $PROVIDER is not
+// registered or does not declare all the capabilities.
+func SkipUnlessSupported(t testing.TB, capabilities ...Capability) {
+	t.Helper()
+
+	r, ok := Lookup(GetProvider())
+	if !ok {
+		t.Skipf("provider %q in $%s is not registered, registered providers are %v", GetProvider(), ProviderEnvVarName, Registered())
+	}
+
+	for _, c := range capabilities {
+		if !r.Supports(c) {
+			t.Skipf("provider %q does not support %s", r.Name, c)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/giantswarm/micrologger"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_Registry(t *testing.T) {
+	factory := func(ctx context.Context, logger micrologger.Logger, client ctrl.Client, cluster *capi.Cluster) (Support, error) {
+		return nil, nil
+	}
+
+	Register(Registration{
+		Name:         "registry-test",
+		Factory:      factory,
+		Capabilities: []Capability{NodePools, Autoscaler},
+	})
+
+	r, ok := Lookup("registry-test")
+	if !ok {
+		t.Fatal("registered provider not found")
+	}
+	if !r.Supports(NodePools, Autoscaler) {
+		t.Fatalf("provider does not support its declared capabilities %v", r.Capabilities)
+	}
+	if r.Supports(NodePools, CgroupsV1) {
+		t.Fatal("provider supports a capability it did not declare")
+	}
+
+	for _, name := range []string{"aws", "azure", "capa", "capd", "registry-test"} {
+		if _, ok := Lookup(name); !ok {
+			t.Fatalf("provider %q not in %v", name, Registered())
+		}
+	}
+
+	t.Setenv(ProviderEnvVarName, "registry-test")
+	if !Supports(Autoscaler) {
+		t.Fatal("expected $PROVIDER to support Autoscaler")
+	}
+	if Supports(DeletionVerification) {
+		t.Fatal("expected $PROVIDER not to support DeletionVerification")
+	}
+
+	t.Setenv(ProviderEnvVarName, "unknown")
+	if Supports() {
+		t.Fatal("expected an unregistered $PROVIDER not to support anything")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering a provider twice to panic")
+		}
+	}()
+	Register(Registration{Name: "registry-test", Factory: factory})
+}
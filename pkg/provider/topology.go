@@ -0,0 +1,225 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/giantswarm/backoff"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"k8s.io/client-go/util/retry"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/randomid"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
+)
+
+// TopologyProviderSupport manages the node pools of clusters created from a
+// ClusterClass. Node pools are added to and removed from
+// `Cluster.Spec.Topology.Workers`, and the topology controller creates and
+// deletes the MachineDeployments and their templates. Everything else is
+// delegated to the provider specific Support.
+type TopologyProviderSupport struct {
+	Support
+
+	logger micrologger.Logger
+}
+
+func NewTopologyProviderSupport(logger micrologger.Logger, support Support) Support {
+	return &TopologyProviderSupport{
+		Support: support,
+		logger:  logger,
+	}
+}
+
+// CreateNodePoolAndWaitReady adds a worker to the topology. Machine settings
+// like the instance type are ClusterClass variables, which are specific to
+// every ClusterClass, so only the replicas, a single availability zone and
+// the annotations of spec are supported.
+func (p *TopologyProviderSupport) CreateNodePoolAndWaitReady(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, spec NodePoolSpec) (*ctrl.ObjectKey, error) {
+	err := spec.Validate()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = spec.unsupported("ClusterClass", "AvailabilityZones", "InstanceType", "Spot", "Taints", "NodeLabels", "Disks", "OSImage", "CgroupsV1")
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	name := randomid.New()
+
+	err = p.addWorker(ctx, client, cluster, name, spec)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	p.logger.Debugf(ctx, "Added machine deployment %s to the topology of cluster %s", name, cluster.Name)
+
+	waiter, err := wait.New(wait.Config{
+		Logger:  p.logger,
+		Client:  client,
+		Timeout: backoff.LongMaxWait,
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Wait for the topology controller to generate the MachineDeployment.
+	var md *capi.MachineDeployment
+	{
+		generated := func(ctrl.Object) (bool, string) {
+			found, err := capiutil.FindTopologyMachineDeployment(ctx, client, cluster, name)
+			if err != nil {
+				return false, err.Error()
+			}
+			md = found
+
+			return true, fmt.Sprintf("MachineDeployment %s generated", md.Name)
+		}
+		err = waiter.UntilPredicate(ctx, cluster.DeepCopy(), fmt.Sprintf("generate MachineDeployment for topology %q", name), generated)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	// Wait for Node Pool to come up.
+	err = waitForNodePoolReady(ctx, p.logger, client, md, replicasReady)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &ctrl.ObjectKey{Name: md.Name, Namespace: md.Namespace}, nil
+}
+
+func (p *TopologyProviderSupport) DeleteNodePool(ctx context.Context, client ctrl.Client, objKey ctrl.ObjectKey) error {
+	md := &capi.MachineDeployment{}
+
+	err := client.Get(ctx, objKey, md)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	name, ok := md.Labels[capi.ClusterTopologyMachineDeploymentNameLabel]
+	if !ok {
+		return microerror.Maskf(executionFailedError, "MachineDeployment %q is not generated from a topology", md.Name)
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cluster := &capi.Cluster{}
+		err := client.Get(ctx, ctrl.ObjectKey{Namespace: md.Namespace, Name: md.Spec.ClusterName}, cluster)
+		if err != nil {
+			return err
+		}
+
+		if cluster.Spec.Topology == nil || cluster.Spec.Topology.Workers == nil {
+			return nil
+		}
+
+		patch := ctrl.MergeFromWithOptions(cluster.DeepCopy(), ctrl.MergeFromWithOptimisticLock{})
+
+		var workers []capi.MachineDeploymentTopology
+		for _, worker := range cluster.Spec.Topology.Workers.MachineDeployments {
+			if worker.Name != name {
+				workers = append(workers, worker)
+			}
+		}
+		cluster.Spec.Topology.Workers.MachineDeployments = workers
+
+		return client.Patch(ctx, cluster, patch)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// GetNodePoolAZsInCR returns the failure domains of the Machines of the node
+// pool, as MachineDeployments of a topology have at most one failure domain.
+func (p *TopologyProviderSupport) GetNodePoolAZsInCR(ctx context.Context, client ctrl.Client, objKey ctrl.ObjectKey) ([]string, error) {
+	md := &capi.MachineDeployment{}
+
+	err := client.Get(ctx, objKey, md)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	zones, err := machineFailureDomains(ctx, client, md)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return zones, nil
+}
+
+// addWorker appends a MachineDeployment to the topology of the cluster. It
+// uses the worker class of the first MachineDeployment not created by the
+// tests, so the node pool is configured like the existing ones. The
+// availability zone of spec, if any, is the failure domain of the
+// MachineDeployment.
+func (p *TopologyProviderSupport) addWorker(ctx context.Context, client ctrl.Client, cluster *capi.Cluster, name string, spec NodePoolSpec) error {
+	replicas, _, _ := spec.replicas()
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current := &capi.Cluster{}
+		err := client.Get(ctx, ctrl.ObjectKeyFromObject(cluster), current)
+		if err != nil {
+			return err
+		}
+
+		class, err := workerClass(current)
+		if err != nil {
+			return err
+		}
+
+		worker := capi.MachineDeploymentTopology{
+			Metadata: capi.ObjectMeta{
+				Labels: map[string]string{
+					capiutil.E2ENodepool: "true",
+				},
+				Annotations: spec.annotations(),
+			},
+			Class:    class,
+			Name:     name,
+			Replicas: to.Int32Ptr(replicas),
+		}
+
+		if len(spec.AvailabilityZones) == 1 {
+			worker.FailureDomain = to.StringPtr(spec.AvailabilityZones[0])
+		}
+
+		patch := ctrl.MergeFromWithOptions(current.DeepCopy(), ctrl.MergeFromWithOptimisticLock{})
+
+		if current.Spec.Topology.Workers == nil {
+			current.Spec.Topology.Workers = &capi.WorkersTopology{}
+		}
+		current.Spec.Topology.Workers.MachineDeployments = append(current.Spec.Topology.Workers.MachineDeployments, worker)
+
+		return client.Patch(ctx, current, patch)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func workerClass(cluster *capi.Cluster) (string, error) {
+	if cluster.Spec.Topology == nil {
+		return "", microerror.Maskf(executionFailedError, "cluster %q is not created from a ClusterClass", cluster.Name)
+	}
+
+	if cluster.Spec.Topology.Workers != nil {
+		for _, worker := range cluster.Spec.Topology.Workers.MachineDeployments {
+			if _, isE2E := worker.Metadata.Labels[capiutil.E2ENodepool]; !isE2E {
+				return worker.Class, nil
+			}
+		}
+	}
+
+	return "", microerror.Maskf(executionFailedError, "cluster %q has no worker MachineDeployment to copy the class from", cluster.Name)
+}
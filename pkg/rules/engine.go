@@ -0,0 +1,252 @@
+package rules
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/microerror"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/assert"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
+)
+
+type Config struct {
+	Client    ctrl.Client
+	ClusterID string
+	Provider  string
+}
+
+// Engine evaluates rules against the CRs of a single cluster.
+type Engine struct {
+	client    ctrl.Client
+	clusterID string
+	provider  string
+}
+
+func New(config Config) (*Engine, error) {
+	if config.Client == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Client must not be empty", config)
+	}
+	if config.ClusterID == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.ClusterID must not be empty", config)
+	}
+
+	e := &Engine{
+		client:    config.Client,
+		clusterID: config.ClusterID,
+		provider:  config.Provider,
+	}
+
+	return e, nil
+}
+
+// Run runs every rule of ruleSet as a subtest of t.
+func (e *Engine) Run(t *testing.T, ctx context.Context, ruleSet RuleSet) {
+	for _, rule := range ruleSet.Rules {
+		rule := rule
+		t.Run(rule.Name, func(t *testing.T) {
+			e.runRule(t, ctx, rule)
+		})
+	}
+}
+
+func (e *Engine) runRule(t *testing.T, ctx context.Context, rule Rule) {
+	if !e.appliesToProvider(rule) {
+		t.Skipf("rule %q does not apply to provider %q", rule.Name, e.provider)
+	}
+
+	objects, err := e.listTargets(ctx, rule.Target)
+	if err != nil {
+		t.Fatalf("error listing %s objects for cluster %q: %s", rule.Target.Kind, e.clusterID, microerror.JSON(err))
+	}
+
+	if len(objects) == 0 {
+		t.Skipf("no %s objects found for cluster %q", rule.Target.Kind, e.clusterID)
+	}
+
+	// Violations of all the objects are reported together at the end of the
+	// rule subtest.
+	c := assert.NewCollector(t, assert.SoftMode)
+
+	for i := range objects {
+		object := &objects[i]
+
+		var owner assert.TestedObject
+		if rule.Owner != "" {
+			owner, err = e.findOwner(ctx, rule.Owner, object)
+			if err != nil {
+				c.Fatalf("%s '%s/%s': error finding %s owner: %s", object.GetKind(), object.GetNamespace(), object.GetName(), rule.Owner, microerror.JSON(err))
+				continue
+			}
+		}
+
+		switch {
+		case rule.Set:
+			assert.FieldIsSet(c, object, rule.Field)
+		case rule.Equals != nil:
+			switch {
+			case rule.Equals.Value != nil:
+				assert.FieldHasValue(c, object, rule.Field, *rule.Equals.Value)
+			case rule.Equals.Field != "":
+				assert.FieldIsEqual(c, object, rule.Equals.Field, object, rule.Field)
+			case rule.Equals.OwnerField != "":
+				assert.FieldIsEqual(c, owner, rule.Equals.OwnerField, object, rule.Field)
+			}
+		case rule.Matches != "":
+			assert.FieldMatches(c, object, rule.Field, regexp.MustCompile(rule.Matches))
+		case rule.WithinRange != nil:
+			lower, ok := resolveNumber(c, rule.WithinRange.Min, object, owner)
+			if !ok {
+				continue
+			}
+			upper, ok := resolveNumber(c, rule.WithinRange.Max, object, owner)
+			if !ok {
+				continue
+			}
+			assert.FieldIsWithinRange(c, object, rule.Field, lower, upper)
+		}
+	}
+}
+
+func (e *Engine) appliesToProvider(rule Rule) bool {
+	if len(rule.Providers) == 0 {
+		return true
+	}
+
+	for _, p := range rule.Providers {
+		if p == e.provider {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *Engine) listTargets(ctx context.Context, target Target) ([]unstructured.Unstructured, error) {
+	gv, err := schema.ParseGroupVersion(target.APIVersion)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	selector := &metav1.LabelSelector{}
+	if target.Selector != nil {
+		selector = target.Selector.DeepCopy()
+	}
+	if selector.MatchLabels == nil {
+		selector.MatchLabels = map[string]string{}
+	}
+	selector.MatchLabels[capi.ClusterNameLabel] = e.clusterID
+
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gv.WithKind(target.Kind + "List"))
+
+	err = e.client.List(ctx, list, ctrl.MatchingLabelsSelector{Selector: labelSelector})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return list.Items, nil
+}
+
+func (e *Engine) findOwner(ctx context.Context, kind string, object *unstructured.Unstructured) (assert.TestedObject, error) {
+	owner, err := ownerFinders[kind](ctx, e.client, object)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	gvk, err := apiutil.GVKForObject(owner, e.client.Scheme())
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	owner.GetObjectKind().SetGroupVersionKind(gvk)
+
+	return owner, nil
+}
+
+func resolveNumber(t assert.TestingT, operand Operand, object assert.TestedObject, owner assert.TestedObject) (float64, bool) {
+	var value string
+	{
+		var err error
+		switch {
+		case operand.Value != nil:
+			value = *operand.Value
+		case operand.Field != "":
+			value, err = assert.FieldValue(object, operand.Field)
+		case operand.OwnerField != "":
+			value, err = assert.FieldValue(owner, operand.OwnerField)
+		}
+		if err != nil {
+			t.Fatalf("%s '%s/%s': cannot evaluate range bound: %s", object.GetObjectKind().GroupVersionKind().Kind, object.GetNamespace(), object.GetName(), microerror.JSON(err))
+			return 0, false
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		t.Fatalf("%s '%s/%s': range bound %q is not a number", object.GetObjectKind().GroupVersionKind().Kind, object.GetNamespace(), object.GetName(), value)
+		return 0, false
+	}
+
+	return number, true
+}
+
+type ownerFinderFunc func(ctx context.Context, client ctrl.Client, object *unstructured.Unstructured) (assert.TestedObject, error)
+
+// ownerFinders resolves the owner of an object by kind, using the labels
+// set on the object.
+var ownerFinders = map[string]ownerFinderFunc{
+	"Cluster": func(ctx context.Context, client ctrl.Client, object *unstructured.Unstructured) (assert.TestedObject, error) {
+		cluster, err := capiutil.FindCluster(ctx, client, object.GetLabels()[capi.ClusterNameLabel])
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return cluster, nil
+	},
+	"MachinePool": func(ctx context.Context, client ctrl.Client, object *unstructured.Unstructured) (assert.TestedObject, error) {
+		machinePool, err := capiutil.FindMachinePool(ctx, client, object.GetLabels()[label.MachinePool])
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return machinePool, nil
+	},
+	"MachineDeployment": func(ctx context.Context, client ctrl.Client, object *unstructured.Unstructured) (assert.TestedObject, error) {
+		machineDeployment, err := capiutil.FindMachineDeployment(ctx, client, object.GetLabels()[capi.ClusterNameLabel], object.GetLabels()[capi.MachineDeploymentNameLabel])
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return machineDeployment, nil
+	},
+	"AzureCluster": func(ctx context.Context, client ctrl.Client, object *unstructured.Unstructured) (assert.TestedObject, error) {
+		azureCluster, err := capiutil.FindAzureCluster(ctx, client, object.GetLabels()[capi.ClusterNameLabel])
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return azureCluster, nil
+	},
+	"AzureMachinePool": func(ctx context.Context, client ctrl.Client, object *unstructured.Unstructured) (assert.TestedObject, error) {
+		azureMachinePool, err := capiutil.FindAzureMachinePool(ctx, client, object.GetLabels()[label.MachinePool])
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return azureMachinePool, nil
+	},
+}
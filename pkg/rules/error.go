@@ -0,0 +1,21 @@
+package rules
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidRuleError = &microerror.Error{
+	Kind: "invalidRuleError",
+}
+
+// IsInvalidRule asserts invalidRuleError.
+func IsInvalidRule(err error) bool {
+	return microerror.Cause(err) == invalidRuleError
+}
@@ -0,0 +1,144 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/giantswarm/microerror"
+)
+
+// LoadDir loads all the rule files with a .yaml or .yml extension found in
+// dir, sorted by file name.
+func LoadDir(dir string) ([]RuleSet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(paths)
+
+	var ruleSets []RuleSet
+	for _, path := range paths {
+		ruleSet, err := LoadFile(path)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		ruleSets = append(ruleSets, *ruleSet)
+	}
+
+	return ruleSets, nil
+}
+
+// LoadFile loads and validates a single rule file.
+func LoadFile(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var ruleSet RuleSet
+	err = yaml.UnmarshalStrict(data, &ruleSet, yaml.DisallowUnknownFields)
+	if err != nil {
+		return nil, microerror.Maskf(invalidRuleError, "cannot parse rule file %q: %s", path, err)
+	}
+
+	ruleSet.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	names := map[string]bool{}
+	for _, rule := range ruleSet.Rules {
+		err = validate(rule)
+		if err != nil {
+			return nil, microerror.Maskf(invalidRuleError, "rule file %q: %s", path, err)
+		}
+
+		if names[rule.Name] {
+			return nil, microerror.Maskf(invalidRuleError, "rule file %q: duplicate rule name %q", path, rule.Name)
+		}
+		names[rule.Name] = true
+	}
+
+	return &ruleSet, nil
+}
+
+func validate(rule Rule) error {
+	if rule.Name == "" {
+		return microerror.Maskf(invalidRuleError, "rule name must not be empty")
+	}
+
+	if rule.Target.APIVersion == "" || rule.Target.Kind == "" {
+		return microerror.Maskf(invalidRuleError, "rule %q: target.apiVersion and target.kind must not be empty", rule.Name)
+	}
+
+	if rule.Field == "" {
+		return microerror.Maskf(invalidRuleError, "rule %q: field must not be empty", rule.Name)
+	}
+
+	if rule.Owner != "" {
+		if _, ok := ownerFinders[rule.Owner]; !ok {
+			return microerror.Maskf(invalidRuleError, "rule %q: unsupported owner kind %q", rule.Name, rule.Owner)
+		}
+	}
+
+	var operands []Operand
+	var operators int
+	{
+		if rule.Set {
+			operators++
+		}
+		if rule.Equals != nil {
+			operators++
+			operands = append(operands, *rule.Equals)
+		}
+		if rule.Matches != "" {
+			operators++
+			_, err := regexp.Compile(rule.Matches)
+			if err != nil {
+				return microerror.Maskf(invalidRuleError, "rule %q: invalid regular expression %q: %s", rule.Name, rule.Matches, err)
+			}
+		}
+		if rule.WithinRange != nil {
+			operators++
+			operands = append(operands, rule.WithinRange.Min, rule.WithinRange.Max)
+		}
+	}
+
+	if operators != 1 {
+		return microerror.Maskf(invalidRuleError, "rule %q: exactly one of set, equals, matches and withinRange must be specified", rule.Name)
+	}
+
+	for _, operand := range operands {
+		var fields int
+		if operand.Value != nil {
+			fields++
+		}
+		if operand.Field != "" {
+			fields++
+		}
+		if operand.OwnerField != "" {
+			fields++
+			if rule.Owner == "" {
+				return microerror.Maskf(invalidRuleError, "rule %q: ownerField requires owner to be set", rule.Name)
+			}
+		}
+
+		if fields != 1 {
+			return microerror.Maskf(invalidRuleError, "rule %q: exactly one of value, field and ownerField must be specified", rule.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_LoadFile(t *testing.T) {
+	testCases := []struct {
+		name  string
+		data  string
+		valid bool
+	}{
+		{
+			name: "case 0: valid rules",
+			data: `
+rules:
+  - name: machinepool-label-set
+    target:
+      apiVersion: cluster.x-k8s.io/v1beta1
+      kind: MachinePool
+    field: '{.metadata.labels.giantswarm\.io/machine-pool}'
+    set: true
+  - name: release-version-matches-cluster
+    target:
+      apiVersion: cluster.x-k8s.io/v1beta1
+      kind: MachinePool
+    owner: Cluster
+    field: '{.metadata.labels.release\.giantswarm\.io/version}'
+    equals:
+      ownerField: '{.metadata.labels.release\.giantswarm\.io/version}'
+`,
+			valid: true,
+		},
+		{
+			name: "case 1: malformed YAML",
+			data: `
+rules:
+  - name: [machinepool-label-set
+`,
+		},
+		{
+			name: "case 2: unknown field",
+			data: `
+rules:
+  - name: machinepool-label-set
+    target:
+      apiVersion: cluster.x-k8s.io/v1beta1
+      kind: MachinePool
+    field: '{.metadata.name}'
+    set: true
+    severity: warning
+`,
+		},
+		{
+			name: "case 3: duplicate rule name",
+			data: `
+rules:
+  - name: machinepool-label-set
+    target:
+      apiVersion: cluster.x-k8s.io/v1beta1
+      kind: MachinePool
+    field: '{.metadata.name}'
+    set: true
+  - name: machinepool-label-set
+    target:
+      apiVersion: cluster.x-k8s.io/v1beta1
+      kind: MachinePool
+    field: '{.metadata.namespace}'
+    set: true
+`,
+		},
+		{
+			name: "case 4: two operators",
+			data: `
+rules:
+  - name: machinepool-label-set
+    target:
+      apiVersion: cluster.x-k8s.io/v1beta1
+      kind: MachinePool
+    field: '{.metadata.name}'
+    set: true
+    matches: '^[a-z0-9]+$'
+`,
+		},
+		{
+			name: "case 5: owner field without owner",
+			data: `
+rules:
+  - name: release-version-matches-cluster
+    target:
+      apiVersion: cluster.x-k8s.io/v1beta1
+      kind: MachinePool
+    field: '{.metadata.name}'
+    equals:
+      ownerField: '{.metadata.name}'
+`,
+		},
+		{
+			name: "case 6: invalid regular expression",
+			data: `
+rules:
+  - name: machinepool-name
+    target:
+      apiVersion: cluster.x-k8s.io/v1beta1
+      kind: MachinePool
+    field: '{.metadata.name}'
+    matches: '[a-z'
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "machinepool.yaml")
+			err := os.WriteFile(path, []byte(tc.data), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ruleSet, err := LoadFile(path)
+			if tc.valid {
+				if err != nil {
+					t.Fatalf("expected rule file to be valid, got %v", err)
+				}
+				if ruleSet.Name != "machinepool" || len(ruleSet.Rules) != 2 {
+					t.Fatalf("expected 2 rules in rule set machinepool, got %d in %q", len(ruleSet.Rules), ruleSet.Name)
+				}
+			} else if !IsInvalidRule(err) {
+				t.Fatalf("expected invalidRuleError, got %v", err)
+			}
+		})
+	}
+}
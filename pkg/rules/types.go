@@ -0,0 +1,71 @@
+package rules
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RuleSet is the content of a single rule file.
+type RuleSet struct {
+	// Name is the name of the rule file without its extension. It is used as
+	// the name of the subtest grouping the rules of the file.
+	Name  string `json:"-"`
+	Rules []Rule `json:"rules"`
+}
+
+// Rule describes an invariant that must hold for every object of the
+// target kind belonging to the tested cluster, e.g.
+//
+//	name: machinepool-release-version
+//	target:
+//	  apiVersion: cluster.x-k8s.io/v1beta1
+//	  kind: MachinePool
+//	owner: Cluster
+//	field: '{.metadata.labels.release\.giantswarm\.io/version}'
+//	equals:
+//	  ownerField: '{.metadata.labels.release\.giantswarm\.io/version}'
+//
+// Exactly one of Set, Equals, Matches and WithinRange must be specified.
+type Rule struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Providers limits the rule to the listed providers. The rule applies to
+	// all providers when empty.
+	Providers []string `json:"providers,omitempty"`
+	Target    Target   `json:"target"`
+	// Owner is the kind of the owner object resolved for every target
+	// object. It is required when any operand refers to an owner field.
+	Owner string `json:"owner,omitempty"`
+	// Field is the JSONPath expression evaluated against the target object.
+	Field string `json:"field"`
+
+	Set         bool     `json:"set,omitempty"`
+	Equals      *Operand `json:"equals,omitempty"`
+	Matches     string   `json:"matches,omitempty"`
+	WithinRange *Range   `json:"withinRange,omitempty"`
+}
+
+// Target selects the objects a rule applies to. Objects are always limited
+// to the ones labeled with the tested cluster ID.
+type Target struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Selector   *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// Operand is the value a field is compared to. Exactly one of its fields
+// must be specified.
+type Operand struct {
+	// Value is a literal value.
+	Value *string `json:"value,omitempty"`
+	// Field is a JSONPath expression evaluated against the target object.
+	Field string `json:"field,omitempty"`
+	// OwnerField is a JSONPath expression evaluated against the owner of
+	// the target object.
+	OwnerField string `json:"ownerField,omitempty"`
+}
+
+// Range defines inclusive numeric bounds.
+type Range struct {
+	Min Operand `json:"min"`
+	Max Operand `json:"max"`
+}
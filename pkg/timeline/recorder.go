@@ -0,0 +1,334 @@
+// Package timeline records the condition transitions of objects while tests
+// run, so slow or flapping reconciliation can be debugged and asserted on
+// after the fact.
+package timeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	clusterNameLabel = "cluster.x-k8s.io/cluster-name"
+
+	rewatchInterval = 30 * time.Second
+)
+
+// Transition is a change of the status or reason of a condition, or the
+// first time a condition was observed.
+type Transition struct {
+	// Time is when the transition was observed.
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Status    string    `json:"status"`
+	Reason    string    `json:"reason,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	// LastTransitionTime is the lastTransitionTime of the condition, which
+	// is not updated when only the reason changes.
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+func (t Transition) String() string {
+	s := fmt.Sprintf("%s %s %s %s=%s", t.Time.UTC().Format(time.RFC3339), t.Kind, objectName(t.Namespace, t.Name), t.Type, t.Status)
+	if t.Reason != "" {
+		s += fmt.Sprintf(" reason=%q", t.Reason)
+	}
+	if t.Message != "" {
+		s += fmt.Sprintf(" message=%q", t.Message)
+	}
+
+	return s
+}
+
+type RecorderConfig struct {
+	Logger micrologger.Logger
+	// Client is a client of the cluster holding the recorded objects.
+	Client client.WithWatch
+	// Kinds are the kinds of the recorded objects. Kinds the cluster does
+	// not serve are skipped.
+	Kinds []schema.GroupVersionKind
+	// ClusterID limits the recorded objects to the ones named after the
+	// cluster or labeled with its name. All objects are recorded when empty.
+	ClusterID string
+}
+
+// Recorder watches objects and records the transitions of their
+// status.conditions.
+type Recorder struct {
+	logger    micrologger.Logger
+	client    client.WithWatch
+	kinds     []schema.GroupVersionKind
+	clusterID string
+
+	mutex       sync.Mutex
+	conditions  map[string]map[string]Transition
+	transitions []Transition
+	cancel      context.CancelFunc
+	done        sync.WaitGroup
+}
+
+func NewRecorder(config RecorderConfig) (*Recorder, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Client == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Client must not be empty", config)
+	}
+	if len(config.Kinds) == 0 {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Kinds must not be empty", config)
+	}
+
+	r := &Recorder{
+		logger:    config.Logger,
+		client:    config.Client,
+		kinds:     config.Kinds,
+		clusterID: config.ClusterID,
+
+		conditions: map[string]map[string]Transition{},
+	}
+
+	return r, nil
+}
+
+// Start records the transitions in the background until Stop is called or
+// ctx ends. The conditions objects already have when Start is called are
+// recorded as their first transitions.
+func (r *Recorder) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	for _, gvk := range r.kinds {
+		r.done.Add(1)
+		go func(gvk schema.GroupVersionKind) {
+			defer r.done.Done()
+			r.record(ctx, gvk)
+		}(gvk)
+	}
+}
+
+// Stop stops recording and waits for the watches to end.
+func (r *Recorder) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.done.Wait()
+}
+
+// Transitions returns the recorded transitions of the condition of
+// conditionType of the given object, oldest first.
+func (r *Recorder) Transitions(kind string, namespace string, name string, conditionType string) []Transition {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var transitions []Transition
+	for _, t := range r.transitions {
+		if t.Kind == kind && t.Namespace == namespace && t.Name == name && t.Type == conditionType {
+			transitions = append(transitions, t)
+		}
+	}
+
+	return transitions
+}
+
+// All returns all recorded transitions, oldest first.
+func (r *Recorder) All() []Transition {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	transitions := make([]Transition, len(r.transitions))
+	copy(transitions, r.transitions)
+
+	return transitions
+}
+
+// WriteFile writes the recorded transitions to path, one per line, oldest
+// first.
+func (r *Recorder) WriteFile(path string) error {
+	var b strings.Builder
+	for _, t := range r.All() {
+		b.WriteString(t.String())
+		b.WriteString("\n")
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = os.WriteFile(path, []byte(b.String()), 0644)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// record lists and watches the objects of gvk until ctx ends. Watches closed
+// by the API server are restarted after listing the objects again, which only
+// adds transitions for conditions that changed in between.
+func (r *Recorder) record(ctx context.Context, gvk schema.GroupVersionKind) {
+	for {
+		err := r.listAndWatch(ctx, gvk)
+		if meta.IsNoMatchError(err) {
+			r.logger.Debugf(ctx, "Not recording the conditions of %s: %s", gvk.Kind, err)
+			return
+		} else if err != nil {
+			r.logger.Debugf(ctx, "Error watching %s, retrying in %s: %s", gvk.Kind, rewatchInterval, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(rewatchInterval):
+		}
+	}
+}
+
+func (r *Recorder) listAndWatch(ctx context.Context, gvk schema.GroupVersionKind) error {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+
+	err := r.client.List(ctx, list)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for i := range list.Items {
+		if r.matches(&list.Items[i]) {
+			r.observe(ctx, &list.Items[i])
+		}
+	}
+
+	events, err := r.client.Watch(ctx, list, &client.ListOptions{Raw: &metav1.ListOptions{ResourceVersion: list.GetResourceVersion()}})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer events.Stop()
+
+	r.consume(ctx, events)
+
+	return nil
+}
+
+func (r *Recorder) consume(ctx context.Context, events watch.Interface) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events.ResultChan():
+			if !ok {
+				return
+			}
+
+			obj, ok := event.Object.(*unstructured.Unstructured)
+			if !ok || !r.matches(obj) {
+				continue
+			}
+
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				r.observe(ctx, obj)
+			case watch.Deleted:
+				r.forget(obj)
+			}
+		}
+	}
+}
+
+func (r *Recorder) matches(obj *unstructured.Unstructured) bool {
+	if r.clusterID == "" {
+		return true
+	}
+
+	return obj.GetName() == r.clusterID || obj.GetLabels()[clusterNameLabel] == r.clusterID
+}
+
+// observe records the conditions of obj whose status or reason changed since
+// they were last observed.
+func (r *Recorder) observe(ctx context.Context, obj *unstructured.Unstructured) {
+	now := time.Now()
+	key := objectKey(obj)
+
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	known, ok := r.conditions[key]
+	if !ok {
+		known = map[string]Transition{}
+		r.conditions[key] = known
+	}
+
+	var transitions []Transition
+	for _, c := range conditions {
+		fields, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		t := Transition{
+			Time:      now,
+			Kind:      obj.GetKind(),
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+		}
+		t.Type, _, _ = unstructured.NestedString(fields, "type")
+		t.Status, _, _ = unstructured.NestedString(fields, "status")
+		t.Reason, _, _ = unstructured.NestedString(fields, "reason")
+		t.Message, _, _ = unstructured.NestedString(fields, "message")
+		t.LastTransitionTime, _, _ = unstructured.NestedString(fields, "lastTransitionTime")
+
+		previous, ok := known[t.Type]
+		if ok && previous.Status == t.Status && previous.Reason == t.Reason {
+			continue
+		}
+
+		known[t.Type] = t
+		transitions = append(transitions, t)
+	}
+
+	sort.Slice(transitions, func(i, j int) bool {
+		return transitions[i].Type < transitions[j].Type
+	})
+	for _, t := range transitions {
+		r.logger.Debugf(ctx, "Condition transition: %s", t)
+	}
+	r.transitions = append(r.transitions, transitions...)
+}
+
+func (r *Recorder) forget(obj *unstructured.Unstructured) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.conditions, objectKey(obj))
+}
+
+func objectKey(obj *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s", obj.GetKind(), objectName(obj.GetNamespace(), obj.GetName()))
+}
+
+func objectName(namespace string, name string) string {
+	if namespace == "" {
+		return name
+	}
+
+	return namespace + "/" + name
+}
@@ -0,0 +1,140 @@
+package timeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var clusterGVK = schema.GroupVersionKind{Group: "cluster.x-k8s.io", Version: "v1beta1", Kind: "Cluster"}
+
+func Test_Recorder(t *testing.T) {
+	ctx := context.Background()
+
+	c := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).WithObjects(
+		newTestCluster("abc12", map[string]string{"Ready": "False", "Upgrading": "False"}),
+		newTestCluster("def34", map[string]string{"Ready": "True"}),
+	).Build()
+
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := NewRecorder(RecorderConfig{
+		Logger:    logger,
+		Client:    c,
+		Kinds:     []schema.GroupVersionKind{clusterGVK},
+		ClusterID: "abc12",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder.Start(ctx)
+
+	waitForTransitions(t, recorder, 2)
+	setConditions(t, c, "abc12", map[string]string{"Ready": "False", "Upgrading": "True"})
+	waitForTransitions(t, recorder, 3)
+	// Unchanged conditions are not recorded again.
+	setConditions(t, c, "abc12", map[string]string{"Ready": "False", "Upgrading": "True"})
+	setConditions(t, c, "abc12", map[string]string{"Ready": "True", "Upgrading": "False"})
+	waitForTransitions(t, recorder, 5)
+
+	recorder.Stop()
+
+	var upgrading []string
+	for _, tr := range recorder.Transitions("Cluster", "org-test", "abc12", "Upgrading") {
+		upgrading = append(upgrading, tr.Status)
+	}
+	if expected := []string{"False", "True", "False"}; !reflect.DeepEqual(upgrading, expected) {
+		t.Fatalf("Upgrading transitions == %v, want %v", upgrading, expected)
+	}
+
+	all := recorder.All()
+	if len(all) != 5 {
+		t.Fatalf("recorded %d transitions, want 5: %v", len(all), all)
+	}
+	for _, tr := range all {
+		if tr.Name != "abc12" {
+			t.Fatalf("recorded transition of another cluster: %s", tr)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "timeline.txt")
+	err = recorder.WriteFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `Cluster org-test/abc12 Upgrading=True reason="Test"`) {
+		t.Fatalf("timeline == %q, want it to contain the Upgrading transition", data)
+	}
+}
+
+func waitForTransitions(t *testing.T, recorder *Recorder, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(recorder.All()) < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("recorded %d transitions, want %d: %v", len(recorder.All()), count, recorder.All())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func setConditions(t *testing.T, c client.Client, name string, conditions map[string]string) {
+	t.Helper()
+
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(clusterGVK)
+	err := c.Get(context.Background(), client.ObjectKey{Namespace: "org-test", Name: name}, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setTestConditions(current, conditions)
+	err = c.Update(context.Background(), current)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestCluster(name string, conditions map[string]string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(clusterGVK)
+	u.SetNamespace("org-test")
+	u.SetName(name)
+	setTestConditions(u, conditions)
+
+	return u
+}
+
+// setTestConditions replaces the conditions of u, mapping their types to
+// their status.
+func setTestConditions(u *unstructured.Unstructured, conditions map[string]string) {
+	var list []interface{}
+	for conditionType, status := range conditions {
+		list = append(list, map[string]interface{}{
+			"type":   conditionType,
+			"status": status,
+			"reason": "Test",
+		})
+	}
+	_ = unstructured.SetNestedSlice(u.Object, list, "status", "conditions")
+}
@@ -0,0 +1,225 @@
+package wait
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UntilPredicate waits until predicate holds for obj. obj must have its name,
+// and namespace if any, set. It is updated with the last observed state of
+// the object.
+func (w *Waiter) UntilPredicate(ctx context.Context, obj client.Object, awaited string, predicate Predicate) error {
+	key := client.ObjectKeyFromObject(obj)
+
+	check := func(ctx context.Context) (bool, string, error) {
+		err := w.client.Get(ctx, key, obj)
+		if apierrors.IsNotFound(err) {
+			return false, "not found", nil
+		} else if err != nil {
+			return false, fmt.Sprintf("cannot get object: %s", err), nil
+		}
+
+		done, state := predicate(obj)
+		return done, state, nil
+	}
+
+	watchFunc := func(ctx context.Context) watch.Interface {
+		return w.watch(ctx, obj)
+	}
+
+	err := w.until(ctx, w.describe(obj), awaited, watchFunc, check)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// UntilList waits until predicate holds for the objects selected by opts,
+// e.g. until all Nodes of a cluster are ready. list is updated with the last
+// observed objects.
+func (w *Waiter) UntilList(ctx context.Context, list client.ObjectList, awaited string, predicate ListPredicate, opts ...client.ListOption) error {
+	check := func(ctx context.Context) (bool, string, error) {
+		err := w.client.List(ctx, list, opts...)
+		if err != nil {
+			return false, fmt.Sprintf("cannot list objects: %s", err), nil
+		}
+
+		done, state := predicate(list)
+		return done, state, nil
+	}
+
+	watchFunc := func(ctx context.Context) watch.Interface {
+		return w.watchList(ctx, list, opts...)
+	}
+
+	err := w.until(ctx, w.describeList(list, opts...), awaited, watchFunc, check)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// Until polls check until it reports what reached the awaited state, e.g. for
+// Prometheus targets or cloud provider resources, which cannot be watched.
+// Errors returned by check stop waiting.
+func (w *Waiter) Until(ctx context.Context, what string, awaited string, check Check) error {
+	err := w.until(ctx, what, awaited, nil, check)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// UntilCondition waits until obj has a condition of conditionType in
+// status.conditions with the given status. It works with any object using
+// the Kubernetes or Cluster API conditions, e.g. Nodes or Clusters.
+func (w *Waiter) UntilCondition(ctx context.Context, obj client.Object, conditionType string, status corev1.ConditionStatus) error {
+	predicate := func(obj client.Object) (bool, string) {
+		condition, err := getCondition(obj, conditionType)
+		if err != nil {
+			return false, err.Error()
+		}
+		if condition == nil {
+			return false, fmt.Sprintf("condition %s is not set", conditionType)
+		}
+
+		state := fmt.Sprintf("condition %s is %s", conditionType, condition["status"])
+		if reason := condition["reason"]; reason != "" {
+			state += fmt.Sprintf(", reason %q", reason)
+		}
+		if message := condition["message"]; message != "" {
+			state += fmt.Sprintf(", message %q", message)
+		}
+
+		return condition["status"] == string(status), state
+	}
+
+	err := w.UntilPredicate(ctx, obj, fmt.Sprintf("have condition %s=%s", conditionType, status), predicate)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// UntilPhase waits until status.phase of obj is phase, e.g. Running for a Pod
+// or Provisioned for a Cluster.
+func (w *Waiter) UntilPhase(ctx context.Context, obj client.Object, phase string) error {
+	predicate := func(obj client.Object) (bool, string) {
+		content, err := toUnstructured(obj)
+		if err != nil {
+			return false, err.Error()
+		}
+
+		current, _, _ := unstructured.NestedString(content, "status", "phase")
+		if current == "" {
+			return false, "phase is not set"
+		}
+
+		return current == phase, fmt.Sprintf("phase is %s", current)
+	}
+
+	err := w.UntilPredicate(ctx, obj, fmt.Sprintf("reach phase %s", phase), predicate)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// UntilDeleted waits until obj is gone. An object with the same name but a
+// different UID than obj counts as deleted.
+func (w *Waiter) UntilDeleted(ctx context.Context, obj client.Object) error {
+	key := client.ObjectKeyFromObject(obj)
+
+	check := func(ctx context.Context) (bool, string, error) {
+		current, ok := obj.DeepCopyObject().(client.Object)
+		if !ok {
+			return false, "", microerror.Maskf(invalidConfigError, "cannot copy %T", obj)
+		}
+
+		err := w.client.Get(ctx, key, current)
+		if apierrors.IsNotFound(err) {
+			return true, "deleted", nil
+		} else if err != nil {
+			return false, fmt.Sprintf("cannot get object: %s", err), nil
+		}
+
+		if obj.GetUID() != "" && current.GetUID() != obj.GetUID() {
+			return true, "recreated", nil
+		}
+
+		state := "exists"
+		if current.GetDeletionTimestamp() != nil {
+			state = fmt.Sprintf("being deleted since %s", current.GetDeletionTimestamp().UTC().Format("15:04:05"))
+			if finalizers := current.GetFinalizers(); len(finalizers) > 0 {
+				state += fmt.Sprintf(", finalizers %v", finalizers)
+			}
+		}
+
+		return false, state, nil
+	}
+
+	watchFunc := func(ctx context.Context) watch.Interface {
+		return w.watch(ctx, obj)
+	}
+
+	err := w.until(ctx, w.describe(obj), "be deleted", watchFunc, check)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// getCondition returns the fields of the condition of conditionType in
+// status.conditions of obj, or nil when it has none.
+func getCondition(obj client.Object, conditionType string) (map[string]string, error) {
+	content, err := toUnstructured(obj)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(content, "status", "conditions")
+	for _, c := range conditions {
+		fields, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		condition := map[string]string{}
+		for _, f := range []string{"type", "status", "reason", "message"} {
+			condition[f], _, _ = unstructured.NestedString(fields, f)
+		}
+
+		if condition["type"] == conditionType {
+			return condition, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func toUnstructured(obj client.Object) (map[string]interface{}, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.Object, nil
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,264 @@
+// Package wait waits for objects of a cluster to reach a state. Waiting is
+// driven by watch events when the client supports watching, and falls back to
+// polling otherwise, e.g. when API fixtures are replayed.
+package wait
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	DefaultInterval = 30 * time.Second
+	DefaultTimeout  = 20 * time.Minute
+)
+
+// Predicate reports whether obj reached the awaited state, along with a
+// description of its current state used in logs and timeout errors.
+type Predicate func(obj client.Object) (bool, string)
+
+// ListPredicate reports whether the objects of list reached the awaited
+// state, along with a description of their current state.
+type ListPredicate func(list client.ObjectList) (bool, string)
+
+// Check reports whether the awaited state is reached, along with a
+// description of the current state. A non-nil error stops waiting and is
+// returned as is, e.g. when the awaited state cannot be reached anymore.
+type Check func(ctx context.Context) (bool, string, error)
+
+type Config struct {
+	Logger micrologger.Logger
+	// Client is a client of the cluster holding the awaited objects. Waiting
+	// is driven by watch events when it implements client.WithWatch.
+	Client client.Client
+	// Interval is the interval objects are polled at when they cannot be
+	// watched. Watched objects are also checked at that interval, in case an
+	// event is missed. Defaults to DefaultInterval.
+	Interval time.Duration
+	// Timeout is the time to wait for before giving up, unless the context
+	// expires earlier. Defaults to DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Waiter waits for the objects of a single cluster.
+type Waiter struct {
+	logger   micrologger.Logger
+	client   client.Client
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func New(config Config) (*Waiter, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Client == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Client must not be empty", config)
+	}
+	if config.Interval == 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	w := &Waiter{
+		logger:   config.Logger,
+		client:   config.Client,
+		interval: config.Interval,
+		timeout:  config.Timeout,
+	}
+
+	return w, nil
+}
+
+// until calls check whenever the awaited objects may have changed until it
+// reports they are in the awaited state, or fails. The timeout error holds the
+// last state check described. watchFunc may be nil for state that can only be
+// polled.
+func (w *Waiter) until(ctx context.Context, what string, awaited string, watchFunc func(context.Context) watch.Interface, check Check) error {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	if watchFunc == nil {
+		watchFunc = func(context.Context) watch.Interface { return nil }
+	}
+
+	events := watchFunc(ctx)
+	defer func() {
+		if events != nil {
+			events.Stop()
+		}
+	}()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	var state string
+	var observed time.Time
+	for {
+		done, current, err := check(ctx)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		if done {
+			w.logger.Debugf(ctx, "Done waiting for %s to %s after %s", what, awaited, time.Since(start).Round(time.Second))
+			return nil
+		}
+		if current != state {
+			w.logger.Debugf(ctx, "Waiting for %s to %s: %s", what, awaited, current)
+		}
+		state = current
+		observed = time.Now()
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return microerror.Maskf(timeoutError, "timed out after %s waiting for %s to %s, last observed %s ago: %s", time.Since(start).Round(time.Second), what, awaited, time.Since(observed).Round(time.Second), state)
+			}
+			return microerror.Mask(ctx.Err())
+		case _, ok := <-resultChan(events):
+			if !ok {
+				// The API server closes watches after a while. Watch again
+				// on the next tick, polling in between.
+				events.Stop()
+				events = nil
+			}
+		case <-ticker.C:
+			if events == nil {
+				events = watchFunc(ctx)
+			}
+		}
+	}
+}
+
+// watch watches obj, or returns nil when the client cannot watch it.
+func (w *Waiter) watch(ctx context.Context, obj client.Object) watch.Interface {
+	gvk, err := apiutil.GVKForObject(obj, w.client.Scheme())
+	if err != nil {
+		w.logger.Debugf(ctx, "Polling %T %s: %s", obj, objectName(obj), err)
+		return nil
+	}
+
+	var list client.ObjectList
+	{
+		listGVK := gvk.GroupVersion().WithKind(gvk.Kind + "List")
+		if _, ok := obj.(*unstructured.Unstructured); ok {
+			u := &unstructured.UnstructuredList{}
+			u.SetGroupVersionKind(listGVK)
+			list = u
+		} else {
+			o, err := w.client.Scheme().New(listGVK)
+			if err != nil {
+				w.logger.Debugf(ctx, "Polling %s %s: %s", gvk.Kind, objectName(obj), err)
+				return nil
+			}
+			var ok bool
+			list, ok = o.(client.ObjectList)
+			if !ok {
+				w.logger.Debugf(ctx, "Polling %s %s: %T is not a list", gvk.Kind, objectName(obj), o)
+				return nil
+			}
+		}
+	}
+
+	var opts []client.ListOption
+	if obj.GetNamespace() != "" {
+		opts = append(opts, client.InNamespace(obj.GetNamespace()))
+	}
+	opts = append(opts, client.MatchingFields{"metadata.name": obj.GetName()})
+
+	return w.watchList(ctx, list, opts...)
+}
+
+// watchList watches the objects of list selected by opts, or returns nil when
+// the client cannot watch them.
+func (w *Waiter) watchList(ctx context.Context, list client.ObjectList, opts ...client.ListOption) watch.Interface {
+	watchClient, ok := w.client.(client.WithWatch)
+	if !ok {
+		return nil
+	}
+
+	// The watch only uses list to find the resource. Keep it untouched.
+	copied, ok := list.DeepCopyObject().(client.ObjectList)
+	if !ok {
+		return nil
+	}
+
+	events, err := watchClient.Watch(ctx, copied, opts...)
+	if err != nil {
+		w.logger.Debugf(ctx, "Polling %s: %s", w.kind(list), err)
+		return nil
+	}
+
+	return events
+}
+
+// describe returns the kind and name of obj, e.g. "Node worker-1".
+func (w *Waiter) describe(obj client.Object) string {
+	return fmt.Sprintf("%s %s", w.kind(obj), objectName(obj))
+}
+
+// describeList returns the kind of list along with the namespace and
+// selectors of opts, e.g. "Pods in kube-system with app=cilium".
+func (w *Waiter) describeList(list client.ObjectList, opts ...client.ListOption) string {
+	listOptions := &client.ListOptions{}
+	listOptions.ApplyOptions(opts)
+
+	what := strings.TrimSuffix(w.kind(list), "List") + "s"
+	if listOptions.Namespace != "" {
+		what += fmt.Sprintf(" in %s", listOptions.Namespace)
+	}
+	var selectors []string
+	if listOptions.LabelSelector != nil && !listOptions.LabelSelector.Empty() {
+		selectors = append(selectors, listOptions.LabelSelector.String())
+	}
+	if listOptions.FieldSelector != nil && !listOptions.FieldSelector.Empty() {
+		selectors = append(selectors, listOptions.FieldSelector.String())
+	}
+	if len(selectors) > 0 {
+		what += fmt.Sprintf(" with %s", strings.Join(selectors, ","))
+	}
+
+	return what
+}
+
+func (w *Waiter) kind(obj runtime.Object) string {
+	gvk, err := apiutil.GVKForObject(obj, w.client.Scheme())
+	if err != nil {
+		return strings.TrimPrefix(fmt.Sprintf("%T", obj), "*")
+	}
+
+	return gvk.Kind
+}
+
+// objectName returns namespace/name of namespaced objects and the name of
+// cluster scoped ones.
+func objectName(obj client.Object) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+
+	return client.ObjectKeyFromObject(obj).String()
+}
+
+// resultChan returns the result channel of events, or a nil channel blocking
+// forever when events is nil.
+func resultChan(events watch.Interface) <-chan watch.Event {
+	if events == nil {
+		return nil
+	}
+
+	return events.ResultChan()
+}
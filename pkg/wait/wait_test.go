@@ -0,0 +1,248 @@
+package wait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// pollingClient hides the Watch method of the fake client.
+type pollingClient struct {
+	client.Client
+}
+
+func Test_Waiter_UntilCondition(t *testing.T) {
+	testCases := []struct {
+		name   string
+		watch  bool
+		update bool
+	}{
+		{
+			name:   "case 0: watched condition",
+			watch:  true,
+			update: true,
+		},
+		{
+			name:   "case 1: polled condition",
+			update: true,
+		},
+		{
+			name:  "case 2: condition never set",
+			watch: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker-1"},
+				Status: corev1.NodeStatus{
+					Conditions: []corev1.NodeCondition{
+						{Type: corev1.NodeReady, Status: corev1.ConditionFalse, Reason: "KubeletNotReady", Message: "CNI not initialized"},
+					},
+				},
+			}
+			fakeClient := newFakeClient(t, node)
+
+			var c client.Client = fakeClient
+			interval := time.Hour
+			if !tc.watch {
+				c = pollingClient{Client: fakeClient}
+				interval = 10 * time.Millisecond
+			}
+			w := newTestWaiter(t, c, interval, time.Second)
+
+			if tc.update {
+				updateLater(t, fakeClient, node, func(obj client.Object) {
+					obj.(*corev1.Node).Status.Conditions[0].Status = corev1.ConditionTrue
+				})
+			}
+
+			awaited := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}}
+			err := w.UntilCondition(ctx, awaited, string(corev1.NodeReady), corev1.ConditionTrue)
+			if tc.update {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if awaited.Status.Conditions[0].Status != corev1.ConditionTrue {
+					t.Fatalf("node was not updated with its last state: %#v", awaited.Status)
+				}
+				return
+			}
+
+			if !IsTimeout(err) {
+				t.Fatalf("error == %#v, want timeoutError", err)
+			}
+			expected := `Node worker-1 to have condition Ready=True, last observed 1s ago: condition Ready is False, reason "KubeletNotReady", message "CNI not initialized"`
+			if !strings.Contains(err.Error(), expected) {
+				t.Fatalf("error == %q, want it to contain %q", err.Error(), expected)
+			}
+		})
+	}
+}
+
+func Test_Waiter_UntilPhase(t *testing.T) {
+	ctx := context.Background()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Status:     corev1.PodStatus{Phase: corev1.PodPending},
+	}
+	c := newFakeClient(t, pod)
+	w := newTestWaiter(t, c, time.Hour, time.Second)
+
+	updateLater(t, c, pod, func(obj client.Object) {
+		obj.(*corev1.Pod).Status.Phase = corev1.PodRunning
+	})
+
+	err := w.UntilPhase(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"}}, string(corev1.PodRunning))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Waiter_UntilDeleted(t *testing.T) {
+	ctx := context.Background()
+
+	now := metav1.Now()
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "values", Namespace: "default", Finalizers: []string{"giantswarm.io/app"}, DeletionTimestamp: &now},
+	}
+	c := newFakeClient(t, cm)
+	w := newTestWaiter(t, c, time.Hour, 200*time.Millisecond)
+
+	err := w.UntilDeleted(ctx, cm)
+	if !IsTimeout(err) {
+		t.Fatalf("error == %#v, want timeoutError", err)
+	}
+	if !strings.Contains(err.Error(), "finalizers [giantswarm.io/app]") {
+		t.Fatalf("error == %q, want it to contain the finalizers", err.Error())
+	}
+
+	updateLater(t, c, cm, func(obj client.Object) {
+		obj.SetFinalizers(nil)
+	})
+
+	w = newTestWaiter(t, c, time.Hour, time.Second)
+	err = w.UntilDeleted(ctx, cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Waiter_UntilList(t *testing.T) {
+	ctx := context.Background()
+
+	c := newFakeClient(t, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}})
+	w := newTestWaiter(t, c, time.Hour, time.Second)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+
+		err := c.Create(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-2"}})
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	nodes := &corev1.NodeList{}
+	predicate := func(list client.ObjectList) (bool, string) {
+		return len(nodes.Items) == 2, fmt.Sprintf("%d nodes", len(nodes.Items))
+	}
+	err := w.UntilList(ctx, nodes, "have 2 nodes", predicate)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Waiter_Until(t *testing.T) {
+	ctx := context.Background()
+
+	w := newTestWaiter(t, newFakeClient(t), 10*time.Millisecond, time.Second)
+
+	failed := errors.New("failed")
+	var calls int
+	check := func(ctx context.Context) (bool, string, error) {
+		calls++
+		if calls == 3 {
+			return false, "", failed
+		}
+		return false, "pending", nil
+	}
+
+	err := w.Until(ctx, "target", "be up", check)
+	if microerror.Cause(err) != failed {
+		t.Fatalf("error == %#v, want the error of check", err)
+	}
+}
+
+func newFakeClient(t *testing.T, objects ...client.Object) client.WithWatch {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	err := corev1.AddToScheme(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func newTestWaiter(t *testing.T, c client.Client, interval time.Duration, timeout time.Duration) *Waiter {
+	t.Helper()
+
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := New(Config{
+		Logger:   logger,
+		Client:   c,
+		Interval: interval,
+		Timeout:  timeout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+// updateLater applies mutate to the current state of obj and updates it, once
+// the waiter under test is waiting.
+func updateLater(t *testing.T, c client.Client, obj client.Object, mutate func(client.Object)) {
+	current := obj.DeepCopyObject().(client.Object)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+
+		ctx := context.Background()
+
+		err := c.Get(ctx, client.ObjectKeyFromObject(current), current)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		mutate(current)
+
+		err = c.Update(ctx, current)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+}
@@ -2,7 +2,6 @@ package sonobuoy_plugin
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"testing"
@@ -14,12 +13,14 @@ import (
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/capiutil"
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
-	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/podrunner"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/promclient"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
 )
 
 func Test_Prometheus(t *testing.T) {
@@ -29,7 +30,7 @@ func Test_Prometheus(t *testing.T) {
 
 	ctx := context.Background()
 
-	cpCtrlClient, err := ctrlclient.CreateCPCtrlClient()
+	cpCtrlClient, err := ctrlclient.ForTest(t).CPCtrlClient()
 	if err != nil {
 		t.Fatalf("error creating CP k8s client: %v", err)
 	}
@@ -74,80 +75,64 @@ func Test_Prometheus(t *testing.T) {
 	}
 
 	namespace := fmt.Sprintf("%s-prometheus", clusterID)
-	podName := fmt.Sprintf("prometheus-%s-0", clusterID)
 
 	logger.Debugf(ctx, "Waiting for prometheus namespace %q to exist", namespace)
 
+	waiter, err := wait.New(wait.Config{
+		Logger:   logger,
+		Client:   cpCtrlClient,
+		Interval: 1 * time.Minute,
+		Timeout:  backoff.LongMaxWait,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// Wait for prometheus namespace to exist.
 	{
-		o := func() error {
-			ns := &corev1.Namespace{}
-			err = cpCtrlClient.Get(ctx, client.ObjectKey{Name: namespace}, ns)
-			if err != nil {
-				return microerror.Mask(err)
-			}
-
-			return nil
+		exists := func(client.Object) (bool, string) {
+			return true, ""
 		}
 
-		b := backoff.NewConstant(backoff.LongMaxWait, 1*time.Minute)
-		n := backoff.NewNotifier(logger, ctx)
-		err = backoff.RetryNotify(o, b, n)
+		err = waiter.UntilPredicate(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, "exist", exists)
 		if err != nil {
-			t.Fatalf("Error waiting for prometheus namespace to exist.")
+			t.Fatalf("Error waiting for prometheus namespace to exist: %s", microerror.JSON(err))
 		}
 	}
 
+	cpClients, err := ctrlclient.ForTest(t).CP()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	promClient, err := promclient.NewServiceProxy(cpClients.RESTConfig, namespace, "prometheus-operated", 9090, "/"+clusterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	logger.Debugf(ctx, "Waiting for prometheus targets to be up")
 
 	// Wait for all targets to be "Up".
 	{
-		o := func() error {
-			kc, err := ctrlclient.GetCPKubeConfig()
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			stdout, _, err := podrunner.ExecInPod(ctx, logger, podName, namespace, "prometheus", []string{"wget", "-q", "-O-", fmt.Sprintf("prometheus-operated.%s-prometheus:9090/%s/api/v1/targets", clusterID, clusterID)}, kc)
+		check := func(ctx context.Context) (bool, string, error) {
+			targets, err := promClient.Targets(ctx)
 			if err != nil {
-				return microerror.Maskf(podExecError, "Can't exec command in pod %s: %s.", podName, err)
-			}
-
-			type target struct {
-				Health string
-				Labels map[string]string
-			}
-
-			response := struct {
-				Data struct {
-					ActiveTargets []target
-				}
-			}{}
-
-			err = json.Unmarshal([]byte(stdout), &response)
-			if err != nil {
-				return microerror.Maskf(unexpectedAnswerError, "Can't parse prometheus targets output: %s", err)
+				return false, fmt.Sprintf("cannot get targets: %s", err), nil
 			}
 
 			down := make([]string, 0)
-			for _, target := range response.Data.ActiveTargets {
+			for _, target := range targets.ActiveTargets {
 				if target.Health != "up" {
 					down = append(down, fmt.Sprintf("%s (Health = %q)", target.Labels["job"], target.Health))
 				}
 			}
 
-			if len(down) > 0 {
-				return microerror.Maskf(targetDownError, "%d target down: %v", len(down), down)
-			}
-
-			return nil
+			return len(down) == 0, fmt.Sprintf("%d target down: %v", len(down), down), nil
 		}
 
-		b := backoff.NewConstant(backoff.LongMaxWait, 1*time.Minute)
-		n := backoff.NewNotifier(logger, ctx)
-		err = backoff.RetryNotify(o, b, n)
+		err = waiter.Until(ctx, "prometheus targets", "be up", check)
 		if err != nil {
-			t.Fatalf("Error waiting for prometheus targets to be healthy.")
+			t.Fatalf("Error waiting for prometheus targets to be healthy: %s", microerror.JSON(err))
 		}
 	}
 }
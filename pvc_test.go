@@ -6,7 +6,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/giantswarm/backoff"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	kyvernov1 "github.com/kyverno/kyverno/api/kyverno/v1"
@@ -19,6 +18,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/ctrlclient"
+	"github.com/giantswarm/sonobuoy-plugin/v5/pkg/wait"
 )
 
 const (
@@ -33,7 +33,7 @@ func Test_PVC(t *testing.T) {
 
 	ctx := context.Background()
 
-	tcCtrlClient, err := ctrlclient.CreateTCCtrlClient()
+	tcCtrlClient, err := ctrlclient.ForTest(t).TCCtrlClient()
 	if err != nil {
 		t.Fatalf("error creating CP k8s client: %v", err)
 	}
@@ -50,6 +50,16 @@ func Test_PVC(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	waiter, err := wait.New(wait.Config{
+		Logger:   logger,
+		Client:   tcCtrlClient,
+		Interval: 10 * time.Second,
+		Timeout:  5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	for _, class := range classes {
 		pvc, err := createPVC(ctx, tcCtrlClient, class)
 		if err != nil {
@@ -73,26 +83,10 @@ func Test_PVC(t *testing.T) {
 		}
 
 		// Wait for the PVC to be bound.
-		o := func() error {
-			current := &corev1.PersistentVolumeClaim{}
-			err := tcCtrlClient.Get(ctx, client.ObjectKey{Name: pvc.Name, Namespace: pvc.Namespace}, current)
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			if current.Status.Phase != corev1.ClaimBound {
-				return microerror.Maskf(pvcUnboundError, "PVC for storage class %q is in phase %s", class, current.Status.Phase)
-			}
-
-			return nil
-		}
-
-		b := backoff.NewConstant(5*time.Minute, 10*time.Second)
-		n := backoff.NewNotifier(logger, ctx)
-		err = backoff.RetryNotify(o, b, n)
+		err = waiter.UntilPhase(ctx, &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: pvc.Name, Namespace: pvc.Namespace}}, string(corev1.ClaimBound))
 		if err != nil {
 			cleanup()
-			t.Fatalf("timeout waiting for PVC to be bound: %v", err)
+			t.Fatalf("timeout waiting for PVC for storage class %q to be bound: %v", class, err)
 		}
 
 		cleanup()
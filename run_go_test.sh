@@ -4,10 +4,34 @@ set -o pipefail
 
 results_dir="${RESULTS_DIR:-/tmp/results}"
 junit_report_file="${results_dir}/combined-report.xml"
+diagnostics_dir="${results_dir}/diagnostics"
+condition_timeline_file="${results_dir}/condition-timeline.txt"
+results_tarball="${results_dir}/results.tar.gz"
+
+# Tests write the diagnostics of failed app installs and the condition timeline
+# to the results dir.
+export RESULTS_DIR="${results_dir}"
 
 # saveResults prepares the results for handoff to the Sonobuoy worker.
 # See: https://github.com/vmware-tanzu/sonobuoy/blob/master/site/docs/master/plugins.md
 saveResults() {
+  # Ship the diagnostics and the condition timeline along with the report in a
+  # tarball, if there are any.
+  artifacts=()
+  for artifact in "${diagnostics_dir}" "${condition_timeline_file}"
+  do
+    if [ -e "${artifact}" ]
+    then
+      artifacts+=("$(basename "${artifact}")")
+    fi
+  done
+
+  if [ ${#artifacts[@]} -gt 0 ] && tar -czf "${results_tarball}" -C "${results_dir}" "$(basename "${junit_report_file}")" "${artifacts[@]}"
+  then
+    printf ${results_tarball} >"${results_dir}/done"
+    return
+  fi
+
   # Signal to the worker that we are done and where to find the results.
   printf ${junit_report_file} >"${results_dir}/done"
 }